@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"strconv"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/joho/godotenv"
 	"google.golang.org/grpc"
@@ -15,6 +17,7 @@ import (
 
 	"github.com/nktauserum/web-calculation/proto/pb"
 	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
 )
 
 type Agent struct {
@@ -52,24 +55,51 @@ func (c *Agent) GetTask() (*shared.Task, error) {
 		return nil, fmt.Errorf("no tasks available")
 	}
 
+	// Операторы из нескольких символов передаются одним символом не из ASCII
+	operator, _ := utf8.DecodeRuneInString(task.Operator)
+
 	return &shared.Task{
 		ID:             task.Id,
 		FirstArgument:  task.Arg1,
 		SecondArgument: task.Arg2,
-		Operator:       rune(task.Operator[0]),
+		Operator:       operator,
+		Function:       task.Function,
+		Mode:           shared.Mode(task.Mode),
+		Precision:      int(task.Precision),
+		Rounding:       shared.Rounding(task.Rounding),
 		OperationTime:  task.OperationTime,
 		Status:         task.Status,
 		Result:         task.Result,
+		Program:        task.Program,
+		Variable:       task.Variable,
+		Chunk:          int(task.Chunk),
+		Chunks:         int(task.Chunks),
 	}, nil
 }
 
-func (c *Agent) CompleteTask(id int64, result float64) error {
+// CompleteTask отправляет оркестратору результат задачи: приближённое значение
+// и запись Value, по которой оркестратор восстанавливает результат без потерь
+func (c *Agent) CompleteTask(result shared.TaskResult) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	_, err := c.client.CompleteTask(ctx, &pb.TaskResult{
+		Id:     result.ID,
+		Result: result.Result,
+		Imag:   result.Imag,
+		Value:  result.Value,
+	})
+	return err
+}
+
+// FailTask сообщает оркестратору, что задачу невозможно вычислить
+func (c *Agent) FailTask(id int64, reason string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
 	_, err := c.client.CompleteTask(ctx, &pb.TaskResult{
-		Id:     id,
-		Result: result,
+		Id:    id,
+		Error: reason,
 	})
 	return err
 }
@@ -82,6 +112,12 @@ func (c *Agent) Close() {
 
 func (app *Agent) Run() error {
 	log.Println("Agent started!")
+
+	// Время операций задаётся в .env. Файл читается один раз: godotenv.Load
+	// не перезаписывает уже заданные переменные, поэтому повторное чтение ничего не меняет
+	if err := godotenv.Load(".env"); err != nil {
+		log.Printf("Error loading .env file: %v", err)
+	}
 	computingPower := os.Getenv("COMPUTING_POWER")
 	workers, err := strconv.Atoi(computingPower)
 	if err != nil {
@@ -114,13 +150,19 @@ func (app *Agent) Run() error {
 				log.Printf("Получена задача %d", task.ID)
 
 				// Calculate expression
-				result, err := calculateExpression(*task)
+				result, err := compute(*task)
 				if err != nil {
 					log.Printf("Error calculating expression: %v", err)
+					// Повторная попытка даст ту же ошибку, поэтому задача считается проваленной
+					if err := app.FailTask(task.ID, err.Error()); err != nil {
+						log.Printf("Error failing task: %v", err)
+						continue
+					}
+					processedTasks.Delete(task.ID)
 					continue
 				}
 
-				err = app.CompleteTask(task.ID, result)
+				err = app.CompleteTask(result)
 				if err != nil {
 					log.Printf("Error completing task: %v", err)
 					continue
@@ -135,91 +177,183 @@ func (app *Agent) Run() error {
 	return nil
 }
 
+// compute вычисляет задачу в её режиме арифметики. Возвращает приближённое
+// значение результата и его запись без потери точности
+func compute(task shared.Task) (shared.TaskResult, error) {
+	result := shared.TaskResult{ID: task.ID}
+
+	if task.Operator == '∫' || task.Operator == 'Σ' {
+		value, err := calculateChunk(task)
+		if err != nil {
+			return result, err
+		}
+		if err := shared.CheckFinite(value); err != nil {
+			return result, err
+		}
+		result.Result = value
+		result.Value = shared.FormatComplex(complex(value, 0))
+		return result, nil
+	}
+
+	if task.Mode == shared.ModeExact || task.Mode == shared.ModeDecimal {
+		value, err := calculateExact(task)
+		if err != nil {
+			return result, err
+		}
+		if task.Mode == shared.ModeExact {
+			result.Value = value.RatString()
+		} else {
+			// Десятичный режим: точный результат операции округляется до заданной точности
+			result.Value = shared.RoundDecimal(value, task.Precision, task.Rounding)
+			value.SetString(result.Value)
+		}
+		result.Result, _ = value.Float64()
+		return result, nil
+	}
+
+	if task.Mode == shared.ModeInterval {
+		iv, err := calculateInterval(task)
+		if err != nil {
+			return result, err
+		}
+		// Неограниченный интервал ничего не говорит о результате
+		for _, bound := range []float64{iv.Lo, iv.Hi} {
+			if err := shared.CheckFinite(bound); err != nil {
+				return result, err
+			}
+		}
+		result.Result = iv.Mid()
+		result.Value = iv.String()
+		return result, nil
+	}
+
+	if shared.IsSamples(task.FirstArgument) || shared.IsSamples(task.SecondArgument) {
+		samples, err := calculateSamples(task)
+		if err != nil {
+			return result, err
+		}
+		result.Result = samples.Mean()
+		result.Value = samples.String()
+		return result, nil
+	}
+
+	var z complex128
+	if needsComplex(task) {
+		value, err := calculateComplex(task)
+		if err != nil {
+			return result, err
+		}
+		z = value
+	} else {
+		value, err := calculateExpression(task)
+		if err != nil {
+			return result, err
+		}
+		z = complex(value, 0)
+	}
+
+	// Бесконечность и NaN оркестратору не отправляются
+	for _, part := range []float64{real(z), imag(z)} {
+		if err := shared.CheckFinite(part); err != nil {
+			return result, err
+		}
+	}
+	result.Result, result.Imag = real(z), imag(z)
+	result.Value = shared.FormatComplex(z)
+	return result, nil
+}
+
 /*
 Время выполнения операций задается переменными среды в миллисекундах
 TIME_ADDITION_MS - время выполнения операции сложения в миллисекундах
 TIME_SUBTRACTION_MS - время выполнения операции вычитания в миллисекундах
 TIME_MULTIPLICATIONS_MS - время выполнения операции умножения в миллисекундах
 TIME_DIVISIONS_MS - время выполнения операции деления в миллисекундах
+TIME_POWER_MS - время выполнения операции возведения в степень в миллисекундах
+TIME_MODULO_MS, TIME_INT_DIVISION_MS - время выполнения операций % и //
+TIME_BITWISE_MS, TIME_SHIFT_MS - время выполнения побитовых операций и сдвигов
+TIME_COMPARISON_MS - время выполнения сравнений
+TIME_<ФУНКЦИЯ>_MS - время вычисления функции, например TIME_SQRT_MS
 */
 func calculateExpression(task shared.Task) (float64, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		log.Printf("Error loading .env file: %v", err)
-	}
-
 	firstarg, err := strconv.ParseFloat(task.FirstArgument, 64)
 	if err != nil {
 		log.Printf("Error parsing first argument: %v", err)
 		return 0, err
 	}
-	secondarg, err := strconv.ParseFloat(task.SecondArgument, 64)
-	if err != nil {
-		log.Printf("Error parsing second argument: %v", err)
+
+	// У функций одного аргумента второй аргумент пуст
+	var secondarg float64
+	if _, ok := shared.UnaryFunctions[task.Function]; !ok {
+		secondarg, err = strconv.ParseFloat(task.SecondArgument, 64)
+		if err != nil {
+			log.Printf("Error parsing second argument: %v", err)
+			return 0, err
+		}
+	}
+
+	if err := delay(shared.DelayVariable(task.Operator, task.Function)); err != nil {
 		return 0, err
 	}
+	return evaluate(task, firstarg, secondarg)
+}
 
-	switch task.Operator {
-	case '+':
-		// задержка для сложения
-		var addition_time time.Duration
-		if os.Getenv("TIME_ADDITION_MS") == "" {
-			addition_time = time.Second * 0
-		} else {
-			addition_time, err = time.ParseDuration(os.Getenv("TIME_ADDITION_MS") + "ms")
-			if err != nil {
-				log.Printf("Error parsing addition_time: %v", err)
-				return 0, err
-			}
+// evaluate выполняет операцию задачи над числами x и y без задержки
+func evaluate(task shared.Task, x, y float64) (float64, error) {
+	if function, ok := shared.UnaryFunctions[task.Function]; ok {
+		return function(x)
+	}
+
+	if task.Function != "" {
+		function, ok := shared.BinaryFunctions[task.Function]
+		if !ok {
+			return 0, fmt.Errorf("%w: %s", errors.ErrUnknownFunction, task.Function)
 		}
+		return function(x, y)
+	}
 
-		time.Sleep(addition_time)
-		return firstarg + secondarg, nil
+	switch task.Operator {
+	case '+':
+		return x + y, nil
 	case '-':
-		var subtraction_time time.Duration
-		if os.Getenv("TIME_SUBTRACTION_MS") == "" {
-			subtraction_time = time.Second * 0
-		} else {
-			subtraction_time, err = time.ParseDuration(os.Getenv("TIME_SUBTRACTION_MS") + "ms")
-			if err != nil {
-				log.Printf("Error parsing TIME_SUBTRACTION_MS: %v", err)
-				return 0, err
-			}
-		}
-		time.Sleep(subtraction_time)
-		return firstarg - secondarg, nil
+		return x - y, nil
 	case '*':
-		// задержка для умножения
-		var multiplication_time time.Duration
-		if os.Getenv("TIME_MULTIPLICATIONS_MS") == "" {
-			multiplication_time = time.Second * 0
-		} else {
-			multiplication_time, err = time.ParseDuration(os.Getenv("TIME_MULTIPLICATIONS_MS") + "ms")
-			if err != nil {
-				log.Printf("Error parsing TIME_MULTIPLICATIONS_MS: %v", err)
-				return 0, err
-			}
-		}
-		time.Sleep(multiplication_time)
-		return firstarg * secondarg, nil
+		return x * y, nil
 	case '/':
-		// задержка для деления
-		var division_time time.Duration
-		if os.Getenv("TIME_DIVISIONS_MS") == "" {
-			division_time = time.Second * 0
-		} else {
-			division_time, err = time.ParseDuration(os.Getenv("TIME_DIVISIONS_MS") + "ms")
-			if err != nil {
-				log.Printf("Error parsing TIME_DIVISIONS_MS: %v", err)
-				return 0, err
-			}
-		}
-		time.Sleep(division_time)
-
-		if secondarg == 0 {
+		if y == 0 {
 			return 0, fmt.Errorf("на ноль делить нельзя")
 		}
-		return firstarg / secondarg, nil
+		return x / y, nil
+	case '^':
+		return math.Pow(x, y), nil
+	case '<', '>', '≤', '≥', '=', '≠':
+		cmp := 0
+		if x < y {
+			cmp = -1
+		} else if x > y {
+			cmp = 1
+		}
+		return compare(task.Operator, cmp), nil
+	case '%', '÷', '&', '|', '⊕', '≪', '≫':
+		return integerOperation(task.Operator, x, y)
+	}
+	return 0, fmt.Errorf("%w: %c", errors.ErrUnknownOperator, task.Operator)
+}
 
+// delay имитирует длительное вычисление: ждёт столько миллисекунд,
+// сколько указано в переменной среды variable. Пустая переменная означает отсутствие задержки
+func delay(variable string) error {
+	value := os.Getenv(variable)
+	if value == "" {
+		return nil
 	}
-	return 0, nil
+
+	duration, err := time.ParseDuration(value + "ms")
+	if err != nil {
+		log.Printf("Error parsing %s: %v", variable, err)
+		return err
+	}
+
+	time.Sleep(duration)
+	return nil
 }
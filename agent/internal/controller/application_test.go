@@ -0,0 +1,262 @@
+package controller
+
+import (
+	stderrors "errors"
+	"math"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+func TestCalculateExpression(t *testing.T) {
+	tests := []struct {
+		name string
+		task shared.Task
+		want float64
+	}{
+		{"addition", shared.Task{FirstArgument: "2", SecondArgument: "3", Operator: '+'}, 5},
+		{"power", shared.Task{FirstArgument: "2", SecondArgument: "10", Operator: '^'}, 1024},
+		{"function", shared.Task{FirstArgument: "16", Operator: 'f', Function: "sqrt"}, 4},
+		{"abs", shared.Task{FirstArgument: "-2.5", Operator: 'f', Function: "abs"}, 2.5},
+		{"binary function", shared.Task{FirstArgument: "3", SecondArgument: "7", Operator: 'f', Function: "max"}, 7},
+	}
+
+	for _, tt := range tests {
+		got, err := calculateExpression(tt.task)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: calculateExpression = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestCalculateExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		task shared.Task
+		err  error
+	}{
+		{"sqrt domain", shared.Task{FirstArgument: "-1", Operator: 'f', Function: "sqrt"}, errors.ErrDomain},
+		{"ln domain", shared.Task{FirstArgument: "0", Operator: 'f', Function: "ln"}, errors.ErrDomain},
+		{"unknown function", shared.Task{FirstArgument: "1", SecondArgument: "2", Operator: 'f', Function: "foo"}, errors.ErrUnknownFunction},
+		{"unknown operator", shared.Task{FirstArgument: "1", SecondArgument: "2", Operator: '?'}, errors.ErrUnknownOperator},
+	}
+
+	for _, tt := range tests {
+		if _, err := calculateExpression(tt.task); !stderrors.Is(err, tt.err) {
+			t.Errorf("%s: calculateExpression = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name   string
+		task   shared.Task
+		value  string
+		result float64
+	}{
+		{
+			name:   "float",
+			task:   shared.Task{FirstArgument: "0.1", SecondArgument: "0.2", Operator: '+', Mode: shared.ModeFloat},
+			value:  "0.30000000000000004",
+			result: 0.30000000000000004,
+		},
+		{
+			name:   "float function",
+			task:   shared.Task{FirstArgument: "16", Operator: 'f', Function: "sqrt", Mode: shared.ModeFloat},
+			value:  "4",
+			result: 4,
+		},
+		{
+			name:   "exact",
+			task:   shared.Task{FirstArgument: "1/10", SecondArgument: "1/5", Operator: '+', Mode: shared.ModeExact},
+			value:  "3/10",
+			result: 0.3,
+		},
+		{
+			name:   "exact power",
+			task:   shared.Task{FirstArgument: "2/3", SecondArgument: "-2", Operator: '^', Mode: shared.ModeExact},
+			value:  "9/4",
+			result: 2.25,
+		},
+		{
+			name:   "decimal",
+			task:   shared.Task{FirstArgument: "1", SecondArgument: "3", Operator: '/', Mode: shared.ModeDecimal, Precision: 5, Rounding: shared.RoundHalfEven},
+			value:  "0.33333",
+			result: 0.33333,
+		},
+		{
+			name:   "decimal rounding",
+			task:   shared.Task{FirstArgument: "2", SecondArgument: "3", Operator: '/', Mode: shared.ModeDecimal, Precision: 3, Rounding: shared.RoundDown},
+			value:  "0.666",
+			result: 0.666,
+		},
+		{
+			name:   "exact integer division",
+			task:   shared.Task{FirstArgument: "-7", SecondArgument: "2", Operator: '÷', Mode: shared.ModeExact},
+			value:  "-4",
+			result: -4,
+		},
+		{
+			name:   "interval",
+			task:   shared.Task{FirstArgument: "[1, 2]", SecondArgument: "[3, 4]", Operator: '+', Mode: shared.ModeInterval},
+			value:  "[4, 6]",
+			result: 5,
+		},
+		{
+			name:   "samples",
+			task:   shared.Task{FirstArgument: "{1, 2, 3}", SecondArgument: "2", Operator: '*', Mode: shared.ModeFloat},
+			value:  "{2, 4, 6}",
+			result: 4,
+		},
+		{
+			name:   "complex",
+			task:   shared.Task{FirstArgument: "2i", SecondArgument: "2i", Operator: '*', Mode: shared.ModeFloat},
+			value:  "-4",
+			result: -4,
+		},
+		{
+			name:   "complex function",
+			task:   shared.Task{FirstArgument: "2i", Operator: 'f', Function: "sqrt", Mode: shared.ModeFloat},
+			value:  "1+1i",
+			result: 1,
+		},
+		{
+			name:   "comparison",
+			task:   shared.Task{FirstArgument: "0.1", SecondArgument: "0.2", Operator: '<', Mode: shared.ModeFloat},
+			value:  "1",
+			result: 1,
+		},
+		{
+			name:   "exact comparison",
+			task:   shared.Task{FirstArgument: "1/3", SecondArgument: "1/2", Operator: '≥', Mode: shared.ModeExact},
+			value:  "0",
+			result: 0,
+		},
+		{
+			name:   "complex equality",
+			task:   shared.Task{FirstArgument: "2i", SecondArgument: "2i", Operator: '=', Mode: shared.ModeFloat},
+			value:  "1",
+			result: 1,
+		},
+		{
+			name:   "series",
+			task:   shared.Task{FirstArgument: "1", SecondArgument: "10", Operator: 'Σ', Function: "sum", Program: []string{"k"}, Variable: "k", Chunk: 1, Chunks: 2},
+			value:  "40",
+			result: 40,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := compute(tt.task)
+		if err != nil {
+			t.Errorf("%s: compute: %v", tt.name, err)
+			continue
+		}
+		if got.Value != tt.value || got.Result != tt.result {
+			t.Errorf("%s: compute = %q (%v), want %q (%v)", tt.name, got.Value, got.Result, tt.value, tt.result)
+		}
+	}
+}
+
+func TestComputeIntegral(t *testing.T) {
+	// Участки интеграла x^2 по [0, 1] в сумме дают 1/3 с точностью формулы Симпсона
+	var sum float64
+	for chunk := range 4 {
+		task := shared.Task{FirstArgument: "0", SecondArgument: "1", Operator: '∫', Function: "integrate", Program: []string{"x", "x", "*"}, Variable: "x", Chunk: chunk, Chunks: 4}
+		got, err := compute(task)
+		if err != nil {
+			t.Fatalf("участок %d: %v", chunk, err)
+		}
+		sum += got.Result
+	}
+	if math.Abs(sum-1.0/3) > 1e-12 {
+		t.Errorf("integrate(x^2, x, 0, 1) = %v, want 1/3", sum)
+	}
+}
+
+func TestComputeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		task shared.Task
+		err  error
+	}{
+		{
+			name: "overflow",
+			task: shared.Task{FirstArgument: "1e308", SecondArgument: "10", Operator: '*', Mode: shared.ModeFloat},
+			err:  errors.ErrOverflow,
+		},
+		{
+			name: "exact sqrt",
+			task: shared.Task{FirstArgument: "2", Operator: 'f', Function: "sqrt", Mode: shared.ModeExact},
+			err:  errors.ErrInexact,
+		},
+		{
+			name: "interval division",
+			task: shared.Task{FirstArgument: "1", SecondArgument: "[-1, 1]", Operator: '/', Mode: shared.ModeInterval},
+			err:  errors.ErrIntervalDivision,
+		},
+		// Вещественные аргументы не переводят задачу в комплексные числа
+		{
+			name: "real sqrt",
+			task: shared.Task{FirstArgument: "-1", Operator: 'f', Function: "sqrt", Mode: shared.ModeFloat},
+			err:  errors.ErrDomain,
+		},
+		{
+			name: "real ln",
+			task: shared.Task{FirstArgument: "-1", Operator: 'f', Function: "ln", Mode: shared.ModeFloat},
+			err:  errors.ErrDomain,
+		},
+		{
+			name: "real root",
+			task: shared.Task{FirstArgument: "-8", SecondArgument: "0.3333333333333333", Operator: '^', Mode: shared.ModeFloat},
+			err:  errors.ErrNotANumber,
+		},
+		{
+			name: "complex min",
+			task: shared.Task{FirstArgument: "1i", SecondArgument: "1", Operator: 'f', Function: "min", Mode: shared.ModeFloat},
+			err:  errors.ErrComplexArgument,
+		},
+		{
+			name: "complex order",
+			task: shared.Task{FirstArgument: "1i", SecondArgument: "1", Operator: '<', Mode: shared.ModeFloat},
+			err:  errors.ErrComplexArgument,
+		},
+		{
+			name: "series limits",
+			task: shared.Task{FirstArgument: "1.5", SecondArgument: "3", Operator: 'Σ', Function: "sum", Program: []string{"k"}, Variable: "k", Chunks: 1},
+			err:  errors.ErrNotInteger,
+		},
+		{
+			name: "series terms",
+			task: shared.Task{FirstArgument: "1", SecondArgument: "1e9", Operator: 'Σ', Function: "sum", Program: []string{"k"}, Variable: "k", Chunks: 1},
+			err:  errors.ErrTooManyTerms,
+		},
+	}
+
+	for _, tt := range tests {
+		if _, err := compute(tt.task); !stderrors.Is(err, tt.err) {
+			t.Errorf("%s: compute = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	// Деление на ноль в каждом режиме - ошибка задачи, а не бесконечность
+	for _, mode := range []shared.Mode{shared.ModeFloat, shared.ModeExact, shared.ModeDecimal, shared.ModeInterval} {
+		task := shared.Task{FirstArgument: "1", SecondArgument: "0", Operator: '/', Mode: mode, Precision: 5}
+		if _, err := compute(task); err == nil {
+			t.Errorf("%s: compute(1 / 0) без ошибки", mode)
+		}
+	}
+}
+
+func TestRunBranches(t *testing.T) {
+	// if(x > 1, 1 / 0, x): невыбранная ветка с делением на ноль не вычисляется
+	program := []string{"x", "1", ">", "?", "1", "0", "/", ":", "x", ";"}
+	if got, err := run(program, "x", 0.5); err != nil || got != 0.5 {
+		t.Errorf("run(%v, x = 0.5) = %v, %v, want 0.5", program, got, err)
+	}
+	if _, err := run(program, "x", 2); err == nil {
+		t.Errorf("run(%v, x = 2): want ошибку деления на ноль", program)
+	}
+}
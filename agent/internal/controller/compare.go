@@ -0,0 +1,33 @@
+package controller
+
+// isComparison проверяет, является ли оператор сравнением
+func isComparison(op rune) bool {
+	switch op {
+	case '<', '>', '≤', '≥', '=', '≠':
+		return true
+	}
+	return false
+}
+
+// compare переводит результат сравнения аргументов (cmp: -1, 0 или 1) в 1 для истины и 0 для лжи
+func compare(op rune, cmp int) float64 {
+	var truth bool
+	switch op {
+	case '<':
+		truth = cmp < 0
+	case '>':
+		truth = cmp > 0
+	case '≤':
+		truth = cmp <= 0
+	case '≥':
+		truth = cmp >= 0
+	case '=':
+		truth = cmp == 0
+	case '≠':
+		truth = cmp != 0
+	}
+	if truth {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strconv"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Комплексные версии функций одного аргумента
+var complexFunctions = map[string]func(z complex128) (complex128, error){
+	"sqrt": func(z complex128) (complex128, error) { return cmplx.Sqrt(z), nil },
+	"abs":  func(z complex128) (complex128, error) { return complex(cmplx.Abs(z), 0), nil },
+	"ln": func(z complex128) (complex128, error) {
+		if z == 0 {
+			return 0, fmt.Errorf("ln(0): %w", errors.ErrDomain)
+		}
+		return cmplx.Log(z), nil
+	},
+	"log10": func(z complex128) (complex128, error) {
+		if z == 0 {
+			return 0, fmt.Errorf("log10(0): %w", errors.ErrDomain)
+		}
+		return cmplx.Log10(z), nil
+	},
+	"exp": func(z complex128) (complex128, error) { return cmplx.Exp(z), nil },
+	"sin": func(z complex128) (complex128, error) { return cmplx.Sin(z), nil },
+	"cos": func(z complex128) (complex128, error) { return cmplx.Cos(z), nil },
+	"tan": func(z complex128) (complex128, error) { return cmplx.Tan(z), nil },
+}
+
+// needsComplex проверяет, нужно ли вычислять задачу в комплексных числах: хотя бы
+// один из аргументов имеет мнимую часть. Операции над вещественными числами без
+// вещественного результата (sqrt(-4), ln(-1), (-8)^(1/3)) остаются ошибками области
+// определения
+func needsComplex(task shared.Task) bool {
+	if _, err := strconv.ParseFloat(task.FirstArgument, 64); err != nil {
+		return true
+	}
+	_, err := strconv.ParseFloat(task.SecondArgument, 64)
+	return err != nil && task.SecondArgument != ""
+}
+
+// calculateComplex вычисляет задачу над комплексными числами
+func calculateComplex(task shared.Task) (complex128, error) {
+	x, err := strconv.ParseComplex(task.FirstArgument, 128)
+	if err != nil {
+		return 0, err
+	}
+
+	if function, ok := complexFunctions[task.Function]; ok {
+		if err := delay(shared.DelayVariable(task.Operator, task.Function)); err != nil {
+			return 0, err
+		}
+		return function(x)
+	}
+
+	y, err := strconv.ParseComplex(task.SecondArgument, 128)
+	if err != nil {
+		return 0, err
+	}
+
+	// Комплексные числа не упорядочены, поэтому min и max для них не определены
+	if task.Function != "" {
+		return 0, fmt.Errorf("%s(%s, %s): %w", task.Function, task.FirstArgument, task.SecondArgument, errors.ErrComplexArgument)
+	}
+
+	// Комплексные числа можно проверить только на равенство
+	if isComparison(task.Operator) {
+		if task.Operator != '=' && task.Operator != '≠' {
+			return 0, fmt.Errorf("%s %c %s: %w", task.FirstArgument, task.Operator, task.SecondArgument, errors.ErrComplexArgument)
+		}
+		if err := delay(shared.ComparisonDelay); err != nil {
+			return 0, err
+		}
+		cmp := 0
+		if x != y {
+			cmp = 1
+		}
+		return complex(compare(task.Operator, cmp), 0), nil
+	}
+
+	if variable, ok := shared.IntegerDelays[task.Operator]; ok {
+		if imag(x) != 0 || imag(y) != 0 {
+			return 0, fmt.Errorf("%w: %s %c %s", errors.ErrNotInteger, task.FirstArgument, task.Operator, task.SecondArgument)
+		}
+		if err := delay(variable); err != nil {
+			return 0, err
+		}
+		result, err := integerOperation(task.Operator, real(x), real(y))
+		return complex(result, 0), err
+	}
+
+	variable, ok := shared.ArithmeticDelays[task.Operator]
+	if !ok {
+		return 0, fmt.Errorf("%w: %c", errors.ErrUnknownOperator, task.Operator)
+	}
+	if err := delay(variable); err != nil {
+		return 0, err
+	}
+
+	switch task.Operator {
+	case '+':
+		return x + y, nil
+	case '-':
+		return x - y, nil
+	case '*':
+		return x * y, nil
+	case '/':
+		if y == 0 {
+			return 0, fmt.Errorf("на ноль делить нельзя")
+		}
+		return x / y, nil
+	}
+	return complexPower(x, y), nil
+}
+
+// complexPower возводит комплексное число в степень. Целую степень считаем
+// умножениями: cmplx.Pow идёт через логарифм и даёт i^2 = -1+1.2e-16i
+func complexPower(x, y complex128) complex128 {
+	n := real(y)
+	if imag(y) != 0 || math.Trunc(n) != n || math.Abs(n) > 1024 {
+		return cmplx.Pow(x, y)
+	}
+
+	result, base := complex(1, 0), x
+	for k := int(math.Abs(n)); k > 0; k >>= 1 {
+		if k&1 == 1 {
+			result *= base
+		}
+		base *= base
+	}
+	if n < 0 {
+		return 1 / result
+	}
+	return result
+}
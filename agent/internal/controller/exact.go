@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Наибольший по модулю показатель степени в точном режиме: знаменатель и числитель
+// результата растут вместе с показателем
+const maxExactExponent = 4096
+
+// calculateExact вычисляет задачу точного или десятичного режима над рациональными числами.
+// Аргументы записаны дробями или десятичными числами: "1/10", "0.1", "-3".
+// Функции, результат которых обычно иррационален (sqrt, ln, sin...), точно не вычисляются
+func calculateExact(task shared.Task) (*big.Rat, error) {
+	switch task.Function {
+	case "", "abs", "min", "max":
+	default:
+		return nil, fmt.Errorf("%s: %w в режиме %s", task.Function, errors.ErrInexact, task.Mode)
+	}
+
+	x, ok := new(big.Rat).SetString(task.FirstArgument)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errors.ErrInvalidNumber, task.FirstArgument)
+	}
+
+	if task.Function == "abs" {
+		if err := delay(shared.DelayVariable(task.Operator, task.Function)); err != nil {
+			return nil, err
+		}
+		return x.Abs(x), nil
+	}
+
+	y, ok := new(big.Rat).SetString(task.SecondArgument)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errors.ErrInvalidNumber, task.SecondArgument)
+	}
+
+	if task.Function != "" {
+		if err := delay(shared.DelayVariable(task.Operator, task.Function)); err != nil {
+			return nil, err
+		}
+		if (x.Cmp(y) < 0) == (task.Function == "min") {
+			return x, nil
+		}
+		return y, nil
+	}
+
+	if isComparison(task.Operator) {
+		if err := delay(shared.ComparisonDelay); err != nil {
+			return nil, err
+		}
+		return new(big.Rat).SetFloat64(compare(task.Operator, x.Cmp(y))), nil
+	}
+
+	if variable, ok := shared.IntegerDelays[task.Operator]; ok {
+		if err := delay(variable); err != nil {
+			return nil, err
+		}
+		return exactIntegerOperation(task.Operator, x, y)
+	}
+
+	variable, ok := shared.ArithmeticDelays[task.Operator]
+	if !ok {
+		return nil, fmt.Errorf("%w: %c", errors.ErrUnknownOperator, task.Operator)
+	}
+	if err := delay(variable); err != nil {
+		return nil, err
+	}
+
+	result := new(big.Rat)
+	switch task.Operator {
+	case '+':
+		return result.Add(x, y), nil
+	case '-':
+		return result.Sub(x, y), nil
+	case '*':
+		return result.Mul(x, y), nil
+	case '/':
+		if y.Sign() == 0 {
+			return nil, fmt.Errorf("на ноль делить нельзя")
+		}
+		return result.Quo(x, y), nil
+	}
+
+	// Дробный показатель обычно даёт иррациональный результат
+	if !y.IsInt() {
+		return nil, fmt.Errorf("%s ^ %s: %w в режиме %s", task.FirstArgument, task.SecondArgument, errors.ErrInexact, task.Mode)
+	}
+	return exactPower(x, y.Num())
+}
+
+// exactPower возводит рациональное число в целую степень
+func exactPower(x *big.Rat, exponent *big.Int) (*big.Rat, error) {
+	if exponent.CmpAbs(big.NewInt(maxExactExponent)) > 0 {
+		return nil, fmt.Errorf("%w: %s, допустимо не больше %d по модулю", errors.ErrExponentTooLarge, exponent, maxExactExponent)
+	}
+	if exponent.Sign() < 0 && x.Sign() == 0 {
+		return nil, fmt.Errorf("на ноль делить нельзя")
+	}
+
+	n := new(big.Int).Abs(exponent)
+	num := new(big.Int).Exp(x.Num(), n, nil)
+	den := new(big.Int).Exp(x.Denom(), n, nil)
+	if exponent.Sign() < 0 {
+		num, den = den, num
+	}
+	return new(big.Rat).SetFrac(num, den), nil
+}
+
+// exactIntegerOperation выполняет целочисленную операцию без ограничения разрядности.
+// Деление с остатком округляет частное вниз, как и integerOperation
+func exactIntegerOperation(op rune, x, y *big.Rat) (*big.Rat, error) {
+	if !x.IsInt() {
+		return nil, fmt.Errorf("%w: %s", errors.ErrNotInteger, x.RatString())
+	}
+	if !y.IsInt() {
+		return nil, fmt.Errorf("%w: %s", errors.ErrNotInteger, y.RatString())
+	}
+	a, b := x.Num(), y.Num()
+
+	result := new(big.Int)
+	switch op {
+	case '%', '÷':
+		if b.Sign() == 0 {
+			return nil, fmt.Errorf("на ноль делить нельзя")
+		}
+		quotient, remainder := new(big.Int).QuoRem(a, b, new(big.Int))
+		if remainder.Sign() != 0 && remainder.Sign() != b.Sign() {
+			quotient.Sub(quotient, big.NewInt(1))
+			remainder.Add(remainder, b)
+		}
+		result = remainder
+		if op == '÷' {
+			result = quotient
+		}
+	case '&':
+		result.And(a, b)
+	case '|':
+		result.Or(a, b)
+	case '⊕':
+		result.Xor(a, b)
+	case '≪', '≫':
+		if b.Sign() < 0 || b.Cmp(big.NewInt(63)) > 0 {
+			return nil, fmt.Errorf("%w: сдвиг на %s бит", errors.ErrInvalidShift, b)
+		}
+		if op == '≫' {
+			result.Rsh(a, uint(b.Uint64()))
+		} else {
+			result.Lsh(a, uint(b.Uint64()))
+		}
+	default:
+		return nil, fmt.Errorf("%w: %c", errors.ErrUnknownOperator, op)
+	}
+	return new(big.Rat).SetInt(result), nil
+}
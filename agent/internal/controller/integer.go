@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Наибольшее по модулю целое, которое float64 представляет точно
+const maxExactInteger = 1 << 53
+
+// integerOperation выполняет операцию, определённую только для целых чисел.
+// Деление с остатком округляет частное вниз, как в Python: -7 // 2 = -4, -7 % 2 = 1
+func integerOperation(op rune, x, y float64) (float64, error) {
+	a, err := toInteger(x)
+	if err != nil {
+		return 0, err
+	}
+	b, err := toInteger(y)
+	if err != nil {
+		return 0, err
+	}
+
+	var result int64
+	switch op {
+	case '%', '÷':
+		if b == 0 {
+			return 0, fmt.Errorf("на ноль делить нельзя")
+		}
+		quotient, remainder := a/b, a%b
+		if remainder != 0 && (remainder < 0) != (b < 0) {
+			quotient--
+			remainder += b
+		}
+		result = remainder
+		if op == '÷' {
+			result = quotient
+		}
+	case '&':
+		result = a & b
+	case '|':
+		result = a | b
+	case '⊕':
+		result = a ^ b
+	case '≪', '≫':
+		if b < 0 || b > 63 {
+			return 0, fmt.Errorf("%w: сдвиг на %d бит", errors.ErrInvalidShift, b)
+		}
+		if op == '≫' {
+			result = a >> b
+			break
+		}
+		result = a << b
+		if result>>b != a {
+			return 0, fmt.Errorf("%w: %d << %d", errors.ErrIntegerOverflow, a, b)
+		}
+	default:
+		return 0, fmt.Errorf("%w: %c", errors.ErrUnknownOperator, op)
+	}
+
+	if result > maxExactInteger || result < -maxExactInteger {
+		return 0, fmt.Errorf("%w: %d", errors.ErrIntegerOverflow, result)
+	}
+	return float64(result), nil
+}
+
+// toInteger проверяет, что число целое и представлено точно
+func toInteger(x float64) (int64, error) {
+	if math.Trunc(x) != x || math.Abs(x) > maxExactInteger {
+		return 0, fmt.Errorf("%w: %v", errors.ErrNotInteger, x)
+	}
+	return int64(x), nil
+}
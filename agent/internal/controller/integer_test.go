@@ -0,0 +1,59 @@
+package controller
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+func TestIntegerOperation(t *testing.T) {
+	tests := []struct {
+		x    float64
+		op   rune
+		y    float64
+		want float64
+	}{
+		{7, '%', 3, 1},
+		{-7, '%', 2, 1},
+		{7, '%', -3, -2},
+		{-7, '÷', 2, -4},
+		{7, '÷', 2, 3},
+		{6, '&', 3, 2},
+		{6, '|', 3, 7},
+		{6, '⊕', 3, 5},
+		{1, '≪', 10, 1024},
+		{-16, '≫', 2, -4},
+	}
+
+	for _, tt := range tests {
+		got, err := integerOperation(tt.op, tt.x, tt.y)
+		if err != nil || got != tt.want {
+			t.Errorf("%v %c %v = %v, %v, want %v", tt.x, tt.op, tt.y, got, err, tt.want)
+		}
+	}
+}
+
+func TestIntegerOperationErrors(t *testing.T) {
+	tests := []struct {
+		x   float64
+		op  rune
+		y   float64
+		err error
+	}{
+		{1.5, '&', 1, errors.ErrNotInteger},
+		{1, '%', 1e300, errors.ErrNotInteger},
+		{1, '≪', 64, errors.ErrInvalidShift},
+		{1, '≫', -1, errors.ErrInvalidShift},
+		{1, '≪', 60, errors.ErrIntegerOverflow},
+	}
+
+	for _, tt := range tests {
+		if _, err := integerOperation(tt.op, tt.x, tt.y); !stderrors.Is(err, tt.err) {
+			t.Errorf("%v %c %v = %v, want %v", tt.x, tt.op, tt.y, err, tt.err)
+		}
+	}
+	if _, err := integerOperation('%', 1, 0); err == nil {
+		t.Errorf("1 %% 0 без ошибки")
+	}
+}
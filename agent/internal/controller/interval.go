@@ -0,0 +1,361 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Функции пакета math не гарантируют правильного округления: их результат
+// расширяется на столько единиц последнего разряда в каждую сторону
+const libraryULPs = 4
+
+// Наибольший по модулю целый показатель степени, которая вычисляется умножениями
+const maxIntervalExponent = 1 << 53
+
+// calculateInterval вычисляет задачу интервального режима. Аргументы - интервалы "[lo, hi]"
+// или числа. Границы результата округляются наружу, поэтому он содержит значение
+// операции для любых точек аргументов
+func calculateInterval(task shared.Task) (shared.Interval, error) {
+	x, err := shared.ParseInterval(task.FirstArgument)
+	if err != nil {
+		return shared.Interval{}, err
+	}
+
+	// У функций одного аргумента второй аргумент пуст
+	if _, ok := shared.UnaryFunctions[task.Function]; ok {
+		if err := delay(shared.DelayVariable(task.Operator, task.Function)); err != nil {
+			return shared.Interval{}, err
+		}
+		return intervalFunction(task.Function, x)
+	}
+
+	y, err := shared.ParseInterval(task.SecondArgument)
+	if err != nil {
+		return shared.Interval{}, err
+	}
+
+	if task.Function != "" {
+		if _, ok := shared.BinaryFunctions[task.Function]; !ok {
+			return shared.Interval{}, fmt.Errorf("%w: %s", errors.ErrUnknownFunction, task.Function)
+		}
+		if err := delay(shared.DelayVariable(task.Operator, task.Function)); err != nil {
+			return shared.Interval{}, err
+		}
+		if task.Function == "min" {
+			return shared.Interval{Lo: math.Min(x.Lo, y.Lo), Hi: math.Min(x.Hi, y.Hi)}, nil
+		}
+		return shared.Interval{Lo: math.Max(x.Lo, y.Lo), Hi: math.Max(x.Hi, y.Hi)}, nil
+	}
+
+	if isComparison(task.Operator) {
+		if err := delay(shared.ComparisonDelay); err != nil {
+			return shared.Interval{}, err
+		}
+		return intervalCompare(task.Operator, x, y), nil
+	}
+
+	// Целочисленные операции определены только для точных целых чисел
+	if variable, ok := shared.IntegerDelays[task.Operator]; ok {
+		if err := delay(variable); err != nil {
+			return shared.Interval{}, err
+		}
+		for _, arg := range []shared.Interval{x, y} {
+			if !arg.IsPoint() {
+				return shared.Interval{}, fmt.Errorf("%w: %s", errors.ErrNotInteger, arg)
+			}
+		}
+		result, err := integerOperation(task.Operator, x.Lo, y.Lo)
+		return shared.Interval{Lo: result, Hi: result}, err
+	}
+
+	variable, ok := shared.ArithmeticDelays[task.Operator]
+	if !ok {
+		return shared.Interval{}, fmt.Errorf("%w: %c", errors.ErrUnknownOperator, task.Operator)
+	}
+	if err := delay(variable); err != nil {
+		return shared.Interval{}, err
+	}
+
+	switch task.Operator {
+	case '+':
+		return shared.Interval{Lo: addDown(x.Lo, y.Lo), Hi: addUp(x.Hi, y.Hi)}, nil
+	case '-':
+		return shared.Interval{Lo: addDown(x.Lo, -y.Hi), Hi: addUp(x.Hi, -y.Lo)}, nil
+	case '*':
+		return intervalMul(x, y), nil
+	case '/':
+		return intervalDiv(x, y)
+	}
+	return intervalPow(x, y)
+}
+
+// Операции с округлением вниз и вверх. Ошибка округления суммы находится точно
+// алгоритмом TwoSum, произведения и частного - с помощью FMA: знак ошибки
+// показывает, с какой стороны от результата лежит точное значение
+
+func addDown(a, b float64) float64 { return round(a+b, sumError(a, b), -1) }
+func addUp(a, b float64) float64   { return round(a+b, sumError(a, b), 1) }
+
+func mulDown(a, b float64) float64 { return round(a*b, productError(a, b), -1) }
+func mulUp(a, b float64) float64   { return round(a*b, productError(a, b), 1) }
+
+func divDown(a, b float64) float64 { return round(a/b, quotientError(a, b), -1) }
+func divUp(a, b float64) float64   { return round(a/b, quotientError(a, b), 1) }
+
+// sumError возвращает ошибку округления суммы: a + b = fl(a + b) + e
+func sumError(a, b float64) float64 {
+	s := a + b
+	bb := s - a
+	return (a - (s - bb)) + (b - bb)
+}
+
+// productError возвращает знак ошибки округления произведения. Около нуля FMA теряет
+// точность, поэтому там ошибка считается неизвестной (NaN)
+func productError(a, b float64) float64 {
+	p := a * b
+	if p != 0 && math.Abs(p) < 0x1p-960 || p == 0 && a != 0 && b != 0 {
+		return math.NaN()
+	}
+	return math.FMA(a, b, -p)
+}
+
+// quotientError возвращает знак ошибки округления частного: a / b = q + r / b,
+// где остаток r = a - q * b вычисляется точно
+func quotientError(a, b float64) float64 {
+	q := a / b
+	if q != 0 && math.Abs(q) < 0x1p-960 || q == 0 && a != 0 {
+		return math.NaN()
+	}
+	r := math.FMA(-q, b, a)
+	if b < 0 {
+		return -r
+	}
+	return r
+}
+
+// round округляет результат r в сторону direction (-1 - вниз, 1 - вверх), если точное
+// значение r + e лежит с этой стороны. Неизвестная ошибка (NaN) всегда сдвигает границу
+func round(r, e, direction float64) float64 {
+	if math.IsInf(r, 0) || math.IsNaN(r) {
+		return r
+	}
+	if math.IsNaN(e) || e*direction > 0 {
+		return math.Nextafter(r, math.Inf(int(direction)))
+	}
+	return r
+}
+
+// widen расширяет интервал на n единиц последнего разряда в каждую сторону
+func widen(iv shared.Interval, n int) shared.Interval {
+	for range n {
+		iv.Lo = math.Nextafter(iv.Lo, math.Inf(-1))
+		iv.Hi = math.Nextafter(iv.Hi, math.Inf(1))
+	}
+	return iv
+}
+
+// intervalMul умножает интервалы: границы произведения - наименьшее и наибольшее
+// из произведений границ
+func intervalMul(x, y shared.Interval) shared.Interval {
+	result := shared.Interval{Lo: math.Inf(1), Hi: math.Inf(-1)}
+	for _, a := range []float64{x.Lo, x.Hi} {
+		for _, b := range []float64{y.Lo, y.Hi} {
+			result.Lo = math.Min(result.Lo, mulDown(a, b))
+			result.Hi = math.Max(result.Hi, mulUp(a, b))
+		}
+	}
+	return result
+}
+
+// intervalDiv делит интервалы. Делитель, содержащий ноль, даёт неограниченный результат
+func intervalDiv(x, y shared.Interval) (shared.Interval, error) {
+	if y.Contains(0) {
+		if y.IsPoint() {
+			return shared.Interval{}, fmt.Errorf("на ноль делить нельзя")
+		}
+		return shared.Interval{}, fmt.Errorf("%w: %s", errors.ErrIntervalDivision, y)
+	}
+	result := shared.Interval{Lo: math.Inf(1), Hi: math.Inf(-1)}
+	for _, a := range []float64{x.Lo, x.Hi} {
+		for _, b := range []float64{y.Lo, y.Hi} {
+			result.Lo = math.Min(result.Lo, divDown(a, b))
+			result.Hi = math.Max(result.Hi, divUp(a, b))
+		}
+	}
+	return result, nil
+}
+
+// intervalPow возводит интервал в степень. Целая степень вычисляется умножениями
+// с направленным округлением, дробная определена только для неотрицательного основания
+func intervalPow(x, y shared.Interval) (shared.Interval, error) {
+	if y.IsPoint() && math.Trunc(y.Lo) == y.Lo && math.Abs(y.Lo) <= maxIntervalExponent {
+		n := int64(y.Lo)
+		if n < 0 {
+			return intervalDiv(shared.Interval{Lo: 1, Hi: 1}, integerPow(x, -n))
+		}
+		return integerPow(x, n), nil
+	}
+
+	if x.Lo < 0 {
+		return shared.Interval{}, fmt.Errorf("%s ^ %s: %w", x, y, errors.ErrDomain)
+	}
+	if x.Lo == 0 && y.Lo <= 0 {
+		return shared.Interval{}, fmt.Errorf("%s ^ %s: %w", x, y, errors.ErrDomain)
+	}
+
+	// При положительном основании x ^ y монотонна по каждому аргументу
+	result := shared.Interval{Lo: math.Inf(1), Hi: math.Inf(-1)}
+	for _, a := range []float64{x.Lo, x.Hi} {
+		for _, b := range []float64{y.Lo, y.Hi} {
+			p := math.Pow(a, b)
+			result.Lo, result.Hi = math.Min(result.Lo, p), math.Max(result.Hi, p)
+		}
+	}
+	result = widen(result, libraryULPs)
+	result.Lo = math.Max(result.Lo, 0)
+	return result, nil
+}
+
+// integerPow возводит интервал в неотрицательную целую степень n
+func integerPow(x shared.Interval, n int64) shared.Interval {
+	if n == 0 {
+		return shared.Interval{Lo: 1, Hi: 1}
+	}
+	if n%2 == 1 {
+		// Нечётная степень монотонна: (-a) ^ n = -(a ^ n)
+		return shared.Interval{Lo: oddPow(x.Lo, n, -1), Hi: oddPow(x.Hi, n, 1)}
+	}
+	switch {
+	case x.Lo >= 0:
+		return shared.Interval{Lo: powRound(x.Lo, n, -1), Hi: powRound(x.Hi, n, 1)}
+	case x.Hi <= 0:
+		return shared.Interval{Lo: powRound(-x.Hi, n, -1), Hi: powRound(-x.Lo, n, 1)}
+	}
+	return shared.Interval{Lo: 0, Hi: powRound(math.Max(-x.Lo, x.Hi), n, 1)}
+}
+
+// oddPow возводит число в нечётную степень с округлением в сторону direction
+func oddPow(a float64, n int64, direction float64) float64 {
+	if a < 0 {
+		return -powRound(-a, n, -direction)
+	}
+	return powRound(a, n, direction)
+}
+
+// powRound возводит неотрицательное число в степень n быстрым возведением в степень.
+// Все множители неотрицательны, поэтому округление каждого умножения в одну сторону
+// округляет в ту же сторону и результат
+func powRound(a float64, n int64, direction float64) float64 {
+	mul := mulUp
+	if direction < 0 {
+		mul = mulDown
+	}
+	result := 1.0
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result = mul(result, a)
+		}
+		a = mul(a, a)
+	}
+	return result
+}
+
+// intervalFunction вычисляет функцию одного аргумента над интервалом
+func intervalFunction(name string, x shared.Interval) (shared.Interval, error) {
+	switch name {
+	case "sqrt":
+		if x.Lo < 0 {
+			return shared.Interval{}, fmt.Errorf("sqrt(%s): %w", x, errors.ErrDomain)
+		}
+		return shared.Interval{Lo: sqrtRound(x.Lo, -1), Hi: sqrtRound(x.Hi, 1)}, nil
+	case "abs":
+		switch {
+		case x.Lo >= 0:
+			return x, nil
+		case x.Hi <= 0:
+			return shared.Interval{Lo: -x.Hi, Hi: -x.Lo}, nil
+		}
+		return shared.Interval{Lo: 0, Hi: math.Max(-x.Lo, x.Hi)}, nil
+	case "ln", "log10":
+		if x.Lo <= 0 {
+			return shared.Interval{}, fmt.Errorf("%s(%s): %w", name, x, errors.ErrDomain)
+		}
+		f := math.Log
+		if name == "log10" {
+			f = math.Log10
+		}
+		return widen(shared.Interval{Lo: f(x.Lo), Hi: f(x.Hi)}, libraryULPs), nil
+	case "exp":
+		result := widen(shared.Interval{Lo: math.Exp(x.Lo), Hi: math.Exp(x.Hi)}, libraryULPs)
+		result.Lo = math.Max(result.Lo, 0)
+		return result, nil
+	case "sin":
+		return periodic(math.Sin, x, math.Pi/2, -math.Pi/2), nil
+	case "cos":
+		return periodic(math.Cos, x, 0, math.Pi), nil
+	case "tan":
+		// tan монотонна между полюсами pi/2 + k*pi
+		if containsPoint(x, math.Pi/2, math.Pi) {
+			return shared.Interval{}, fmt.Errorf("tan(%s): %w", x, errors.ErrDomain)
+		}
+		return widen(shared.Interval{Lo: math.Tan(x.Lo), Hi: math.Tan(x.Hi)}, libraryULPs), nil
+	}
+	return shared.Interval{}, fmt.Errorf("%w: %s", errors.ErrUnknownFunction, name)
+}
+
+// sqrtRound извлекает корень с округлением в сторону direction. math.Sqrt округляет
+// правильно, а знак остатка a - s * s показывает, с какой стороны лежит точный корень
+func sqrtRound(a, direction float64) float64 {
+	s := math.Sqrt(a)
+	return round(s, math.FMA(-s, s, a), direction)
+}
+
+// periodic вычисляет функцию с периодом 2pi, у которой максимум 1 в точках maximum + 2pi*k,
+// а минимум -1 в точках minimum + 2pi*k. Если интервал содержит такую точку,
+// соответствующая граница результата равна 1 или -1, иначе функция монотонна на интервале
+func periodic(f func(float64) float64, x shared.Interval, maximum, minimum float64) shared.Interval {
+	a, b := f(x.Lo), f(x.Hi)
+	result := widen(shared.Interval{Lo: math.Min(a, b), Hi: math.Max(a, b)}, libraryULPs)
+	if containsPoint(x, maximum, 2*math.Pi) {
+		result.Hi = 1
+	}
+	if containsPoint(x, minimum, 2*math.Pi) {
+		result.Lo = -1
+	}
+	result.Lo, result.Hi = math.Max(result.Lo, -1), math.Min(result.Hi, 1)
+	return result
+}
+
+// containsPoint проверяет, может ли интервал содержать точку c + k*period. Значение pi
+// приближённое, поэтому интервал проверяется с запасом: лишняя точка только расширит результат
+func containsPoint(x shared.Interval, c, period float64) bool {
+	margin := 1e-9 * math.Max(1, math.Max(math.Abs(x.Lo), math.Abs(x.Hi)))
+	if x.Hi-x.Lo+2*margin >= period {
+		return true
+	}
+	k := math.Ceil((x.Lo - margin - c) / period)
+	return c+k*period <= x.Hi+margin
+}
+
+// intervalCompare сравнивает интервалы. Результат - 1 или 0, если сравнение даёт
+// один ответ для всех точек интервалов, иначе интервал [0, 1]
+func intervalCompare(op rune, x, y shared.Interval) shared.Interval {
+	result := shared.Interval{Lo: 1, Hi: 0}
+	include := func(cmp int) {
+		truth := compare(op, cmp)
+		result.Lo, result.Hi = math.Min(result.Lo, truth), math.Max(result.Hi, truth)
+	}
+	if x.Lo < y.Hi {
+		include(-1)
+	}
+	if x.Hi > y.Lo {
+		include(1)
+	}
+	if x.Lo <= y.Hi && y.Lo <= x.Hi {
+		include(0)
+	}
+	return result
+}
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Маркеры ленивых веток в теле: условие ? then : else ;
+const (
+	branchThen = "?"
+	branchElse = ":"
+	branchEnd  = ";"
+)
+
+// calculateChunk вычисляет участок интеграла (оператор ∫) или ряда (оператор Σ).
+// Тело задачи - выражение в обратной польской записи от переменной task.Variable,
+// агент вычисляет его сам в каждой точке участка. Задержка выдерживается один раз на участок
+func calculateChunk(task shared.Task) (float64, error) {
+	a, err := strconv.ParseFloat(task.FirstArgument, 64)
+	if err != nil {
+		return 0, err
+	}
+	b, err := strconv.ParseFloat(task.SecondArgument, 64)
+	if err != nil {
+		return 0, err
+	}
+	if task.Chunks <= 0 || task.Chunk < 0 || task.Chunk >= task.Chunks || len(task.Program) == 0 {
+		return 0, fmt.Errorf("%w: участок %d из %d", errors.ErrInvalidExpression, task.Chunk, task.Chunks)
+	}
+
+	if err := delay(shared.DelayVariable(task.Operator, task.Function)); err != nil {
+		return 0, err
+	}
+
+	f := func(x float64) (float64, error) {
+		return run(task.Program, task.Variable, x)
+	}
+	if task.Operator == '∫' {
+		return simpson(f, a, b, task.Chunk, task.Chunks)
+	}
+	return series(f, a, b, task.Chunk, task.Chunks)
+}
+
+// simpson интегрирует f по участку chunk из chunks равных участков отрезка [a, b]
+// формулой Симпсона с shared.SimpsonSteps отрезками разбиения
+func simpson(f func(float64) (float64, error), a, b float64, chunk, chunks int) (float64, error) {
+	width := (b - a) / float64(chunks)
+	lo, hi := a+width*float64(chunk), a+width*float64(chunk+1)
+	if chunk == chunks-1 {
+		hi = b
+	}
+
+	n := shared.SimpsonSteps
+	step := (hi - lo) / float64(n)
+	var sum float64
+	for i := 0; i <= n; i++ {
+		x := lo + step*float64(i)
+		if i == n {
+			x = hi
+		}
+		y, err := f(x)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case i == 0 || i == n:
+			sum += y
+		case i%2 == 1:
+			sum += 4 * y
+		default:
+			sum += 2 * y
+		}
+	}
+	return sum * step / 3, nil
+}
+
+// series складывает члены ряда f(k) участка chunk из chunks: номера k от a до b
+// делятся между участками поровну. При b < a ряд пуст и его сумма - 0
+func series(f func(float64) (float64, error), a, b float64, chunk, chunks int) (float64, error) {
+	if a != math.Trunc(a) || b != math.Trunc(b) {
+		return 0, fmt.Errorf("%w: пределы ряда %g и %g", errors.ErrNotInteger, a, b)
+	}
+	if b < a {
+		return 0, nil
+	}
+	if b-a+1 > shared.MaxSeriesTerms {
+		return 0, fmt.Errorf("%w: %g, допустимо не больше %d", errors.ErrTooManyTerms, b-a+1, shared.MaxSeriesTerms)
+	}
+
+	terms := int(b-a) + 1
+	var sum float64
+	for i := terms * chunk / chunks; i < terms*(chunk+1)/chunks; i++ {
+		y, err := f(a + float64(i))
+		if err != nil {
+			return 0, err
+		}
+		sum += y
+	}
+	return sum, nil
+}
+
+// run вычисляет выражение в обратной польской записи при значении x переменной variable.
+// Операторы и функции выполняются так же, как в обычных задачах, но без задержки.
+// Невыбранная ветка условия пропускается целиком, как у оркестратора
+func run(program []string, variable string, x float64) (float64, error) {
+	var stack []float64
+	pop := func(n int) ([]float64, error) {
+		if len(stack) < n || n == 0 {
+			return nil, errors.ErrNotEnoughOperands
+		}
+		args := slices.Clone(stack[len(stack)-n:])
+		stack = stack[:len(stack)-n]
+		return args, nil
+	}
+
+	for i := 0; i < len(program); i++ {
+		token := program[i]
+		if token == variable {
+			stack = append(stack, x)
+			continue
+		}
+
+		switch token {
+		case branchThen:
+			cond, err := pop(1)
+			if err != nil {
+				return 0, err
+			}
+			if cond[0] == 0 {
+				i = skipBranch(program, i, branchElse)
+			}
+			continue
+		case branchElse:
+			// Ветка then вычислена, ветка else не нужна
+			i = skipBranch(program, i, branchEnd)
+			continue
+		case branchEnd:
+			continue
+		}
+
+		if value, err := strconv.ParseFloat(token, 64); err == nil {
+			stack = append(stack, value)
+			continue
+		}
+
+		if name, count, ok := strings.Cut(token, "/"); ok && name != "" {
+			arity, err := strconv.Atoi(count)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %s", errors.ErrInvalidExpression, token)
+			}
+			args, err := pop(arity)
+			if err != nil {
+				return 0, err
+			}
+			value, err := call(name, args)
+			if err != nil {
+				return 0, err
+			}
+			stack = append(stack, value)
+			continue
+		}
+
+		op, size := utf8.DecodeRuneInString(token)
+		if size != len(token) {
+			return 0, fmt.Errorf("%w: %s", errors.ErrInvalidExpression, token)
+		}
+		args, err := pop(2)
+		if err != nil {
+			return 0, err
+		}
+		value, err := evaluate(shared.Task{Operator: op}, args[0], args[1])
+		if err != nil {
+			return 0, err
+		}
+		stack = append(stack, value)
+	}
+
+	if len(stack) != 1 {
+		return 0, errors.ErrInvalidExpression
+	}
+	return stack[0], nil
+}
+
+// skipBranch возвращает позицию маркера marker, закрывающего ветку, которая начинается
+// после позиции start. Маркеры вложенных условий пропускаются
+func skipBranch(program []string, start int, marker string) int {
+	depth := 0
+	for i := start + 1; i < len(program); i++ {
+		switch program[i] {
+		case branchThen:
+			depth++
+		case branchElse:
+			if depth == 0 && marker == branchElse {
+				return i
+			}
+		case branchEnd:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return len(program)
+}
+
+// call вычисляет функцию тела: функции одного аргумента - как в обычных задачах,
+// агрегатные - сразу над всеми аргументами
+func call(name string, args []float64) (float64, error) {
+	if _, ok := shared.UnaryFunctions[name]; ok && len(args) == 1 {
+		return evaluate(shared.Task{Function: name}, args[0], 0)
+	}
+
+	switch name {
+	case "sum", "avg":
+		var sum float64
+		for _, arg := range args {
+			sum += arg
+		}
+		if name == "avg" {
+			return sum / float64(len(args)), nil
+		}
+		return sum, nil
+	case "min":
+		return slices.Min(args), nil
+	case "max":
+		return slices.Max(args), nil
+	case "median":
+		sorted := slices.Clone(args)
+		slices.Sort(sorted)
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[mid-1] + sorted[mid]) / 2, nil
+		}
+		return sorted[mid], nil
+	}
+	return 0, fmt.Errorf("%w: %s", errors.ErrUnknownFunction, name)
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// calculateSamples вычисляет задачу выражения с погрешностями над пачкой выборок:
+// операция выполняется для каждого значения пачки, число вместо пачки одинаково
+// для всех значений. Задержка операции выдерживается один раз на всю пачку
+func calculateSamples(task shared.Task) (shared.Samples, error) {
+	x, err := shared.ParseSamples(task.FirstArgument)
+	if err != nil {
+		return nil, err
+	}
+	y := shared.Samples{0}
+	if _, ok := shared.UnaryFunctions[task.Function]; !ok {
+		if y, err = shared.ParseSamples(task.SecondArgument); err != nil {
+			return nil, err
+		}
+	}
+	if len(x) != 1 && len(y) != 1 && len(x) != len(y) {
+		return nil, fmt.Errorf("%w: пачки из %d и %d значений", errors.ErrInvalidExpression, len(x), len(y))
+	}
+
+	if err := delay(shared.DelayVariable(task.Operator, task.Function)); err != nil {
+		return nil, err
+	}
+
+	result := make(shared.Samples, max(len(x), len(y)))
+	for i := range result {
+		value, err := evaluate(task, x[min(i, len(x)-1)], y[min(i, len(y)-1)])
+		if err != nil {
+			return nil, err
+		}
+		// Бесконечность и NaN оркестратору не отправляются
+		if err := shared.CheckFinite(value); err != nil {
+			return nil, err
+		}
+		result[i] = value
+	}
+	return result, nil
+}
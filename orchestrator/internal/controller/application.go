@@ -16,6 +16,8 @@ import (
 	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/handler"
 	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
 	"github.com/nktauserum/web-calculation/orchestrator/pkg/auth"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/function"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/variable"
 	"github.com/nktauserum/web-calculation/proto"
 	"github.com/nktauserum/web-calculation/proto/pb"
 )
@@ -83,6 +85,20 @@ func (app *Orchestrator) Run() error {
 	authService := auth.NewAuthService(userStorage, app.JWTSecret, app.TokenExpiry)
 	handler.SetAuthService(authService)
 
+	variableStorage, err := variable.NewStorage(app.DBPath)
+	if err != nil {
+		return fmt.Errorf("ошибка инициализации хранилища переменных: %w", err)
+	}
+	defer variableStorage.Close()
+	handler.SetVariableStorage(variableStorage)
+
+	functionStorage, err := function.NewStorage(app.DBPath)
+	if err != nil {
+		return fmt.Errorf("ошибка инициализации хранилища функций: %w", err)
+	}
+	defer functionStorage.Close()
+	handler.SetFunctionStorage(functionStorage)
+
 	authMiddleware := middleware.NewAuthMiddleware(authService)
 
 	// запускаем gRPC сервер
@@ -100,8 +116,21 @@ func (app *Orchestrator) Run() error {
 
 	// Защищенные маршруты (требуют авторизации)
 	router.HandleFunc("/api/v1/calculate", authMiddleware.RequireAuth(handler.CalculationHandler))
+	router.HandleFunc("/api/v1/parse", authMiddleware.RequireAuth(handler.ParseHandler)).Methods("POST")
+	router.HandleFunc("/api/v1/format", authMiddleware.RequireAuth(handler.FormatHandler)).Methods("POST")
+	router.HandleFunc("/api/v1/derive", authMiddleware.RequireAuth(handler.DeriveHandler)).Methods("POST")
+	router.HandleFunc("/api/v1/plot", authMiddleware.RequireAuth(handler.PlotHandler)).Methods("POST")
+	router.HandleFunc("/api/v1/plots/{plotID}", authMiddleware.RequireAuth(handler.PlotByIDHandler)).Methods("GET")
+	router.HandleFunc("/api/v1/plots/{plotID}/svg", authMiddleware.RequireAuth(handler.PlotSVGHandler)).Methods("GET")
 	router.HandleFunc("/api/v1/expressions", authMiddleware.RequireAuth(handler.ExpressionsListHandler))
 	router.HandleFunc("/api/v1/expressions/{expressionID}", authMiddleware.RequireAuth(handler.ExpressionByIDHandler))
+	router.HandleFunc("/api/v1/expressions/{expressionID}/graph", authMiddleware.RequireAuth(handler.ExpressionGraphHandler)).Methods("GET")
+	router.HandleFunc("/api/v1/variables", authMiddleware.RequireAuth(handler.VariablesListHandler)).Methods("GET")
+	router.HandleFunc("/api/v1/variables/{name}", authMiddleware.RequireAuth(handler.SetVariableHandler)).Methods("PUT")
+	router.HandleFunc("/api/v1/variables/{name}", authMiddleware.RequireAuth(handler.DeleteVariableHandler)).Methods("DELETE")
+	router.HandleFunc("/api/v1/functions", authMiddleware.RequireAuth(handler.FunctionsListHandler)).Methods("GET")
+	router.HandleFunc("/api/v1/functions", authMiddleware.RequireAuth(handler.DefineFunctionHandler)).Methods("POST")
+	router.HandleFunc("/api/v1/functions/{name}", authMiddleware.RequireAuth(handler.DeleteFunctionHandler)).Methods("DELETE")
 
 	return http.ListenAndServe(":"+fmt.Sprint(app.Port), router)
 }
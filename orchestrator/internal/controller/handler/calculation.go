@@ -2,12 +2,18 @@ package handler
 
 import (
 	"encoding/json"
+	stderrors "errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
 	"github.com/nktauserum/web-calculation/orchestrator/pkg/service"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/task"
 	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
 )
 
 func CalculationHandler(w http.ResponseWriter, r *http.Request) {
@@ -28,9 +34,26 @@ func CalculationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	options, err := expressionOptions(query)
+	if err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	scope, err := userScope(r.Context().Value(middleware.UserID).(int64))
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
 	queue := service.GetQueue()
-	exprID, err := queue.ParseExpression(r.Context(), query.Expression)
+	exprID, err := queue.ParseExpression(r.Context(), query.Expression, scope, options)
 	if err != nil {
+		var syntaxErr *errors.SyntaxError
+		if stderrors.As(err, &syntaxErr) {
+			HandleSyntaxError(w, r, syntaxErr)
+			return
+		}
 		HandleError(w, r, err, http.StatusInternalServerError)
 		return
 	}
@@ -48,3 +71,70 @@ func CalculationHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	w.Write(data)
 }
+
+// expressionOptions проверяет режим арифметики, точность, способ округления, локаль и число выборок, указанные в запросе.
+// Точность в режиме exact - знаки после запятой, в режиме decimal - значащие цифры
+func expressionOptions(query *shared.ExpressionRequest) (task.Options, error) {
+	mode, err := shared.ParseMode(query.Mode)
+	if err != nil {
+		return task.Options{}, err
+	}
+	locale, err := parser.ParseLocale(query.Locale)
+	if err != nil {
+		return task.Options{}, err
+	}
+	options := task.Options{Mode: mode, Locale: locale}
+
+	if query.Samples != nil {
+		if mode != shared.ModeFloat {
+			return task.Options{}, fmt.Errorf("%w: выборки задаются только в режиме float", errors.ErrInvalidSamples)
+		}
+		if *query.Samples < 1 || *query.Samples > shared.MaxSamples {
+			return task.Options{}, fmt.Errorf("%w: %d, допустимо от 1 до %d", errors.ErrInvalidSamples, *query.Samples, shared.MaxSamples)
+		}
+		options.Samples = *query.Samples
+	}
+
+	if mode == shared.ModeFloat || mode == shared.ModeInterval {
+		if query.Precision != nil || query.Rounding != "" {
+			return task.Options{}, fmt.Errorf("%w: точность задаётся только в режимах exact и decimal", errors.ErrInvalidPrecision)
+		}
+		return options, nil
+	}
+
+	minPrecision := 0
+	options.Precision = shared.DefaultPrecision
+	if mode == shared.ModeDecimal {
+		options.Precision, minPrecision = shared.DefaultDecimalPrecision, 1
+		options.Rounding, err = shared.ParseRounding(query.Rounding)
+		if err != nil {
+			return task.Options{}, err
+		}
+	} else if query.Rounding != "" {
+		return task.Options{}, fmt.Errorf("%w: округление задаётся только в режиме decimal", errors.ErrUnknownRounding)
+	}
+
+	if query.Precision != nil {
+		options.Precision = *query.Precision
+		if options.Precision < minPrecision || options.Precision > shared.MaxPrecision {
+			return task.Options{}, fmt.Errorf("%w: %d, допустимо от %d до %d", errors.ErrInvalidPrecision, options.Precision, minPrecision, shared.MaxPrecision)
+		}
+	}
+
+	return options, nil
+}
+
+// userScope собирает переменные и функции пользователя, доступные в его выражениях
+func userScope(userID int64) (task.Scope, error) {
+	variables, err := variableStorage.Values(userID)
+	if err != nil {
+		return task.Scope{}, err
+	}
+
+	functions, _, err := functionStorage.Latest(userID)
+	if err != nil {
+		return task.Scope{}, err
+	}
+
+	return task.Scope{Variables: variables, Functions: functions}, nil
+}
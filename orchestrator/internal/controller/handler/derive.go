@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/function"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/service"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/symbolic"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/task"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// DeriveHandler находит производную выражения или сценария по переменной. Функции
+// пользователя подставляются, остальные переменные считаются постоянными.
+// Если задана точка at, производная вычисляется в ней обычным выражением
+func DeriveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := new(shared.DeriveRequest)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, query); err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := parser.ValidateName(query.Variable); err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if query.At != nil && (math.IsInf(*query.At, 0) || math.IsNaN(*query.At)) {
+		HandleError(w, r, fmt.Errorf("%w: at", errors.ErrNumberOutOfRange), http.StatusBadRequest)
+		return
+	}
+
+	options, err := expressionOptions(&query.ExpressionRequest)
+	if err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	scope, err := userScope(r.Context().Value(middleware.UserID).(int64))
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	derivative, err := derive(query.Expression, query.Variable, options.Locale, scope)
+	if err != nil {
+		var syntaxErr *errors.SyntaxError
+		if stderrors.As(err, &syntaxErr) {
+			HandleSyntaxError(w, r, syntaxErr)
+			return
+		}
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	resp := shared.DeriveResponse{Derivative: parser.Format(derivative)}
+	status := http.StatusOK
+
+	if query.At != nil {
+		// Производная вычисляется сценарием "x = at; производная": так точка видна
+		// в результатах выражения и закрывает сохранённую переменную с тем же именем
+		script := parser.FormatScript([]parser.Statement{
+			{Name: query.Variable, Value: numberNode(*query.At)},
+			{Value: derivative},
+		})
+		options.Locale = parser.DefaultLocale
+
+		resp.ID, err = service.GetQueue().ParseExpression(r.Context(), script, scope, options)
+		if err != nil {
+			var syntaxErr *errors.SyntaxError
+			if stderrors.As(err, &syntaxErr) {
+				HandleSyntaxError(w, r, syntaxErr)
+				return
+			}
+			HandleError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		status = http.StatusCreated
+	}
+
+	data, err := json.Marshal(&resp)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// derive разбирает выражение, подставляет переменные сценария и функции пользователя
+// и находит производную по variable. Переменная производной и сохранённые переменные
+// после числа - множители, а не единицы: 3t^2 по t - это 6t
+func derive(expression, variable string, locale parser.Locale, scope task.Scope) (parser.Node, error) {
+	statements, err := parser.ParseScript(expression, locale, append(scope.Names(), variable)...)
+	if err != nil {
+		return nil, err
+	}
+	tree, _, err := function.Expand(symbolic.Inline(statements), scope.Functions)
+	if err != nil {
+		return nil, err
+	}
+	return symbolic.Derive(tree, variable)
+}
+
+// numberNode записывает число литералом, отрицательное - с унарным минусом
+func numberNode(value float64) parser.Node {
+	lit := &parser.NumberLit{Text: strconv.FormatFloat(math.Abs(value), 'g', -1, 64), Value: math.Abs(value)}
+	if value < 0 {
+		return &parser.UnaryExpr{Op: '-', Operand: lit}
+	}
+	return lit
+}
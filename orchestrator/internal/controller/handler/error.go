@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
 )
 
 // Функция обрабатывает все ошибки, возвращая их в json-формате и с соответствующим кодом
@@ -26,3 +28,23 @@ func HandleError(w http.ResponseWriter, r *http.Request, err error, statusCode i
 	log.Printf("Error: %s", result.Error)
 	log.Printf("Status code: %d", statusCode)
 }
+
+// Функция возвращает ошибку разбора выражения вместе с её позицией,
+// чтобы клиент мог подсветить проблемное место
+func HandleSyntaxError(w http.ResponseWriter, r *http.Request, err *errors.SyntaxError) {
+	w.Header().Set("Content-Type", "application/json")
+	result := struct {
+		Error string `json:"result"`
+		*errors.SyntaxError
+	}{Error: err.Err.Error(), SyntaxError: err}
+
+	bytes, marshalErr := json.Marshal(&result)
+	if marshalErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("Unexpected error: %s", marshalErr)
+		return
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprint(w, string(bytes))
+	log.Printf("Syntax error: %s", err)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/task"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// FormatHandler записывает выражение или сценарий в каноническом виде:
+// с минимумом скобок и единой записью чисел. По запросу - ещё и формулой LaTeX или MathML
+func FormatHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := new(shared.FormatRequest)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, query); err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	locale, err := parser.ParseLocale(query.Locale)
+	if err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	// Сохранённые переменные пользователя после числа - множители, а не единицы
+	variables, err := variableStorage.Values(r.Context().Value(middleware.UserID).(int64))
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	statements, err := parser.ParseScript(query.Expression, locale, task.Scope{Variables: variables}.Names()...)
+	if err != nil {
+		var syntaxErr *errors.SyntaxError
+		if stderrors.As(err, &syntaxErr) {
+			HandleSyntaxError(w, r, syntaxErr)
+			return
+		}
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	resp := shared.FormatResponse{Canonical: parser.FormatScript(statements)}
+	switch query.Format {
+	case "", "text":
+	case "latex":
+		resp.LaTeX = parser.LaTeXScript(statements)
+	case "mathml":
+		resp.MathML = parser.MathMLScript(statements)
+	default:
+		HandleError(w, r, fmt.Errorf("%w: %q, допустимо text, latex или mathml", errors.ErrUnknownFormat, query.Format), http.StatusBadRequest)
+		return
+	}
+
+	data, err := json.Marshal(&resp)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
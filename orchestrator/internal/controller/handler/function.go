@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/function"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+var functionStorage *function.Storage
+
+func SetFunctionStorage(storage *function.Storage) {
+	functionStorage = storage
+}
+
+func FunctionsListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(middleware.UserID).(int64)
+
+	functions, stale, err := functionStorage.Latest(userID)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if len(functions) == 0 && len(stale) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var result []shared.Function
+	for _, f := range functions {
+		result = append(result, describeFunction(f))
+	}
+	// Устаревшие определения показываются с причиной: их можно переопределить или удалить
+	for _, f := range stale {
+		result = append(result, shared.Function{Name: f.Name, Params: []string{}, Definition: f.Source, Version: f.Version, Error: f.Err.Error()})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	resp, err := json.Marshal(result)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(resp)
+}
+
+func DefineFunctionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(middleware.UserID).(int64)
+
+	var req shared.FunctionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	f, err := function.Parse(req.Definition)
+	if err == nil {
+		var existing map[string]*function.Function
+		existing, _, err = functionStorage.Latest(userID)
+		if err != nil {
+			HandleError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		err = function.Validate(f, existing)
+	}
+	if err != nil {
+		var syntaxErr *errors.SyntaxError
+		if stderrors.As(err, &syntaxErr) {
+			HandleSyntaxError(w, r, syntaxErr)
+			return
+		}
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := functionStorage.Define(userID, f); err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	result := describeFunction(f)
+	resp, err := json.Marshal(&result)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(resp)
+}
+
+func DeleteFunctionHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserID).(int64)
+	name := mux.Vars(r)["name"]
+
+	found, err := functionStorage.Delete(userID, name)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		HandleError(w, r, fmt.Errorf("%w: %q", errors.ErrUnknownFunction, name), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func describeFunction(f *function.Function) shared.Function {
+	return shared.Function{
+		Name:       f.Name,
+		Params:     append([]string{}, f.Params...),
+		Definition: f.Source,
+		Version:    f.Version,
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/task"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// parsedStatement - инструкция сценария: синтаксическое дерево, обратная польская запись
+// после подстановки переменных и функций, операнд с результатом, его единица и форма
+type parsedStatement struct {
+	Name   string      `json:"name,omitempty"`
+	AST    parser.Node `json:"ast"`
+	RPN    []string    `json:"rpn"`
+	Result string      `json:"result"`
+	Unit   string      `json:"unit,omitempty"`
+	Shape  []int       `json:"shape,omitempty"`
+}
+
+// plannedTask - задача, которую создаст выражение
+type plannedTask struct {
+	ID        int64    `json:"id"`
+	Operator  string   `json:"operator"`
+	Function  string   `json:"function,omitempty"`
+	Args      []string `json:"args"`
+	Program   []string `json:"program,omitempty"`  // тело участка интеграла или ряда
+	Variable  string   `json:"variable,omitempty"` // связанная переменная участка
+	DependsOn []int64  `json:"depends_on,omitempty"`
+	Guard     int64    `json:"guard,omitempty"`
+	Branch    string   `json:"branch,omitempty"`
+	FinishMS  float64  `json:"finish_ms"` // самый ранний момент завершения задачи
+}
+
+type parseResponse struct {
+	Statements     []parsedStatement `json:"statements"`
+	Tasks          []plannedTask     `json:"tasks"`
+	Result         string            `json:"result"`
+	CriticalPathMS float64           `json:"critical_path_ms"`
+}
+
+// ParseHandler разбирает выражение или сценарий и показывает задачи, на которые
+// оно разложится, ничего не ставя в очередь. Задачи нумеруются с 1
+func ParseHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := new(shared.ExpressionRequest)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, query); err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	options, err := expressionOptions(query)
+	if err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	scope, err := userScope(r.Context().Value(middleware.UserID).(int64))
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	plan, err := task.PlanExpression(query.Expression, scope, options, 1)
+	if err != nil {
+		var syntaxErr *errors.SyntaxError
+		if stderrors.As(err, &syntaxErr) {
+			HandleSyntaxError(w, r, syntaxErr)
+			return
+		}
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	deps := task.Dependencies(plan.Tasks)
+	finish, total := task.Estimate(plan.Tasks)
+	resp := parseResponse{Result: plan.Result, CriticalPathMS: total, Tasks: []plannedTask{}}
+	for i, statement := range plan.Statements {
+		resp.Statements = append(resp.Statements, parsedStatement{
+			Name:   statement.Name,
+			AST:    statement.Value,
+			RPN:    plan.RPN[i],
+			Result: plan.Results[i],
+			Unit:   plan.Units[i],
+			Shape:  plan.Shapes[i],
+		})
+	}
+	for _, t := range plan.Tasks {
+		args := []string{t.FirstArgument}
+		if t.SecondArgument != "" {
+			args = append(args, t.SecondArgument)
+		}
+		if t.ThirdArgument != "" {
+			args = append(args, t.ThirdArgument)
+		}
+		resp.Tasks = append(resp.Tasks, plannedTask{
+			ID:        t.ID,
+			Operator:  string(t.Operator),
+			Function:  t.Function,
+			Args:      args,
+			Program:   t.Program,
+			Variable:  t.Variable,
+			DependsOn: deps[t.ID],
+			Guard:     t.Guard,
+			Branch:    t.Branch,
+			FinishMS:  finish[t.ID],
+		})
+	}
+
+	data, err := json.Marshal(&resp)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/function"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/variable"
+)
+
+// setupStorage подключает хранилища переменных и функций во временной базе
+func setupStorage(t *testing.T) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "store.db")
+
+	variables, err := variable.NewStorage(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	functions, err := function.NewStorage(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetVariableStorage(variables)
+	SetFunctionStorage(functions)
+}
+
+// request выполняет запрос к обработчику от имени пользователя 1
+func request(handler http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), middleware.UserID, int64(1)))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestParseHandler(t *testing.T) {
+	setupStorage(t)
+	t.Setenv("TIME_ADDITION_MS", "100")
+	t.Setenv("TIME_MULTIPLICATIONS_MS", "200")
+
+	w := request(ParseHandler, http.MethodPost, "/api/v1/parse", `{"expression": "a = 3*4; a + 7"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("статус %d: %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Statements []struct {
+			Name string `json:"name"`
+			AST  struct {
+				Type string `json:"type"`
+				Op   string `json:"op"`
+			} `json:"ast"`
+			RPN    []string `json:"rpn"`
+			Result string   `json:"result"`
+		} `json:"statements"`
+		Tasks []struct {
+			ID        int64    `json:"id"`
+			Args      []string `json:"args"`
+			DependsOn []int64  `json:"depends_on"`
+			FinishMS  float64  `json:"finish_ms"`
+		} `json:"tasks"`
+		Result         string  `json:"result"`
+		CriticalPathMS float64 `json:"critical_path_ms"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Statements) != 2 || resp.Statements[0].Name != "a" || resp.Statements[0].AST.Type != "binary" || resp.Statements[0].AST.Op != "*" {
+		t.Fatalf("инструкции = %+v", resp.Statements)
+	}
+	if got := resp.Statements[1]; !slices.Equal(got.RPN, []string{"id1", "7", "+"}) || got.Result != "id2" {
+		t.Errorf("вторая инструкция = %+v, want id1 7 + = id2", got)
+	}
+	if len(resp.Tasks) != 2 || !slices.Equal(resp.Tasks[1].DependsOn, []int64{1}) || resp.Tasks[1].FinishMS != 300 {
+		t.Errorf("задачи = %+v", resp.Tasks)
+	}
+	if resp.Result != "id2" || resp.CriticalPathMS != 300 {
+		t.Errorf("результат %s за %v мс, want id2 за 300 мс", resp.Result, resp.CriticalPathMS)
+	}
+}
+
+// Ошибка разбора возвращается с позицией, как при вычислении
+func TestParseHandlerSyntaxError(t *testing.T) {
+	setupStorage(t)
+
+	w := request(ParseHandler, http.MethodPost, "/api/v1/parse", `{"expression": "1 +"}`)
+	var resp struct {
+		Pos int `json:"position"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || resp.Pos != 3 {
+		t.Errorf("статус %d, ответ %s, want 400 с позицией 3", w.Code, w.Body)
+	}
+}
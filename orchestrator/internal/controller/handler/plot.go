@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/chart"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/service"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/task"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// PlotHandler ставит в очередь график выражения: значение в каждой точке отрезка
+// вычисляется агентами отдельно. Возвращает ID, по которому график опрашивается
+func PlotHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := new(shared.PlotRequest)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, query); err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	if query.Variable == "" {
+		query.Variable = "x"
+	}
+	if err := parser.ValidateName(query.Variable); err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if math.IsInf(query.From, 0) || math.IsInf(query.To, 0) || !(query.From < query.To) {
+		HandleError(w, r, fmt.Errorf("%w: от %g до %g, начало должно быть меньше конца", errors.ErrInvalidPlotRange, query.From, query.To), http.StatusBadRequest)
+		return
+	}
+	if query.Points == 0 {
+		query.Points = shared.DefaultPlotPoints
+	}
+	if query.Points < 2 || query.Points > shared.MaxPlotPoints {
+		HandleError(w, r, fmt.Errorf("%w: %d, допустимо от 2 до %d", errors.ErrInvalidPlotPoints, query.Points, shared.MaxPlotPoints), http.StatusBadRequest)
+		return
+	}
+
+	locale, err := parser.ParseLocale(query.Locale)
+	if err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	scope, err := userScope(r.Context().Value(middleware.UserID).(int64))
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	options := task.Options{Mode: shared.ModeFloat, Locale: locale}
+	plotID, err := service.GetQueue().ParsePlot(r.Context(), *query, scope, options)
+	if err != nil {
+		var syntaxErr *errors.SyntaxError
+		if stderrors.As(err, &syntaxErr) {
+			HandleSyntaxError(w, r, syntaxErr)
+			return
+		}
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	data, err := json.Marshal(&shared.CalculateResponse{ID: plotID})
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+}
+
+// PlotByIDHandler возвращает график с уже вычисленными точками и долей вычисленных.
+// Построенный график содержит и SVG
+func PlotByIDHandler(w http.ResponseWriter, r *http.Request) {
+	plot, ok := findPlot(w, r)
+	if !ok {
+		return
+	}
+	if plot.Status {
+		plot.SVG = chart.SVG(*plot)
+	}
+
+	resp, err := json.Marshal(plot)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// PlotSVGHandler рисует график в SVG. Пока вычислены не все точки, на нём только известные
+func PlotSVGHandler(w http.ResponseWriter, r *http.Request) {
+	plot, ok := findPlot(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	fmt.Fprint(w, chart.SVG(*plot))
+}
+
+// findPlot находит график из пути запроса и проверяет, что он принадлежит пользователю
+func findPlot(w http.ResponseWriter, r *http.Request) (*shared.Plot, bool) {
+	plotID, err := strconv.ParseInt(mux.Vars(r)["plotID"], 10, 64)
+	if err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	plot := service.GetQueue().FindPlot(plotID)
+	if plot == nil {
+		HandleError(w, r, fmt.Errorf("%w: %d", errors.ErrPlotNotFound, plotID), http.StatusNotFound)
+		return nil, false
+	}
+	if plot.UserID != r.Context().Value(middleware.UserID).(int64) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+	return plot, true
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/service"
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+// TestMain запускает тесты во временном каталоге: service.GetQueue открывает
+// базу очереди sqlite.db в текущем каталоге
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "handler")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// plotRequest запрашивает график plotID от имени пользователя userID
+func plotRequest(handler http.HandlerFunc, plotID int64, userID int64) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/plots/%d", plotID), nil)
+	r = mux.SetURLVars(r, map[string]string{"plotID": strconv.FormatInt(plotID, 10)})
+	r = r.WithContext(context.WithValue(r.Context(), middleware.UserID, userID))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestPlotHandler(t *testing.T) {
+	setupStorage(t)
+
+	w := request(PlotHandler, http.MethodPost, "/api/v1/plot", `{"expression": "x * 2", "from": -1, "to": 1, "points": 3}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("статус %d: %s", w.Code, w.Body)
+	}
+	var created shared.CalculateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	// Агент: задачи графика - умножения абсцисс на 2
+	queue := service.GetQueue()
+	for _, task := range queue.GetTasks() {
+		if task.Status {
+			continue
+		}
+		x, err := strconv.ParseFloat(task.FirstArgument, 64)
+		if err != nil || task.Operator != '*' || task.SecondArgument != "2" {
+			t.Fatalf("задача графика %+v", task)
+		}
+		queue.Done(shared.TaskResult{ID: task.ID, Result: x * 2})
+	}
+	if err := queue.UpdatePlots(); err != nil {
+		t.Fatal(err)
+	}
+
+	w = plotRequest(PlotByIDHandler, created.ID, 1)
+	if w.Code != http.StatusOK {
+		t.Fatalf("статус %d: %s", w.Code, w.Body)
+	}
+	var plot shared.Plot
+	if err := json.Unmarshal(w.Body.Bytes(), &plot); err != nil {
+		t.Fatal(err)
+	}
+	var ys []string
+	for _, point := range plot.Series {
+		ys = append(ys, fmt.Sprintf("%g:%g", point.X, *point.Y))
+	}
+	if !plot.Status || plot.Expression != "x * 2" || strings.Join(ys, " ") != "-1:-2 0:0 1:2" || !strings.HasPrefix(plot.SVG, "<svg") {
+		t.Errorf("график = %+v", plot)
+	}
+
+	w = plotRequest(PlotSVGHandler, created.ID, 1)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/svg+xml" || !strings.HasPrefix(w.Body.String(), "<svg") {
+		t.Errorf("SVG: статус %d, %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	// Чужой график не отдаётся, несуществующий - не найден
+	if w := plotRequest(PlotByIDHandler, created.ID, 2); w.Code != http.StatusUnauthorized {
+		t.Errorf("чужой график: статус %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := plotRequest(PlotSVGHandler, created.ID, 2); w.Code != http.StatusUnauthorized {
+		t.Errorf("SVG чужого графика: статус %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := plotRequest(PlotByIDHandler, created.ID+1, 1); w.Code != http.StatusNotFound {
+		t.Errorf("несуществующий график: статус %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestPlotHandlerErrors(t *testing.T) {
+	setupStorage(t)
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"expression": "x", "from": 1, "to": 1}`, http.StatusBadRequest},
+		{`{"expression": "x", "from": 0, "to": 1, "points": 1}`, http.StatusBadRequest},
+		{`{"expression": "x", "from": 0, "to": 1, "points": 1001}`, http.StatusBadRequest},
+		{`{"expression": "sqrt", "variable": "sqrt", "from": 0, "to": 1}`, http.StatusBadRequest},
+		{`{"expression": "x +", "from": 0, "to": 1}`, http.StatusBadRequest},
+		{`{"expression": "[x, 1]", "from": 0, "to": 1}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		if w := request(PlotHandler, http.MethodPost, "/api/v1/plot", tt.body); w.Code != tt.code {
+			t.Errorf("%s: статус %d, want %d: %s", tt.body, w.Code, tt.code, w.Body)
+		}
+	}
+}
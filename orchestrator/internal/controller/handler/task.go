@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -9,7 +10,9 @@ import (
 
 	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
 	"github.com/nktauserum/web-calculation/orchestrator/pkg/service"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/task"
 	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
 )
 
 func ExpressionsListHandler(w http.ResponseWriter, r *http.Request) {
@@ -77,3 +80,51 @@ func ExpressionByIDHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 	w.Write(resp)
 }
+
+// ExpressionGraphHandler возвращает граф задач выражения с текущим состоянием каждой задачи.
+// Формат задаётся параметром format: json (по умолчанию), dot или mermaid
+func ExpressionGraphHandler(w http.ResponseWriter, r *http.Request) {
+	queue := service.GetQueue()
+
+	expressionID, err := strconv.ParseInt(mux.Vars(r)["expressionID"], 10, 64)
+	if err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	expression := queue.FindExpression(expressionID)
+	if expression == nil {
+		HandleError(w, r, fmt.Errorf("%w: %d", errors.ErrExpressionNotFound, expressionID), http.StatusNotFound)
+		return
+	}
+	if expression.UserID != r.Context().Value(middleware.UserID).(int64) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	tasks, err := queue.ExpressionTasks(expressionID)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	graph := task.BuildGraph(*expression, tasks)
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		resp, err := json.Marshal(&graph)
+		if err != nil {
+			HandleError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		fmt.Fprint(w, graph.DOT())
+	case "mermaid":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, graph.Mermaid())
+	default:
+		HandleError(w, r, fmt.Errorf("%w: %q, допустимо json, dot или mermaid", errors.ErrUnknownFormat, format), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/variable"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+var variableStorage *variable.Storage
+
+func SetVariableStorage(storage *variable.Storage) {
+	variableStorage = storage
+}
+
+func VariablesListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(middleware.UserID).(int64)
+
+	variables, err := variableStorage.List(userID)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if len(variables) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp, err := json.Marshal(variables)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(resp)
+}
+
+func SetVariableHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(middleware.UserID).(int64)
+	name := mux.Vars(r)["name"]
+
+	if err := parser.ValidateName(name); err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	var req shared.VariableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		HandleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if req.Value == nil {
+		HandleError(w, r, fmt.Errorf("не указано значение переменной"), http.StatusBadRequest)
+		return
+	}
+
+	result := shared.Variable{Name: name, Value: *req.Value}
+	if err := variableStorage.Set(userID, result); err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(&result)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(resp)
+}
+
+func DeleteVariableHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserID).(int64)
+	name := mux.Vars(r)["name"]
+
+	found, err := variableStorage.Delete(userID, name)
+	if err != nil {
+		HandleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		HandleError(w, r, fmt.Errorf("%w: %q", errors.ErrUndefinedVariable, name), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,149 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+// Размеры графика и отступы области построения от краёв, в пикселях
+const (
+	width  = 640
+	height = 400
+	left   = 64
+	right  = 16
+	top    = 32
+	bottom = 48
+	ticks  = 6 // желаемое число делений на оси
+)
+
+// axis - отрезок значений, который отображается на отрезок пикселей [from, to]
+type axis struct {
+	lo, hi   float64
+	from, to float64
+}
+
+func (a axis) scale(v float64) float64 {
+	return a.from + (v-a.lo)/(a.hi-a.lo)*(a.to-a.from)
+}
+
+// SVG рисует график линией по точкам серии. Точки без значения разрывают линию,
+// а одиночная точка между разрывами рисуется кружком. Подписи осей - переменная
+// и единица значений, заголовок - выражение
+func SVG(plot shared.Plot) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, point := range plot.Series {
+		if point.Y != nil {
+			lo, hi = math.Min(lo, *point.Y), math.Max(hi, *point.Y)
+		}
+	}
+	if lo > hi {
+		lo, hi = -1, 1
+	}
+	if lo == hi {
+		pad := math.Max(math.Abs(lo)/10, 1)
+		lo, hi = lo-pad, hi+pad
+	}
+
+	x := axis{lo: plot.From, hi: plot.To, from: left, to: width - right}
+	y := axis{lo: lo, hi: hi, from: height - bottom, to: top}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`, width, height, width, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`, width, height)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" font-size="13">%s</text>`, width/2, top-12, html.EscapeString(plot.Expression))
+
+	// Сетка с подписями делений. Оси проводятся через ноль, если он на графике
+	for _, v := range niceTicks(x.lo, x.hi) {
+		px := x.scale(v)
+		fmt.Fprintf(&b, `<line x1="%s" y1="%d" x2="%s" y2="%d" stroke="#e5e5e5"/>`, coord(px), top, coord(px), height-bottom)
+		fmt.Fprintf(&b, `<text x="%s" y="%d" text-anchor="middle">%s</text>`, coord(px), height-bottom+16, tickLabel(v))
+	}
+	for _, v := range niceTicks(y.lo, y.hi) {
+		py := y.scale(v)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%s" x2="%d" y2="%s" stroke="#e5e5e5"/>`, left, coord(py), width-right, coord(py))
+		fmt.Fprintf(&b, `<text x="%d" y="%s" text-anchor="end" dominant-baseline="middle">%s</text>`, left-6, coord(py), tickLabel(v))
+	}
+	if x.lo <= 0 && 0 <= x.hi {
+		fmt.Fprintf(&b, `<line x1="%s" y1="%d" x2="%s" y2="%d" stroke="#888"/>`, coord(x.scale(0)), top, coord(x.scale(0)), height-bottom)
+	}
+	if y.lo <= 0 && 0 <= y.hi {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%s" x2="%d" y2="%s" stroke="#888"/>`, left, coord(y.scale(0)), width-right, coord(y.scale(0)))
+	}
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#888"/>`, left, top, width-left-right, height-top-bottom)
+
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%s</text>`, (left+width-right)/2, height-10, html.EscapeString(plot.Variable))
+	if plot.Unit != "" {
+		fmt.Fprintf(&b, `<text x="14" y="%d" text-anchor="middle" transform="rotate(-90 14 %d)">%s</text>`, (top+height-bottom)/2, (top+height-bottom)/2, html.EscapeString(plot.Unit))
+	}
+
+	for _, segment := range segments(plot.Series) {
+		if len(segment) == 1 {
+			fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="2" fill="#1f77b4"/>`, coord(x.scale(segment[0].X)), coord(y.scale(*segment[0].Y)))
+			continue
+		}
+		path := make([]string, len(segment))
+		for i, point := range segment {
+			path[i] = coord(x.scale(point.X)) + "," + coord(y.scale(*point.Y))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="#1f77b4" stroke-width="1.5" stroke-linejoin="round"/>`, strings.Join(path, " "))
+	}
+
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// segments делит серию на участки подряд идущих точек со значениями
+func segments(series []shared.PlotPoint) [][]shared.PlotPoint {
+	var result [][]shared.PlotPoint
+	start := -1
+	for i, point := range series {
+		if point.Y != nil && start < 0 {
+			start = i
+		}
+		if point.Y == nil && start >= 0 {
+			result = append(result, series[start:i])
+			start = -1
+		}
+	}
+	if start >= 0 {
+		result = append(result, series[start:])
+	}
+	return result
+}
+
+// niceTicks выбирает деления на отрезке [lo, hi] с шагом 1, 2 или 5, умноженным на степень десяти
+func niceTicks(lo, hi float64) []float64 {
+	raw := (hi - lo) / ticks
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := magnitude
+	for _, factor := range []float64{2, 5, 10} {
+		if raw <= step {
+			break
+		}
+		step = factor * magnitude
+	}
+
+	var result []float64
+	for i := math.Ceil(lo / step); i*step <= hi; i++ {
+		result = append(result, i*step)
+	}
+	return result
+}
+
+// tickLabel записывает значение деления без хвостов округления: 0.30000000000000004 - "0.3"
+func tickLabel(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// coord записывает координату в пикселях с двумя знаками после запятой
+func coord(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
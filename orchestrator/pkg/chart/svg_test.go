@@ -0,0 +1,92 @@
+package chart
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+func value(y float64) *float64 {
+	return &y
+}
+
+func TestSegments(t *testing.T) {
+	series := []shared.PlotPoint{
+		{X: 0, Y: value(1)},
+		{X: 1, Y: value(2)},
+		{X: 2},
+		{X: 3, Y: value(3)},
+		{X: 4},
+		{X: 5, Y: value(4)},
+		{X: 6, Y: value(5)},
+	}
+
+	var lengths []int
+	for _, segment := range segments(series) {
+		lengths = append(lengths, len(segment))
+	}
+	if !slices.Equal(lengths, []int{2, 1, 2}) {
+		t.Errorf("segments = %v участков по точкам, want [2 1 2]", lengths)
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	tests := []struct {
+		lo, hi float64
+		want   []float64
+	}{
+		{0, 10, []float64{0, 2, 4, 6, 8, 10}},
+		{-1, 1, []float64{-1, -0.5, 0, 0.5, 1}},
+		{3, 97, []float64{20, 40, 60, 80}},
+	}
+
+	for _, tt := range tests {
+		if got := niceTicks(tt.lo, tt.hi); !slices.Equal(got, tt.want) {
+			t.Errorf("niceTicks(%g, %g) = %v, want %v", tt.lo, tt.hi, got, tt.want)
+		}
+	}
+	if got := tickLabel(0.30000000000000004); got != "0.3" {
+		t.Errorf("tickLabel(0.30000000000000004) = %q, want 0.3", got)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	plot := shared.Plot{
+		Expression: "x < 1 ? x : 1 / x",
+		Variable:   "x",
+		From:       0,
+		To:         4,
+		Unit:       "m",
+		Series: []shared.PlotPoint{
+			{X: 0, Y: value(0)},
+			{X: 1, Y: value(1)},
+			{X: 2},
+			{X: 3, Y: value(0.5)},
+			{X: 4},
+		},
+	}
+
+	svg := SVG(plot)
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="640" height="400"`,
+		`>x &lt; 1 ? x : 1 / x</text>`,
+		`transform="rotate(-90 14 192)">m</text>`,
+		`<polyline points="64.00,352.00 204.00,32.00"`,
+		`<circle cx="484.00" cy="192.00" r="2"`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG не содержит %q:\n%s", want, svg)
+		}
+	}
+	if !strings.HasSuffix(svg, "</svg>") || strings.Count(svg, "<polyline") != 1 {
+		t.Errorf("SVG: ожидалась одна линия и закрытый тег:\n%s", svg)
+	}
+
+	// График без значений рисуется с осями по умолчанию
+	empty := SVG(shared.Plot{Variable: "x", From: 0, To: 1, Series: []shared.PlotPoint{{X: 0}, {X: 1}}})
+	if strings.Contains(empty, "<polyline") || strings.Contains(empty, "<circle") || strings.Contains(empty, "NaN") {
+		t.Errorf("пустой график:\n%s", empty)
+	}
+}
@@ -0,0 +1,191 @@
+package function
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Expand проверяет вызовы функций в дереве и подставляет на место вызовов
+// функций пользователя их тела. Возвращает новое дерево и версии использованных
+// определений: выражение вычисляется с этими версиями, даже если функции потом изменятся
+func Expand(node parser.Node, functions map[string]*Function) (parser.Node, map[string]int, error) {
+	e := &expander{functions: functions, used: make(map[string]int)}
+	result, err := e.expand(node, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parser.Size(result, maxNodes) > maxNodes {
+		return nil, nil, &errors.SyntaxError{Pos: node.Pos(), Expected: fmt.Sprintf("не больше %d узлов дерева", maxNodes), Err: errors.ErrExpressionTooLarge}
+	}
+	return result, e.used, nil
+}
+
+// Сколько раз можно подставить тело функции в одно выражение: ограничивает работу
+// самой подстановки, если функции вызывают друг друга много раз
+const maxExpansions = 1000
+
+// Наибольшее число узлов в дереве после подстановки. Аргументы копируются в тело
+// при каждом использовании параметра, поэтому даже несколько вложенных вызовов
+// g(x) = x*x*x*x дают дерево, размер которого растёт экспоненциально
+const maxNodes = 10000
+
+type expander struct {
+	functions  map[string]*Function
+	used       map[string]int
+	expansions int
+}
+
+// expand обходит дерево. params - значения параметров функции, тело которой
+// сейчас подставляется, stack - цепочка раскрываемых функций для поиска рекурсии
+func (e *expander) expand(node parser.Node, params map[string]parser.Node, stack []string) (parser.Node, error) {
+	switch n := node.(type) {
+	case *parser.Variable:
+		if value, ok := params[n.Name]; ok {
+			return value, nil
+		}
+		return n, nil
+	case *parser.UnaryExpr:
+		operand, err := e.expand(n.Operand, params, stack)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.UnaryExpr{Position: n.Position, Op: n.Op, Operand: operand}, nil
+	case *parser.BinaryExpr:
+		left, err := e.expand(n.Left, params, stack)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.expand(n.Right, params, stack)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.BinaryExpr{Position: n.Position, Op: n.Op, Left: left, Right: right}, nil
+	case *parser.CallExpr:
+		// Связанная переменная интеграла и ряда не подставляется и закрывает в теле одноимённый параметр
+		it, iteration := parser.IterationOf(n)
+		args := make([]parser.Node, len(n.Args))
+		for i, arg := range n.Args {
+			scope := params
+			if iteration && i == it.Index {
+				args[i] = arg
+				continue
+			}
+			if iteration && i == it.Body {
+				scope = maps.Clone(params)
+				delete(scope, it.Variable)
+			}
+			expanded, err := e.expand(arg, scope, stack)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = expanded
+		}
+		return e.call(n, args, stack)
+	case *parser.Conditional:
+		parts := make([]parser.Node, 3)
+		for i, part := range []parser.Node{n.Cond, n.Then, n.Else} {
+			expanded, err := e.expand(part, params, stack)
+			if err != nil {
+				return nil, err
+			}
+			parts[i] = expanded
+		}
+		return &parser.Conditional{Position: n.Position, Cond: parts[0], Then: parts[1], Else: parts[2]}, nil
+	case *parser.Conversion:
+		value, err := e.expand(n.Value, params, stack)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.Conversion{Position: n.Position, Value: value, Unit: n.Unit}, nil
+	case *parser.VectorLit:
+		elements := make([]parser.Node, len(n.Elements))
+		for i, element := range n.Elements {
+			expanded, err := e.expand(element, params, stack)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = expanded
+		}
+		return &parser.VectorLit{Position: n.Position, Elements: elements}, nil
+	}
+	return node, nil
+}
+
+// call проверяет вызов функции и, если она пользовательская, подставляет её тело
+func (e *expander) call(n *parser.CallExpr, args []parser.Node, stack []string) (parser.Node, error) {
+	if _, ok := shared.UnaryFunctions[n.Name]; ok {
+		if len(args) != 1 {
+			return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: "1 аргумент", Err: errors.ErrArgumentCount}
+		}
+		return &parser.CallExpr{Position: n.Position, Name: n.Name, Args: args}, nil
+	}
+
+	if shared.MatrixFunctions[n.Name] {
+		if len(args) != 1 {
+			return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: "1 аргумент", Err: errors.ErrArgumentCount}
+		}
+		return &parser.CallExpr{Position: n.Position, Name: n.Name, Args: args}, nil
+	}
+
+	if n.Name == "integrate" {
+		if len(args) != 4 {
+			return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: "4 аргумента: integrate(f, x, a, b)", Err: errors.ErrArgumentCount}
+		}
+		if _, ok := args[1].(*parser.Variable); !ok {
+			return nil, &errors.SyntaxError{Pos: args[1].Pos(), Token: parser.Format(args[1]), Expected: "переменная интегрирования", Err: errors.ErrUnexpectedToken}
+		}
+		return &parser.CallExpr{Position: n.Position, Name: n.Name, Args: args}, nil
+	}
+
+	if shared.AggregateFunctions[n.Name] {
+		if len(args) == 0 {
+			return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: "хотя бы 1 аргумент", Err: errors.ErrArgumentCount}
+		}
+		return &parser.CallExpr{Position: n.Position, Name: n.Name, Args: args}, nil
+	}
+
+	function, ok := e.functions[n.Name]
+	if !ok {
+		return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: "имя функции", Err: errors.ErrUnknownFunction}
+	}
+
+	if len(args) != len(function.Params) {
+		return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: fmt.Sprintf("аргументов: %d", len(function.Params)), Err: errors.ErrArgumentCount}
+	}
+
+	for _, name := range stack {
+		if name == n.Name {
+			return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Err: errors.ErrRecursion}
+		}
+	}
+
+	bound := make(map[string]parser.Node, len(args))
+	for i, param := range function.Params {
+		bound[param] = args[i]
+	}
+
+	e.expansions++
+	if e.expansions > maxExpansions {
+		return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Err: errors.ErrExpressionTooLarge}
+	}
+
+	body, err := e.expand(function.Body, bound, append(stack, n.Name))
+	if err != nil {
+		// Позиции внутри тела относятся к определению функции, а не к выражению,
+		// поэтому ошибку показываем на месте вызова
+		return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Err: fmt.Errorf("в теле функции %s: %w", n.Name, err)}
+	}
+
+	// Дерево проверяется сразу после подстановки: иначе следующие вызовы
+	// успели бы размножить его ещё сильнее
+	if parser.Size(body, maxNodes) > maxNodes {
+		return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: fmt.Sprintf("не больше %d узлов дерева", maxNodes), Err: errors.ErrExpressionTooLarge}
+	}
+
+	e.used[n.Name] = function.Version
+	return body, nil
+}
@@ -0,0 +1,110 @@
+package function
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Function - определение функции пользователя определённой версии
+type Function struct {
+	*parser.Definition
+	Source  string // определение в том виде, в каком его прислал пользователь
+	Version int
+}
+
+// Parse разбирает определение функции и проверяет его самостоятельно,
+// без учёта других функций пользователя
+func Parse(source string) (*Function, error) {
+	def, err := parser.ParseDefinition(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := parser.ValidateName(def.Name); err != nil {
+		return nil, err
+	}
+	for _, param := range def.Params {
+		if err := parser.ValidateName(param); err != nil {
+			return nil, err
+		}
+	}
+
+	// В теле можно использовать только параметры: значение функции
+	// не должно зависеть от переменных пользователя
+	if err := checkFreeVariables(def.Body, def.Params); err != nil {
+		return nil, err
+	}
+
+	return &Function{Definition: def, Source: source}, nil
+}
+
+// Validate проверяет новое определение вместе с уже существующими функциями:
+// все вызываемые функции должны существовать, а вызовы - не образовывать цикл
+func Validate(function *Function, existing map[string]*Function) error {
+	functions := make(map[string]*Function, len(existing)+1)
+	for name, f := range existing {
+		functions[name] = f
+	}
+	functions[function.Name] = function
+
+	// Раскрываем вызов новой функции с параметрами в качестве аргументов
+	call := &parser.CallExpr{Name: function.Name}
+	for _, param := range function.Params {
+		call.Args = append(call.Args, &parser.Variable{Name: param})
+	}
+	_, _, err := Expand(call, functions)
+	return err
+}
+
+// checkFreeVariables ищет в теле функции переменные, не являющиеся параметрами
+func checkFreeVariables(node parser.Node, params []string) error {
+	switch n := node.(type) {
+	case *parser.Variable:
+		for _, param := range params {
+			if n.Name == param {
+				return nil
+			}
+		}
+		return &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: fmt.Sprintf("один из параметров %v", params), Err: errors.ErrUndefinedVariable}
+	case *parser.UnaryExpr:
+		return checkFreeVariables(n.Operand, params)
+	case *parser.BinaryExpr:
+		if err := checkFreeVariables(n.Left, params); err != nil {
+			return err
+		}
+		return checkFreeVariables(n.Right, params)
+	case *parser.CallExpr:
+		// В теле интеграла и ряда доступна ещё и связанная переменная
+		it, iteration := parser.IterationOf(n)
+		for i, arg := range n.Args {
+			scope := params
+			if iteration && i == it.Index {
+				continue
+			}
+			if iteration && i == it.Body {
+				scope = append(slices.Clip(params), it.Variable)
+			}
+			if err := checkFreeVariables(arg, scope); err != nil {
+				return err
+			}
+		}
+	case *parser.Conditional:
+		for _, part := range []parser.Node{n.Cond, n.Then, n.Else} {
+			if err := checkFreeVariables(part, params); err != nil {
+				return err
+			}
+		}
+	case *parser.Conversion:
+		return checkFreeVariables(n.Value, params)
+	case *parser.VectorLit:
+		for _, element := range n.Elements {
+			if err := checkFreeVariables(element, params); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
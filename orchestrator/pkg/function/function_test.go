@@ -0,0 +1,167 @@
+package function
+
+import (
+	stderrors "errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// define разбирает определения функций для теста
+func define(t *testing.T, sources ...string) map[string]*Function {
+	t.Helper()
+	functions := make(map[string]*Function)
+	for _, source := range sources {
+		f, err := Parse(source)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", source, err)
+		}
+		functions[f.Name] = f
+	}
+	return functions
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    error
+	}{
+		{"f(x) = x + y", errors.ErrUndefinedVariable},
+		{"sqrt(x) = x", errors.ErrReservedName},
+		{"f(id1) = id1", errors.ErrReservedName},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse(tt.source); !stderrors.Is(err, tt.err) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.source, err, tt.err)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	functions := define(t, "sq(x) = x * x", "hyp(a, b) = sqrt(sq(a) + sq(b))", "area(r) = integrate(r * t, t, 0, 1)")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"sq(3) + 1", "3 * 3 + 1"},
+		{"hyp(3, 4)", "sqrt(3 * 3 + 4 * 4)"},
+		{"sq(sq(2))", "2 * 2 * (2 * 2)"},
+		{"area(2)", "integrate(2 * t, t, 0, 1)"},
+	}
+
+	for _, tt := range tests {
+		node, err := parser.Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.input, err)
+		}
+		expanded, used, err := Expand(node, functions)
+		if err != nil {
+			t.Errorf("Expand(%q): %v", tt.input, err)
+			continue
+		}
+		if got := parser.Format(expanded); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+		if used["sq"] != 0 {
+			t.Errorf("Expand(%q): версия sq = %d, want 0 у неопубликованной функции", tt.input, used["sq"])
+		}
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	functions := define(t, "g(x) = x * x * x * x", "f(x) = h(x) + 1", "h(x) = f(x)")
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"g(g(g(g(g(g(g(g(1))))))))", errors.ErrExpressionTooLarge},
+		{"f(1)", errors.ErrRecursion},
+		{"g(1, 2)", errors.ErrArgumentCount},
+		{"sqrt(1, 2)", errors.ErrArgumentCount},
+		{"nope(1)", errors.ErrUnknownFunction},
+	}
+
+	for _, tt := range tests {
+		node, err := parser.Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.input, err)
+		}
+		if _, _, err := Expand(node, functions); !stderrors.Is(err, tt.err) {
+			t.Errorf("Expand(%q) = %v, want %v", tt.input, err, tt.err)
+		}
+	}
+}
+
+// newStorage открывает хранилище функций во временной базе
+func newStorage(t *testing.T) *Storage {
+	t.Helper()
+	storage, err := NewStorage(filepath.Join(t.TempDir(), "functions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+// Номер версии растёт и после удаления функции: выражения, вычисленные
+// с прежними версиями, нельзя спутать с новыми
+func TestStorageVersions(t *testing.T) {
+	storage := newStorage(t)
+	for i, source := range []string{"f(x) = x + 1", "f(x) = x + 2", "", "f(x) = x + 3"} {
+		if source == "" {
+			if deleted, err := storage.Delete(1, "f"); err != nil || !deleted {
+				t.Fatalf("Delete(f) = %v, %v, want true", deleted, err)
+			}
+			functions, _, err := storage.Latest(1)
+			if err != nil || len(functions) != 0 {
+				t.Fatalf("Latest после удаления = %v, %v, want пусто", functions, err)
+			}
+			continue
+		}
+
+		f := define(t, source)["f"]
+		if err := storage.Define(1, f); err != nil {
+			t.Fatal(err)
+		}
+		if want := min(i, 2) + 1; f.Version != want {
+			t.Errorf("Define(%q): версия %d, want %d", source, f.Version, want)
+		}
+	}
+
+	functions, _, err := storage.Latest(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := functions["f"]; f == nil || f.Version != 3 || f.Source != "f(x) = x + 3" {
+		t.Errorf("Latest = %+v, want f версии 3", f)
+	}
+	if deleted, _ := storage.Delete(1, "nope"); deleted {
+		t.Errorf("Delete(nope) = true, want false")
+	}
+}
+
+// Определение, которое больше не разбирается, не мешает загрузить остальные функции
+func TestStorageLatestSkipsStale(t *testing.T) {
+	storage := newStorage(t)
+	f := define(t, "sq(x) = x * x")["sq"]
+	if err := storage.Define(1, f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.db.Exec("INSERT INTO functions (user_id, name, version, definition) VALUES (1, 'sin', 1, 'sin(x) = x')"); err != nil {
+		t.Fatal(err)
+	}
+
+	functions, stale, err := storage.Latest(1)
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	if len(functions) != 1 || functions["sq"] == nil || functions["sq"].Version != 1 {
+		t.Errorf("Latest: функции %v, want только sq версии 1", functions)
+	}
+	if len(stale) != 1 || stale[0].Name != "sin" || !stderrors.Is(stale[0].Err, errors.ErrReservedName) {
+		t.Errorf("Latest: устаревшие %+v, want sin с ошибкой %v", stale, errors.ErrReservedName)
+	}
+}
@@ -0,0 +1,130 @@
+package function
+
+import (
+	"database/sql"
+	"log"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Storage хранит все версии функций пользователей
+type Storage struct {
+	db *sql.DB
+}
+
+func NewStorage(dbPath string) (*Storage, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Создаем таблицу функций, если она не существует. Каждое переопределение
+	// функции добавляет новую версию, старые версии не удаляются. Удалённая функция
+	// только помечается, чтобы номера версий при новом определении продолжались
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS functions (
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			definition TEXT NOT NULL,
+			deleted BOOLEAN NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, name, version),
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// Define сохраняет новую версию функции и записывает её номер в function.Version.
+// Версии удалённых определений тоже учитываются: номер версии никогда не повторяется
+func (s *Storage) Define(userID int64, function *Function) error {
+	var version int
+	err := s.db.QueryRow(
+		"SELECT COALESCE(MAX(version), 0) + 1 FROM functions WHERE user_id = ? AND name = ?",
+		userID, function.Name,
+	).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO functions (user_id, name, version, definition) VALUES (?, ?, ?, ?)",
+		userID, function.Name, version, function.Source,
+	)
+	if err != nil {
+		return err
+	}
+
+	function.Version = version
+	return nil
+}
+
+// Stale - сохранённое определение, которое больше не разбирается: например, имя функции
+// или её параметра зарезервировали уже после того, как функция была определена
+type Stale struct {
+	Name    string
+	Source  string
+	Version int
+	Err     error
+}
+
+// Latest возвращает последние версии всех функций пользователя по их именам.
+// Устаревшие определения не мешают остальным: они пропускаются и возвращаются отдельно
+func (s *Storage) Latest(userID int64) (map[string]*Function, []Stale, error) {
+	rows, err := s.db.Query(`
+		SELECT f.name, f.version, f.definition FROM functions f
+		WHERE f.user_id = ? AND NOT f.deleted AND f.version = (
+			SELECT MAX(version) FROM functions WHERE user_id = f.user_id AND name = f.name
+		)
+		ORDER BY f.name`,
+		userID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	functions := make(map[string]*Function)
+	var stale []Stale
+	for rows.Next() {
+		var name, source string
+		var version int
+		if err := rows.Scan(&name, &version, &source); err != nil {
+			return nil, nil, err
+		}
+
+		function, err := Parse(source)
+		if err != nil {
+			log.Printf("Функция %s пользователя %d пропущена: %v", name, userID, err)
+			stale = append(stale, Stale{Name: name, Source: source, Version: version, Err: err})
+			continue
+		}
+		function.Version = version
+		functions[function.Name] = function
+	}
+
+	return functions, stale, rows.Err()
+}
+
+// Delete помечает все версии функции удалёнными. Возвращает false, если такой функции не было
+func (s *Storage) Delete(userID int64, name string) (bool, error) {
+	result, err := s.db.Exec(
+		"UPDATE functions SET deleted = 1 WHERE user_id = ? AND name = ? AND NOT deleted",
+		userID, name,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,161 @@
+package parser
+
+import "github.com/nktauserum/web-calculation/orchestrator/pkg/unit"
+
+// Node - узел синтаксического дерева выражения
+type Node interface {
+	// Pos возвращает смещение начала узла в исходном выражении
+	Pos() int
+}
+
+// NumberLit - числовой литерал
+type NumberLit struct {
+	Position int
+	Text     string // литерал в том виде, в каком он записан в выражении
+	Value    float64
+}
+
+// ImaginaryLit - мнимый литерал: 2i, 1.5i или сама мнимая единица i
+type ImaginaryLit struct {
+	Position int
+	Text     string
+	Value    float64 // коэффициент при i
+}
+
+// VectorLit - вектор [a, b, c]. Элементы - любые выражения; вектор из векторов
+// одной длины - матрица по строкам: [[1, 2], [3, 4]]. В режиме interval пара чисел
+// [lo, hi] - интервал
+type VectorLit struct {
+	Position int
+	Elements []Node
+}
+
+// UncertainLit - величина с погрешностью 5 ± 0.1: нормальное распределение со средним Mean
+// и стандартным отклонением Sigma. Оба - числовые литералы, среднее может быть со знаком минус
+type UncertainLit struct {
+	Position    int // позиция знака ±
+	Mean, Sigma Node
+}
+
+// QuantityLit - величина с единицей измерения: 3 km, 9.8 m/s^2. Единица записывается
+// сразу после числового литерала
+type QuantityLit struct {
+	Position int
+	Value    *NumberLit
+	Unit     unit.Expr
+}
+
+// Variable - ссылка на переменную пользователя
+type Variable struct {
+	Position int
+	Name     string
+}
+
+// UnaryExpr - унарная операция: -x, +x, not x или постфиксный процент x%
+type UnaryExpr struct {
+	Position int
+	Op       rune
+	Operand  Node
+}
+
+// BinaryExpr - бинарная операция: x + y, x * y, x ^ y и т.д.
+type BinaryExpr struct {
+	Position int // позиция оператора
+	Op       rune
+	Left     Node
+	Right    Node
+}
+
+// Conditional - условие if(cond, a, b) или cond ? a : b. Вычисляется лениво:
+// задачи ветки ставятся в очередь только после того, как вычислено условие
+type Conditional struct {
+	Position int
+	Cond     Node
+	Then     Node
+	Else     Node
+}
+
+// Conversion - перевод величины в другие единицы: x to km/h. Значение не меняется,
+// меняются единицы, в которых записывается результат
+type Conversion struct {
+	Position int // позиция to
+	Value    Node
+	Unit     unit.Expr
+}
+
+// CallExpr - вызов функции: sqrt(x), avg(x, y, z)
+type CallExpr struct {
+	Position int
+	Name     string
+	Args     []Node
+}
+
+// Iteration - номера аргументов интеграла integrate(f, x, a, b) или ряда sum(k, a, b, f).
+// Тело вычисляется для значений связанной переменной от From до To. Переменная видна
+// только в теле и закрывает одноимённые переменные снаружи
+type Iteration struct {
+	Variable string // имя связанной переменной
+	Index    int    // аргумент с переменной
+	Body     int
+	From, To int
+}
+
+// IterationOf распознаёт интеграл или ряд. sum из четырёх аргументов, первый
+// из которых - имя переменной, - ряд, а не сумма четырёх значений
+func IterationOf(n *CallExpr) (Iteration, bool) {
+	if len(n.Args) != 4 {
+		return Iteration{}, false
+	}
+	it := Iteration{Index: 0, Body: 3, From: 1, To: 2}
+	if n.Name == "integrate" {
+		it = Iteration{Index: 1, Body: 0, From: 2, To: 3}
+	} else if n.Name != "sum" {
+		return Iteration{}, false
+	}
+	v, ok := n.Args[it.Index].(*Variable)
+	if !ok {
+		return Iteration{}, false
+	}
+	it.Variable = v.Name
+	return it, true
+}
+
+func (n *NumberLit) Pos() int    { return n.Position }
+func (n *ImaginaryLit) Pos() int { return n.Position }
+func (n *VectorLit) Pos() int    { return n.Position }
+func (n *UncertainLit) Pos() int { return n.Position }
+func (n *QuantityLit) Pos() int  { return n.Position }
+func (n *Variable) Pos() int     { return n.Position }
+func (n *CallExpr) Pos() int     { return n.Position }
+func (n *UnaryExpr) Pos() int    { return n.Position }
+func (n *BinaryExpr) Pos() int   { return n.Left.Pos() }
+func (n *Conditional) Pos() int  { return n.Position }
+func (n *Conversion) Pos() int   { return n.Value.Pos() }
+
+// Size считает узлы дерева, но не дальше limit + 1: одинаковые поддеревья,
+// подставленные в несколько мест, считаются каждый раз
+func Size(node Node, limit int) int {
+	count := 1
+	var children []Node
+	switch n := node.(type) {
+	case *UnaryExpr:
+		children = []Node{n.Operand}
+	case *BinaryExpr:
+		children = []Node{n.Left, n.Right}
+	case *Conditional:
+		children = []Node{n.Cond, n.Then, n.Else}
+	case *CallExpr:
+		children = n.Args
+	case *Conversion:
+		children = []Node{n.Value}
+	case *VectorLit:
+		children = n.Elements
+	}
+	for _, child := range children {
+		if count > limit {
+			break
+		}
+		count += Size(child, limit-count)
+	}
+	return count
+}
@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Definition - определение функции пользователя: f(x, y) = x^2 + 3*y
+type Definition struct {
+	Name   string
+	Params []string
+	Body   Node
+}
+
+// ParseDefinition разбирает определение функции вида name(a, b, ...) = выражение
+func ParseDefinition(input string) (*Definition, error) {
+	tokens, err := Tokenize(input, DefaultLocale)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, locale: DefaultLocale}
+
+	name := p.next()
+	if name.Kind != Ident {
+		return nil, &errors.SyntaxError{Pos: name.Pos, Token: name.Text, Expected: "имя функции", Err: errors.ErrUnexpectedToken}
+	}
+	if tok := p.next(); tok.Kind != LParen {
+		return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "'('", Err: errors.ErrUnexpectedToken}
+	}
+
+	def := &Definition{Name: name.Text}
+	seen := make(map[string]bool)
+	for p.peek().Kind != RParen {
+		param := p.next()
+		if param.Kind != Ident {
+			return nil, &errors.SyntaxError{Pos: param.Pos, Token: param.Text, Expected: "имя параметра", Err: errors.ErrUnexpectedToken}
+		}
+		if seen[param.Text] {
+			return nil, &errors.SyntaxError{Pos: param.Pos, Token: param.Text, Expected: "уникальное имя параметра", Err: errors.ErrInvalidName}
+		}
+		seen[param.Text] = true
+		def.Params = append(def.Params, param.Text)
+
+		if p.peek().Kind == Comma {
+			p.next()
+			continue
+		}
+		if tok := p.peek(); tok.Kind != RParen {
+			return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "',' или ')'", Err: errors.ErrUnexpectedToken}
+		}
+	}
+	p.next()
+	// Параметр после числа - множитель, а не единица: f(m) = 2 m
+	p.names = scriptNames(tokens, def.Params)
+
+	if tok := p.next(); tok.Kind != Assign {
+		return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "'='", Err: errors.ErrUnexpectedToken}
+	}
+
+	body, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.Kind != EOF {
+		return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "оператор", Err: errors.ErrUnexpectedToken}
+	}
+	def.Body = body
+
+	return def, nil
+}
@@ -0,0 +1,205 @@
+package parser
+
+import (
+	"strings"
+)
+
+// Сила связывания атомов: чисел, переменных, вызовов функций. Атом никогда не берётся в скобки
+const atomPower = 100
+
+// power возвращает силу связывания корня узла: с ней сравнивается оператор родителя,
+// чтобы понять, нужны ли узлу скобки
+func power(node Node) int {
+	switch n := node.(type) {
+	case *BinaryExpr:
+		return infixPower[OperatorText(n.Op)]
+	case *UnaryExpr:
+		switch n.Op {
+		case '¬':
+			return notPower
+		case '%':
+			return percentPower
+		}
+		return prefixPower
+	case *UncertainLit:
+		return uncertainPower
+	case *Conversion:
+		return 0
+	}
+	// Условие записывается вызовом if(...) и скобок тоже не требует
+	return atomPower
+}
+
+// operandNeedsParens проверяет, нужны ли скобки операнду child бинарной операции op.
+// Для левоассоциативных операторов скобки нужны правому операнду той же силы
+// ((a - b) - c записывается a - b - c, а a - (b - c) - со скобками), для правоассоциативных - левому.
+// Унарная операция справа берётся в скобки, если связывает слабее родителя: a ^ (not b).
+// Знак делителя остатка пишется вплотную к нему, поэтому 7 % -3 читается как остаток
+func operandNeedsParens(op rune, child Node, right bool) bool {
+	// Величина с погрешностью в составе операции всегда в скобках: (5 ± 0.1) * 2
+	if _, ok := child.(*UncertainLit); ok {
+		return true
+	}
+	// Величина с единицей - в скобках только в основании степени: (3 m)^2, а 3 m^2 - площадь
+	if _, ok := child.(*QuantityLit); ok {
+		return op == '^' && !right
+	}
+	text := OperatorText(op)
+	parent, childPower := infixPower[text], power(child)
+	if childPower != parent {
+		return childPower < parent
+	}
+	if _, ok := child.(*UnaryExpr); ok {
+		return false
+	}
+	return right != rightAssoc[text]
+}
+
+// unaryOperandParens проверяет, нужны ли скобки операнду префиксной операции n.
+// Операнд берётся в скобки, если связывает слабее операции: -(not 1), -(2 * 3),
+// а бинарная операция - и при равной силе: not (a and b). Знак под знаком тоже
+// в скобках, чтобы знаки не сливались: -(-3)
+func unaryOperandParens(n *UnaryExpr) bool {
+	if inner, ok := n.Operand.(*UnaryExpr); ok {
+		return power(inner) < power(n) || isSign(n.Op) && isSign(inner.Op)
+	}
+	return power(n.Operand) <= power(n)
+}
+
+func isSign(op rune) bool {
+	return op == '-' || op == '+'
+}
+
+// Format записывает выражение в каноническом виде: с минимумом скобок,
+// пробелами вокруг бинарных операторов и числами в единой записи.
+// Условие записывается вызовом if(cond, a, b)
+func Format(node Node) string {
+	var b strings.Builder
+	writeText(&b, node)
+	return b.String()
+}
+
+// FormatScript записывает сценарий в каноническом виде: инструкции через "; "
+func FormatScript(statements []Statement) string {
+	parts := make([]string, len(statements))
+	for i, statement := range statements {
+		parts[i] = Format(statement.Value)
+		if statement.Name != "" {
+			parts[i] = statement.Name + " = " + parts[i]
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func writeText(b *strings.Builder, node Node) {
+	switch n := node.(type) {
+	case *NumberLit:
+		b.WriteString(normalizeNumber(n.Text))
+	case *ImaginaryLit:
+		if n.Text == imaginaryUnit {
+			b.WriteString(imaginaryUnit)
+		} else {
+			b.WriteString(normalizeNumber(strings.TrimSuffix(n.Text, imaginaryUnit)) + imaginaryUnit)
+		}
+	case *Variable:
+		b.WriteString(n.Name)
+	case *UnaryExpr:
+		if n.Op == '%' {
+			writeOperand(b, n.Operand, power(n.Operand) < percentPower)
+			b.WriteByte('%')
+			break
+		}
+		b.WriteString(OperatorText(n.Op))
+		if n.Op == '¬' {
+			b.WriteByte(' ')
+		}
+		writeOperand(b, n.Operand, unaryOperandParens(n))
+	case *BinaryExpr:
+		writeOperand(b, n.Left, operandNeedsParens(n.Op, n.Left, false))
+		b.WriteString(" " + OperatorText(n.Op) + " ")
+		writeOperand(b, n.Right, operandNeedsParens(n.Op, n.Right, true))
+	case *CallExpr:
+		writeCall(b, n.Name, n.Args)
+	case *Conditional:
+		writeCall(b, conditionalFunction, []Node{n.Cond, n.Then, n.Else})
+	case *UncertainLit:
+		writeText(b, n.Mean)
+		b.WriteString(" ± ")
+		writeText(b, n.Sigma)
+	case *VectorLit:
+		b.WriteByte('[')
+		for i, element := range n.Elements {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeText(b, element)
+		}
+		b.WriteByte(']')
+	case *QuantityLit:
+		writeText(b, n.Value)
+		b.WriteString(" " + n.Unit.String())
+	case *Conversion:
+		writeText(b, n.Value)
+		b.WriteString(" " + conversionKeyword + " " + n.Unit.String())
+	}
+}
+
+func writeOperand(b *strings.Builder, node Node, parens bool) {
+	if parens {
+		b.WriteByte('(')
+	}
+	writeText(b, node)
+	if parens {
+		b.WriteByte(')')
+	}
+}
+
+func writeCall(b *strings.Builder, name string, args []Node) {
+	b.WriteString(name + "(")
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeText(b, arg)
+	}
+	b.WriteByte(')')
+}
+
+// normalizeNumber приводит числовой литерал к единой записи: .5 - 0.5, 5. - 5,
+// 007 - 7, 2E+10 - 2e10, 0XFF - 0xff. Значение литерала не меняется
+func normalizeNumber(text string) string {
+	if hasBasePrefix(text) {
+		return strings.ToLower(text)
+	}
+
+	mantissa, exponent := text, ""
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		mantissa, exponent = text[:i], text[i+1:]
+	}
+
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	whole = strings.TrimLeft(whole, "0")
+	if whole == "" {
+		whole = "0"
+	}
+	result := whole
+	if fraction != "" {
+		result += "." + fraction
+	}
+
+	if exponent != "" {
+		sign := ""
+		if exponent[0] == '+' || exponent[0] == '-' {
+			if exponent[0] == '-' {
+				sign = "-"
+			}
+			exponent = exponent[1:]
+		}
+		exponent = strings.TrimLeft(exponent, "0")
+		if exponent == "" {
+			return result
+		}
+		result += "e" + sign + exponent
+	}
+	return result
+}
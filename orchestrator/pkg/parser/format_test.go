@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input string
+		text  string
+		latex string
+	}{
+		{"1+2*3", "1 + 2 * 3", `1 + 2 \cdot 3`},
+		{"(1+2)*3", "(1 + 2) * 3", `\left(1 + 2\right) \cdot 3`},
+		{"1-(2-3)", "1 - (2 - 3)", "1 - \\left(2 - 3\\right)"},
+		{"2^3^2", "2 ^ 3 ^ 2", "2^{3^{2}}"},
+		{"(2^3)^2", "(2 ^ 3) ^ 2", `\left(2^{3}\right)^{2}`},
+		{"-2^2", "-2 ^ 2", "-2^{2}"},
+		{"(-2)^2", "(-2) ^ 2", `\left(-2\right)^{2}`},
+		{"-(2*3)", "-(2 * 3)", `-\left(2 \cdot 3\right)`},
+		{"-(-3)", "-(-3)", `-\left(-3\right)`},
+		{"-(not 1) + 2", "-(not 1) + 2", `-\left(\lnot 1\right) + 2`},
+		{"not (1 and 0)", "not (1 and 0)", `\lnot \left(1 \land 0\right)`},
+		{"not not 1", "not not 1", `\lnot \lnot 1`},
+		{"not -1", "not -1", `\lnot -1`},
+		{"2 ^ -(not 1)", "2 ^ (-(not 1))", `2^{-\left(\lnot 1\right)}`},
+		{"i^2", "i ^ 2", "i^{2}"},
+		{"(2i)^2", "2i ^ 2", `\left(2i\right)^{2}`},
+		{"(-5)%", "(-5)%", `\left(-5\right)\%`},
+		{"-(5%)", "-5%", `-5\%`},
+		{"7 % (-3)", "7 % -3", `7 \bmod -3`},
+		{"10%-3", "10% - 3", `10\% - 3`},
+		{"1/(2+3)", "1 / (2 + 3)", `\frac{1}{2 + 3}`},
+		{".5 + 007", "0.5 + 7", "0.5 + 7"},
+		{"2*5±0.1", "2 * (5 ± 0.1)", `2 \cdot \left(5 \pm 0.1\right)`},
+		{"-5 ± 0.1", "-5 ± 0.1", `-5 \pm 0.1`},
+		{"[-.5,2]*3", "[-0.5, 2] * 3", `\begin{bmatrix} -0.5 & 2 \end{bmatrix} \cdot 3`},
+		{"[[1,2],[3,4]]@[5,6]", "[[1, 2], [3, 4]] @ [5, 6]", `\begin{bmatrix} 1 & 2 \\ 3 & 4 \end{bmatrix} \, \begin{bmatrix} 5 & 6 \end{bmatrix}`},
+	}
+
+	for _, tt := range tests {
+		node, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if got := Format(node); got != tt.text {
+			t.Errorf("Format(%q) = %q, want %q", tt.input, got, tt.text)
+		}
+		if got := LaTeX(node); got != tt.latex {
+			t.Errorf("LaTeX(%q) = %q, want %q", tt.input, got, tt.latex)
+		}
+	}
+}
+
+// Каноническая запись должна вычисляться так же, как исходное выражение,
+// и не меняться при повторной записи
+func TestFormatRoundTrip(t *testing.T) {
+	inputs := []string{
+		"-(not 1) + 2",
+		"-(-3)",
+		"- -3 * 2",
+		"not (0 or 1) + 1",
+		"not 0 == 1",
+		"(not 0) == 1",
+		"1 - (2 - 3) - 4",
+		"2 ^ 3 ^ 2",
+		"(2 ^ 3) ^ 2",
+		"-2 ^ 2",
+		"(-2) ^ 2",
+		"2 ^ -1",
+		"7 % 3",
+		"7 % -3",
+		"7 % -(1 + 2)",
+		"200 + 10%",
+		"200 - (10 + 5)%",
+		"-5%",
+		"(-5)%",
+		"10% - 3",
+		"7 // 2 * 3",
+		"7 // (2 * 3)",
+		"1 / 2x",
+		"2(3 + 4)x",
+		"1 << 2 + 1",
+		"(1 << 2) + 1",
+		"6 & 3 | 8 xor 1",
+		"1 < 2 == 1",
+		"1 < (2 == 1)",
+		"if(1 > 2, 3, -4) * 2",
+		"0 and 1 or 1",
+		"0 and (1 or 1)",
+	}
+
+	for _, input := range inputs {
+		node, err := Parse(input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", input, err)
+			continue
+		}
+		text := Format(node)
+		formatted, err := Parse(text)
+		if err != nil {
+			t.Errorf("Parse(Format(%q)) = Parse(%q): %v", input, text, err)
+			continue
+		}
+
+		want, got := evaluate(t, node), evaluate(t, formatted)
+		if got != want && !(math.IsNaN(got) && math.IsNaN(want)) {
+			t.Errorf("Format(%q) = %q вычисляется в %v, want %v", input, text, got, want)
+		}
+		if again := Format(formatted); again != text {
+			t.Errorf("Format(Parse(%q)) = %q, want %q", text, again, text)
+		}
+	}
+}
+
+func TestMathML(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1/(2+3)", "<mfrac><mrow><mn>1</mn></mrow><mrow><mrow><mn>2</mn><mo>+</mo><mn>3</mn></mrow></mrow></mfrac>"},
+		{"-2^2", "<mrow><mo>−</mo><msup><mrow><mn>2</mn></mrow><mrow><mn>2</mn></mrow></msup></mrow>"},
+		{"sqrt(x) <= 2", "<mrow><msqrt><mi>x</mi></msqrt><mo>≤</mo><mn>2</mn></mrow>"},
+	}
+
+	for _, tt := range tests {
+		node, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		want := `<math xmlns="http://www.w3.org/1998/Math/MathML">` + tt.want + "</math>"
+		if got := MathML(node); got != want {
+			t.Errorf("MathML(%q) = %q, want %q", tt.input, got, want)
+		}
+	}
+}
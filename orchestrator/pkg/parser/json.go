@@ -0,0 +1,123 @@
+package parser
+
+import "encoding/json"
+
+// OperatorText возвращает запись операции дерева в исходном выражении: '≤' - "<=", '¬' - "not".
+// Процент и остаток от деления записываются одинаково - "%"
+func OperatorText(op rune) string {
+	switch op {
+	case '^':
+		return "^"
+	case '*':
+		return "*"
+	case '¬':
+		return "not"
+	}
+	for text, r := range operations {
+		if r == op {
+			return text
+		}
+	}
+	return string(op)
+}
+
+// Узлы дерева сериализуются в JSON с полем type, по которому клиент различает их вид
+
+func (n *NumberLit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string  `json:"type"`
+		Pos   int     `json:"pos"`
+		Text  string  `json:"text"`
+		Value float64 `json:"value"`
+	}{"number", n.Position, n.Text, n.Value})
+}
+
+func (n *ImaginaryLit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string  `json:"type"`
+		Pos   int     `json:"pos"`
+		Text  string  `json:"text"`
+		Value float64 `json:"value"`
+	}{"imaginary", n.Position, n.Text, n.Value})
+}
+
+func (n *VectorLit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string `json:"type"`
+		Pos      int    `json:"pos"`
+		Elements []Node `json:"elements"`
+	}{"vector", n.Position, n.Elements})
+}
+
+func (n *QuantityLit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Pos   int    `json:"pos"`
+		Value Node   `json:"value"`
+		Unit  string `json:"unit"`
+	}{"quantity", n.Position, n.Value, n.Unit.String()})
+}
+
+func (n *Conversion) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Pos   int    `json:"pos"`
+		Value Node   `json:"value"`
+		Unit  string `json:"unit"`
+	}{"conversion", n.Position, n.Value, n.Unit.String()})
+}
+
+func (n *UncertainLit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Pos   int    `json:"pos"`
+		Mean  Node   `json:"mean"`
+		Sigma Node   `json:"sigma"`
+	}{"uncertain", n.Position, n.Mean, n.Sigma})
+}
+
+func (n *Variable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Pos  int    `json:"pos"`
+		Name string `json:"name"`
+	}{"variable", n.Position, n.Name})
+}
+
+func (n *UnaryExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type    string `json:"type"`
+		Pos     int    `json:"pos"`
+		Op      string `json:"op"`
+		Operand Node   `json:"operand"`
+	}{"unary", n.Position, OperatorText(n.Op), n.Operand})
+}
+
+func (n *BinaryExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Pos   int    `json:"pos"`
+		Op    string `json:"op"`
+		Left  Node   `json:"left"`
+		Right Node   `json:"right"`
+	}{"binary", n.Position, OperatorText(n.Op), n.Left, n.Right})
+}
+
+func (n *Conditional) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Pos  int    `json:"pos"`
+		Cond Node   `json:"cond"`
+		Then Node   `json:"then"`
+		Else Node   `json:"else"`
+	}{"conditional", n.Position, n.Cond, n.Then, n.Else})
+}
+
+func (n *CallExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Pos  int    `json:"pos"`
+		Name string `json:"name"`
+		Args []Node `json:"args"`
+	}{"call", n.Position, n.Name, n.Args})
+}
@@ -0,0 +1,303 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/unit"
+)
+
+// Операторы в LaTeX. Деление и целочисленное деление записываются дробью
+var latexOperators = map[rune]string{
+	'+': "+",
+	'-': "-",
+	'*': `\cdot`,
+	'@': `\,`,
+	'%': `\bmod`,
+	'&': `\mathbin{\&}`,
+	'|': `\mathbin{|}`,
+	'⊕': `\oplus`,
+	'≪': `\ll`,
+	'≫': `\gg`,
+	'<': "<",
+	'>': ">",
+	'≤': `\le`,
+	'≥': `\ge`,
+	'=': "=",
+	'≠': `\ne`,
+	'∧': `\land`,
+	'∨': `\lor`,
+	'¬': `\lnot`,
+}
+
+// Функции, для которых в LaTeX есть свои команды
+var latexFunctions = map[string]string{
+	"sin": `\sin`,
+	"cos": `\cos`,
+	"tan": `\tan`,
+	"ln":  `\ln`,
+	"exp": `\exp`,
+	"min": `\min`,
+	"max": `\max`,
+	"det": `\det`,
+}
+
+// isFraction проверяет, записывается ли узел дробью: дробь скобок не требует
+func isFraction(node Node) bool {
+	n, ok := node.(*BinaryExpr)
+	return ok && (n.Op == '/' || n.Op == '÷')
+}
+
+// isSum проверяет, является ли выражение суммой или разностью: тело интеграла и ряда с ней берётся в скобки
+func isSum(node Node) bool {
+	n, ok := node.(*BinaryExpr)
+	return ok && (n.Op == '+' || n.Op == '-')
+}
+
+// displayOperandParens решает, нужны ли скобки операнду при записи формулой (LaTeX, MathML).
+// Числитель и знаменатель дроби и показатель степени в скобках не нуждаются,
+// основание степени берётся в скобки, если это не число, переменная или вызов
+func displayOperandParens(op rune, child Node, right bool) bool {
+	switch {
+	case op == '/' || op == '÷':
+		return false
+	case op == '^' && right:
+		return false
+	case op == '^':
+		switch n := child.(type) {
+		case *Variable, *CallExpr, *Conditional:
+			return false
+		case *NumberLit:
+			return strings.ContainsAny(n.Text, "eE")
+		case *ImaginaryLit:
+			// i^2 - без скобок, а (2i)^2 - в скобках: 2i^2 читается как 2 i^2
+			return n.Text != imaginaryUnit
+		}
+		return true
+	case isFraction(child):
+		return false
+	}
+	return operandNeedsParens(op, child, right)
+}
+
+// LaTeX записывает выражение формулой LaTeX
+func LaTeX(node Node) string {
+	var b strings.Builder
+	writeLaTeX(&b, node)
+	return b.String()
+}
+
+// LaTeXScript записывает сценарий формулами LaTeX через ";\quad"
+func LaTeXScript(statements []Statement) string {
+	parts := make([]string, len(statements))
+	for i, statement := range statements {
+		parts[i] = LaTeX(statement.Value)
+		if statement.Name != "" {
+			parts[i] = latexName(statement.Name) + " = " + parts[i]
+		}
+	}
+	return strings.Join(parts, `;\quad `)
+}
+
+func writeLaTeX(b *strings.Builder, node Node) {
+	switch n := node.(type) {
+	case *NumberLit:
+		b.WriteString(latexNumber(normalizeNumber(n.Text)))
+	case *ImaginaryLit:
+		if n.Text != imaginaryUnit {
+			b.WriteString(latexNumber(normalizeNumber(strings.TrimSuffix(n.Text, imaginaryUnit))))
+		}
+		b.WriteString(imaginaryUnit)
+	case *Variable:
+		b.WriteString(latexName(n.Name))
+	case *UnaryExpr:
+		if n.Op == '%' {
+			writeLaTeXOperand(b, n.Operand, !isFraction(n.Operand) && power(n.Operand) < percentPower)
+			b.WriteString(`\%`)
+			break
+		}
+		b.WriteString(latexOperators[n.Op])
+		if n.Op == '¬' {
+			b.WriteByte(' ')
+		}
+		writeLaTeXOperand(b, n.Operand, !isFraction(n.Operand) && unaryOperandParens(n))
+	case *BinaryExpr:
+		switch n.Op {
+		case '/':
+			b.WriteString(`\frac{`)
+			writeLaTeX(b, n.Left)
+			b.WriteString("}{")
+			writeLaTeX(b, n.Right)
+			b.WriteString("}")
+		case '÷':
+			b.WriteString(`\left\lfloor \frac{`)
+			writeLaTeX(b, n.Left)
+			b.WriteString("}{")
+			writeLaTeX(b, n.Right)
+			b.WriteString(`} \right\rfloor`)
+		case '^':
+			writeLaTeXOperand(b, n.Left, displayOperandParens(n.Op, n.Left, false))
+			b.WriteString("^{")
+			writeLaTeX(b, n.Right)
+			b.WriteString("}")
+		default:
+			writeLaTeXOperand(b, n.Left, displayOperandParens(n.Op, n.Left, false))
+			b.WriteString(" " + latexOperators[n.Op] + " ")
+			writeLaTeXOperand(b, n.Right, displayOperandParens(n.Op, n.Right, true))
+		}
+	case *CallExpr:
+		writeLaTeXCall(b, n)
+	case *Conditional:
+		b.WriteString(`\begin{cases} `)
+		writeLaTeX(b, n.Then)
+		b.WriteString(` & \text{if } `)
+		writeLaTeX(b, n.Cond)
+		b.WriteString(` \\ `)
+		writeLaTeX(b, n.Else)
+		b.WriteString(` & \text{otherwise} \end{cases}`)
+	case *UncertainLit:
+		writeLaTeX(b, n.Mean)
+		b.WriteString(` \pm `)
+		writeLaTeX(b, n.Sigma)
+	case *VectorLit:
+		// Вектор записывается строкой, матрица - строками через \\
+		b.WriteString(`\begin{bmatrix} `)
+		for i, row := range matrixRows(n) {
+			if i > 0 {
+				b.WriteString(` \\ `)
+			}
+			for j, element := range row {
+				if j > 0 {
+					b.WriteString(" & ")
+				}
+				writeLaTeX(b, element)
+			}
+		}
+		b.WriteString(` \end{bmatrix}`)
+	case *QuantityLit:
+		writeLaTeX(b, n.Value)
+		b.WriteString(`\,` + latexUnit(n.Unit))
+	case *Conversion:
+		writeLaTeX(b, n.Value)
+		b.WriteString(` \to ` + latexUnit(n.Unit))
+	}
+}
+
+func writeLaTeXOperand(b *strings.Builder, node Node, parens bool) {
+	if parens {
+		b.WriteString(`\left(`)
+	}
+	writeLaTeX(b, node)
+	if parens {
+		b.WriteString(`\right)`)
+	}
+}
+
+func writeLaTeXCall(b *strings.Builder, call *CallExpr) {
+	// Интеграл и ряд записываются знаками с пределами, тело-сумма - в скобках
+	if it, ok := IterationOf(call); ok {
+		body := call.Args[it.Body]
+		if call.Name == "integrate" {
+			b.WriteString(`\int_{`)
+			writeLaTeX(b, call.Args[it.From])
+			b.WriteString(`}^{`)
+			writeLaTeX(b, call.Args[it.To])
+			b.WriteString(`} `)
+			writeLaTeXOperand(b, body, isSum(body))
+			b.WriteString(`\,d` + latexName(it.Variable))
+			return
+		}
+		b.WriteString(`\sum_{` + latexName(it.Variable) + ` = `)
+		writeLaTeX(b, call.Args[it.From])
+		b.WriteString(`}^{`)
+		writeLaTeX(b, call.Args[it.To])
+		b.WriteString(`} `)
+		writeLaTeXOperand(b, body, isSum(body))
+		return
+	}
+
+	if len(call.Args) == 1 {
+		switch call.Name {
+		case "sqrt":
+			b.WriteString(`\sqrt{`)
+			writeLaTeX(b, call.Args[0])
+			b.WriteString("}")
+			return
+		case "abs":
+			b.WriteString(`\left|`)
+			writeLaTeX(b, call.Args[0])
+			b.WriteString(`\right|`)
+			return
+		case "log10":
+			b.WriteString(`\log_{10}`)
+			writeLaTeXOperand(b, call.Args[0], true)
+			return
+		}
+	}
+
+	name, ok := latexFunctions[call.Name]
+	if !ok {
+		name = `\operatorname{` + strings.ReplaceAll(call.Name, "_", `\_`) + "}"
+	}
+	b.WriteString(name + `\left(`)
+	for i, arg := range call.Args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeLaTeX(b, arg)
+	}
+	b.WriteString(`\right)`)
+}
+
+// latexNumber записывает число, порядок - степенью десяти: 1.5e-3 - 1.5 \cdot 10^{-3}
+func latexNumber(text string) string {
+	mantissa, exponent, ok := strings.Cut(text, "e")
+	if !ok || hasBasePrefix(text) {
+		return text
+	}
+	return mantissa + ` \cdot 10^{` + exponent + "}"
+}
+
+// latexUnit записывает единицу прямым шрифтом: \mathrm{km}/\mathrm{h}
+func latexUnit(e unit.Expr) string {
+	numerator, denominator := e.Split()
+	parts := make([]string, len(numerator))
+	for i, term := range numerator {
+		parts[i] = latexUnitTerm(term)
+	}
+	text := strings.Join(parts, ` \cdot `)
+	for _, term := range denominator {
+		text += "/" + latexUnitTerm(term)
+	}
+	return text
+}
+
+func latexUnitTerm(term unit.Term) string {
+	text := `\mathrm{` + term.Symbol + "}"
+	if term.Power != 1 {
+		text += "^{" + strconv.Itoa(term.Power) + "}"
+	}
+	return text
+}
+
+// latexName записывает имя переменной: однобуквенное - как есть, длинное - прямым курсивом целиком
+func latexName(name string) string {
+	if len([]rune(name)) == 1 {
+		return name
+	}
+	return `\mathit{` + strings.ReplaceAll(name, "_", `\_`) + "}"
+}
+
+// matrixRows делит вектор на строки для записи формулой. Вектор из векторов - матрица
+// по строкам, вектор чисел записывается одной строкой
+func matrixRows(n *VectorLit) [][]Node {
+	rows := make([][]Node, len(n.Elements))
+	for i, element := range n.Elements {
+		row, ok := element.(*VectorLit)
+		if !ok {
+			return [][]Node{n.Elements}
+		}
+		rows[i] = row.Elements
+	}
+	return rows
+}
@@ -0,0 +1,146 @@
+package parser
+
+import (
+	stderrors "errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Операторы из нескольких символов. Проверяются раньше односимвольных
+var longOperators = []string{"**", "//", "<<", ">>", "<=", ">=", "==", "!="}
+
+// Операторы, записываемые словом
+var wordOperators = map[string]bool{
+	"xor": true,
+	"and": true,
+	"or":  true,
+	"not": true,
+}
+
+// Tokenize разбивает выражение на токены. Последним токеном всегда идёт EOF.
+// Числа записываются по правилам локали, в токенах они приводятся к записи с точкой
+func Tokenize(input string, locale Locale) ([]Token, error) {
+	var tokens []Token
+	// Для каждой открытой скобки - открывает ли она список: аргументы вызова функции или элементы вектора
+	var lists []bool
+
+	for pos := 0; pos < len(input); {
+		r, size := utf8.DecodeRuneInString(input[pos:])
+
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+		case locale.startsNumber(input, pos):
+			numbers := locale
+			if len(lists) > 0 && lists[len(lists)-1] {
+				numbers = locale.arguments()
+			}
+			end := scanNumber(input, pos, numbers)
+			text := numbers.delocalize(input[pos:end])
+			if _, err := parseNumber(text); err != nil {
+				if stderrors.Is(err, strconv.ErrRange) {
+					return nil, &errors.SyntaxError{Pos: pos, Token: input[pos:end], Err: errors.ErrNumberOutOfRange}
+				}
+				return nil, &errors.SyntaxError{Pos: pos, Token: input[pos:end], Expected: "число", Err: errors.ErrInvalidNumber}
+			}
+			tokens = append(tokens, Token{Kind: Number, Text: text, Pos: pos})
+			pos = end
+		case unicode.IsLetter(r) || r == '_':
+			end := pos
+			for end < len(input) {
+				r, size := utf8.DecodeRuneInString(input[end:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+					break
+				}
+				end += size
+			}
+			kind := Ident
+			if wordOperators[input[pos:end]] {
+				kind = Operator
+			}
+			tokens = append(tokens, Token{Kind: kind, Text: input[pos:end], Pos: pos})
+			pos = end
+		case r == '(':
+			lists = append(lists, len(tokens) > 0 && tokens[len(tokens)-1].Kind == Ident)
+			tokens = append(tokens, Token{Kind: LParen, Text: "(", Pos: pos})
+			pos += size
+		case r == ')':
+			lists = closeBracket(lists)
+			tokens = append(tokens, Token{Kind: RParen, Text: ")", Pos: pos})
+			pos += size
+		case r == '[':
+			lists = append(lists, true)
+			tokens = append(tokens, Token{Kind: LBracket, Text: "[", Pos: pos})
+			pos += size
+		case r == ']':
+			lists = closeBracket(lists)
+			tokens = append(tokens, Token{Kind: RBracket, Text: "]", Pos: pos})
+			pos += size
+		case r == ',':
+			// Запятая, не ставшая частью числа, разделяет аргументы функций и элементы векторов
+			tokens = append(tokens, Token{Kind: Comma, Text: ",", Pos: pos})
+			pos += size
+		case longOperator(input[pos:]) != "":
+			text := longOperator(input[pos:])
+			tokens = append(tokens, Token{Kind: Operator, Text: text, Pos: pos})
+			pos += len(text)
+		case r == '=':
+			tokens = append(tokens, Token{Kind: Assign, Text: "=", Pos: pos})
+			pos += size
+		case r == ';':
+			tokens = append(tokens, Token{Kind: Semicolon, Text: ";", Pos: pos})
+			pos += size
+		case strings.ContainsRune("+-*/^%&|<>?:×·±@", r):
+			tokens = append(tokens, Token{Kind: Operator, Text: string(r), Pos: pos})
+			pos += size
+		default:
+			return nil, &errors.SyntaxError{Pos: pos, Token: string(r), Err: errors.ErrInvalidNumber}
+		}
+	}
+
+	return append(markTimes(tokens), Token{Kind: EOF, Pos: len(input)}), nil
+}
+
+// closeBracket снимает закрытую скобку со стека. Лишнюю закрывающую скобку
+// лексер пропускает: о ней сообщит парсер
+func closeBracket(lists []bool) []bool {
+	if len(lists) == 0 {
+		return lists
+	}
+	return lists[:len(lists)-1]
+}
+
+// markTimes превращает букву x между двумя числами в знак умножения: 3 x 4.
+// В остальных местах x - обычное имя: 3x = 3*x
+func markTimes(tokens []Token) []Token {
+	for i := 1; i+1 < len(tokens); i++ {
+		if tokens[i].Kind == Ident && tokens[i].Text == "x" && tokens[i-1].Kind == Number && tokens[i+1].Kind == Number {
+			tokens[i].Kind = Operator
+		}
+	}
+	return tokens
+}
+
+// longOperator возвращает многосимвольный оператор, с которого начинается s
+func longOperator(s string) string {
+	for _, op := range longOperators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// IsIdentifier проверяет, что строка целиком является одним идентификатором
+func IsIdentifier(s string) bool {
+	tokens, err := Tokenize(s, DefaultLocale)
+	return err == nil && len(tokens) == 2 && tokens[0].Kind == Ident && tokens[0].Text == s
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Locale - запись чисел в выражении: десятичный разделитель и разделители групп разрядов
+type Locale struct {
+	Decimal rune   // десятичный разделитель: '.' или ','
+	Groups  string // символы, разделяющие группы по три цифры; пусто - группы не разделяются
+	Comma   bool   // запятая вплотную к цифрам - тоже десятичный разделитель, как в первых версиях: 1,5
+}
+
+// DefaultLocale - запись по умолчанию: десятичный разделитель - точка или запятая вплотную
+// к цифрам, группы не разделяются. В списке аргументов запятая разделяет аргументы
+var DefaultLocale = Locale{Decimal: '.', Comma: true}
+
+// Поддерживаемые локали. Неразрывные пробелы ставят в числах текстовые редакторы
+var locales = map[string]Locale{
+	"en": {Decimal: '.', Groups: ","},
+	"de": {Decimal: ',', Groups: "."},
+	"ru": {Decimal: ',', Groups: "   "},
+	"fr": {Decimal: ',', Groups: "   "},
+}
+
+// ParseLocale проверяет локаль из запроса. Пустая строка означает запись по умолчанию
+func ParseLocale(name string) (Locale, error) {
+	if name == "" {
+		return DefaultLocale, nil
+	}
+	if locale, ok := locales[name]; ok {
+		return locale, nil
+	}
+	return Locale{}, fmt.Errorf("%w: %q, допустимо en, de, ru или fr", errors.ErrUnknownLocale, name)
+}
+
+// startsNumber проверяет, начинается ли с позиции pos числовой литерал.
+// С точки число начинается, только если точка - десятичный разделитель: .5
+func (l Locale) startsNumber(input string, pos int) bool {
+	return isDigit(rune(input[pos])) || input[pos] == '.' && l.Decimal == '.'
+}
+
+// isGroup проверяет, что с позиции pos записан разделитель группы разрядов, за которым
+// ровно три цифры. Возвращает длину разделителя в байтах
+func (l Locale) isGroup(input string, pos int) (int, bool) {
+	if l.Groups == "" || pos >= len(input) {
+		return 0, false
+	}
+	r, size := utf8.DecodeRuneInString(input[pos:])
+	if !strings.ContainsRune(l.Groups, r) {
+		return 0, false
+	}
+	end := pos + size
+	for i := end; i < end+3; i++ {
+		if i >= len(input) || !isDigit(rune(input[i])) {
+			return 0, false
+		}
+	}
+	if end+3 < len(input) && isDigit(rune(input[end+3])) {
+		return 0, false
+	}
+	return size, true
+}
+
+// isDecimal проверяет, что с позиции pos записан десятичный разделитель. Запятая считается
+// десятичным разделителем, только если за ней сразу идёт цифра: в max(1, 2) она разделяет аргументы
+func (l Locale) isDecimal(input string, pos int) bool {
+	if pos >= len(input) {
+		return false
+	}
+	switch {
+	case input[pos] == '.' && l.Decimal == '.':
+		return true
+	case input[pos] == ',' && (l.Decimal == ',' || l.Comma):
+		return pos+1 < len(input) && isDigit(rune(input[pos+1]))
+	}
+	return false
+}
+
+// arguments возвращает запись чисел в списке аргументов функции или в векторе. Там запятая
+// разделяет аргументы, поэтому разделители групп не действуют, а десятичная запятая
+// записи по умолчанию не принимается: max(1,5) - максимум из 1 и 5, а не 1.5
+func (l Locale) arguments() Locale {
+	return Locale{Decimal: l.Decimal}
+}
+
+// delocalize переводит литерал в запись по умолчанию: десятичный разделитель - точка,
+// без разделителей групп
+func (l Locale) delocalize(text string) string {
+	if l.Decimal == '.' && l.Groups == "" && !l.Comma {
+		return text
+	}
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == l.Decimal || r == ',' && l.Comma:
+			b.WriteByte('.')
+		case strings.ContainsRune(l.Groups, r):
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// commaHint подсказывает, что означает запятая в этой локали
+func (l Locale) commaHint() string {
+	if l.Decimal == ',' || l.Comma {
+		return "оператор (десятичная запятая пишется без пробелов)"
+	}
+	return "оператор (десятичный разделитель - точка)"
+}
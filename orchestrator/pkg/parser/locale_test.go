@@ -0,0 +1,67 @@
+package parser
+
+import (
+	stderrors "errors"
+	"slices"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+func TestTokenizeLocale(t *testing.T) {
+	tests := []struct {
+		locale  string
+		input   string
+		numbers []string // числовые токены в записи с точкой
+		commas  int      // запятые, разделяющие аргументы
+	}{
+		{"", "1.5 + 2", []string{"1.5", "2"}, 0},
+		{"", "1,5 + 2", []string{"1.5", "2"}, 0},
+		{"", "(1,5) * 2", []string{"1.5", "2"}, 0},
+		{"", "max(1,5)", []string{"1", "5"}, 1},
+		{"", "max (1,5, 2)", []string{"1", "5", "2"}, 2},
+		{"", "max((1,5), 2)", []string{"1.5", "2"}, 1},
+		{"", "[1,5]", []string{"1", "5"}, 1},
+		{"", "1, 5", []string{"1", "5"}, 1},
+		{"en", "1,234.5", []string{"1234.5"}, 0},
+		{"en", "1,23", []string{"1", "23"}, 1},
+		{"en", "max(1,234)", []string{"1", "234"}, 1},
+		{"en", "max(1,234.5, 2)", []string{"1", "234.5", "2"}, 2},
+		{"de", "1.234,5", []string{"1234.5"}, 0},
+		{"de", "max(1,5, 2)", []string{"1.5", "2"}, 1},
+		{"ru", "1 234,5", []string{"1234.5"}, 0},
+		{"fr", "1 234,5 * 2", []string{"1234.5", "2"}, 0},
+	}
+
+	for _, tt := range tests {
+		locale, err := ParseLocale(tt.locale)
+		if err != nil {
+			t.Fatalf("ParseLocale(%q): %v", tt.locale, err)
+		}
+		tokens, err := Tokenize(tt.input, locale)
+		if err != nil {
+			t.Errorf("Tokenize(%q, %q): %v", tt.input, tt.locale, err)
+			continue
+		}
+
+		var numbers []string
+		commas := 0
+		for _, tok := range tokens {
+			switch tok.Kind {
+			case Number:
+				numbers = append(numbers, tok.Text)
+			case Comma:
+				commas++
+			}
+		}
+		if !slices.Equal(numbers, tt.numbers) || commas != tt.commas {
+			t.Errorf("Tokenize(%q, %q): числа %q, запятых %d, want %q, %d", tt.input, tt.locale, numbers, commas, tt.numbers, tt.commas)
+		}
+	}
+}
+
+func TestParseLocaleUnknown(t *testing.T) {
+	if _, err := ParseLocale("es"); !stderrors.Is(err, errors.ErrUnknownLocale) {
+		t.Errorf("ParseLocale(%q) = %v, want %v", "es", err, errors.ErrUnknownLocale)
+	}
+}
@@ -0,0 +1,295 @@
+package parser
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/unit"
+)
+
+// Операторы в MathML. Деление и целочисленное деление записываются дробью
+var mathmlOperators = map[rune]string{
+	'+': "+",
+	'-': "−",
+	'*': "⋅",
+	'@': "\u2062",
+	'%': "mod",
+	'&': "&",
+	'|': "|",
+	'⊕': "⊕",
+	'≪': "≪",
+	'≫': "≫",
+	'<': "<",
+	'>': ">",
+	'≤': "≤",
+	'≥': "≥",
+	'=': "=",
+	'≠': "≠",
+	'∧': "∧",
+	'∨': "∨",
+	'¬': "¬",
+}
+
+// MathML записывает выражение формулой MathML
+func MathML(node Node) string {
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML">`)
+	writeMathML(&b, node)
+	b.WriteString("</math>")
+	return b.String()
+}
+
+// MathMLScript записывает сценарий одной формулой MathML, инструкции разделены ';'
+func MathMLScript(statements []Statement) string {
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML">`)
+	for i, statement := range statements {
+		if i > 0 {
+			mo(&b, ";")
+		}
+		b.WriteString("<mrow>")
+		if statement.Name != "" {
+			mi(&b, statement.Name)
+			mo(&b, "=")
+		}
+		writeMathML(&b, statement.Value)
+		b.WriteString("</mrow>")
+	}
+	b.WriteString("</math>")
+	return b.String()
+}
+
+func mo(b *strings.Builder, text string) {
+	b.WriteString("<mo>" + html.EscapeString(text) + "</mo>")
+}
+
+func mi(b *strings.Builder, text string) {
+	b.WriteString("<mi>" + html.EscapeString(text) + "</mi>")
+}
+
+func writeMathML(b *strings.Builder, node Node) {
+	switch n := node.(type) {
+	case *NumberLit:
+		writeMathMLNumber(b, normalizeNumber(n.Text))
+	case *ImaginaryLit:
+		b.WriteString("<mrow>")
+		if n.Text != imaginaryUnit {
+			writeMathMLNumber(b, normalizeNumber(strings.TrimSuffix(n.Text, imaginaryUnit)))
+		}
+		mi(b, imaginaryUnit)
+		b.WriteString("</mrow>")
+	case *Variable:
+		mi(b, n.Name)
+	case *UnaryExpr:
+		if n.Op == '%' {
+			b.WriteString("<mrow>")
+			writeMathMLOperand(b, n.Operand, !isFraction(n.Operand) && power(n.Operand) < percentPower)
+			mo(b, "%")
+			b.WriteString("</mrow>")
+			break
+		}
+		b.WriteString("<mrow>")
+		mo(b, mathmlOperators[n.Op])
+		writeMathMLOperand(b, n.Operand, !isFraction(n.Operand) && unaryOperandParens(n))
+		b.WriteString("</mrow>")
+	case *BinaryExpr:
+		switch n.Op {
+		case '/', '÷':
+			if n.Op == '÷' {
+				b.WriteString("<mrow>")
+				mo(b, "⌊")
+			}
+			b.WriteString("<mfrac><mrow>")
+			writeMathML(b, n.Left)
+			b.WriteString("</mrow><mrow>")
+			writeMathML(b, n.Right)
+			b.WriteString("</mrow></mfrac>")
+			if n.Op == '÷' {
+				mo(b, "⌋")
+				b.WriteString("</mrow>")
+			}
+		case '^':
+			b.WriteString("<msup><mrow>")
+			writeMathMLOperand(b, n.Left, displayOperandParens(n.Op, n.Left, false))
+			b.WriteString("</mrow><mrow>")
+			writeMathML(b, n.Right)
+			b.WriteString("</mrow></msup>")
+		default:
+			b.WriteString("<mrow>")
+			writeMathMLOperand(b, n.Left, displayOperandParens(n.Op, n.Left, false))
+			mo(b, mathmlOperators[n.Op])
+			writeMathMLOperand(b, n.Right, displayOperandParens(n.Op, n.Right, true))
+			b.WriteString("</mrow>")
+		}
+	case *CallExpr:
+		writeMathMLCall(b, n)
+	case *Conditional:
+		b.WriteString(`<mrow><mo>{</mo><mtable><mtr><mtd>`)
+		writeMathML(b, n.Then)
+		b.WriteString(`</mtd><mtd><mtext>if </mtext>`)
+		writeMathML(b, n.Cond)
+		b.WriteString(`</mtd></mtr><mtr><mtd>`)
+		writeMathML(b, n.Else)
+		b.WriteString(`</mtd><mtd><mtext>otherwise</mtext></mtd></mtr></mtable></mrow>`)
+	case *UncertainLit:
+		b.WriteString("<mrow>")
+		writeMathML(b, n.Mean)
+		mo(b, "±")
+		writeMathML(b, n.Sigma)
+		b.WriteString("</mrow>")
+	case *VectorLit:
+		b.WriteString("<mrow>")
+		mo(b, "[")
+		b.WriteString("<mtable>")
+		for _, row := range matrixRows(n) {
+			b.WriteString("<mtr>")
+			for _, element := range row {
+				b.WriteString("<mtd>")
+				writeMathML(b, element)
+				b.WriteString("</mtd>")
+			}
+			b.WriteString("</mtr>")
+		}
+		b.WriteString("</mtable>")
+		mo(b, "]")
+		b.WriteString("</mrow>")
+	case *QuantityLit:
+		b.WriteString("<mrow>")
+		writeMathML(b, n.Value)
+		b.WriteString(`<mspace width="0.17em"/>`)
+		writeMathMLUnit(b, n.Unit)
+		b.WriteString("</mrow>")
+	case *Conversion:
+		b.WriteString("<mrow>")
+		writeMathML(b, n.Value)
+		mo(b, "→")
+		writeMathMLUnit(b, n.Unit)
+		b.WriteString("</mrow>")
+	}
+}
+
+func writeMathMLOperand(b *strings.Builder, node Node, parens bool) {
+	if !parens {
+		writeMathML(b, node)
+		return
+	}
+	b.WriteString("<mrow>")
+	mo(b, "(")
+	writeMathML(b, node)
+	mo(b, ")")
+	b.WriteString("</mrow>")
+}
+
+func writeMathMLCall(b *strings.Builder, call *CallExpr) {
+	if it, ok := IterationOf(call); ok {
+		tag := "munderover"
+		if call.Name == "integrate" {
+			tag = "msubsup"
+		}
+		b.WriteString("<mrow><" + tag + ">")
+		if call.Name == "integrate" {
+			mo(b, "∫")
+			writeMathML(b, call.Args[it.From])
+		} else {
+			mo(b, "∑")
+			b.WriteString("<mrow>")
+			mi(b, it.Variable)
+			mo(b, "=")
+			writeMathML(b, call.Args[it.From])
+			b.WriteString("</mrow>")
+		}
+		writeMathML(b, call.Args[it.To])
+		b.WriteString("</" + tag + ">")
+
+		body := call.Args[it.Body]
+		if isSum(body) {
+			mo(b, "(")
+		}
+		writeMathML(b, body)
+		if isSum(body) {
+			mo(b, ")")
+		}
+		if call.Name == "integrate" {
+			b.WriteString(`<mspace width="0.17em"/>`)
+			mi(b, "d")
+			mi(b, it.Variable)
+		}
+		b.WriteString("</mrow>")
+		return
+	}
+
+	if len(call.Args) == 1 {
+		switch call.Name {
+		case "sqrt":
+			b.WriteString("<msqrt>")
+			writeMathML(b, call.Args[0])
+			b.WriteString("</msqrt>")
+			return
+		case "abs":
+			b.WriteString("<mrow>")
+			mo(b, "|")
+			writeMathML(b, call.Args[0])
+			mo(b, "|")
+			b.WriteString("</mrow>")
+			return
+		}
+	}
+
+	b.WriteString("<mrow>")
+	mi(b, call.Name)
+	b.WriteString("<mo>&#x2061;</mo><mrow>") // невидимый оператор применения функции
+	mo(b, "(")
+	for i, arg := range call.Args {
+		if i > 0 {
+			mo(b, ",")
+		}
+		writeMathML(b, arg)
+	}
+	mo(b, ")")
+	b.WriteString("</mrow></mrow>")
+}
+
+// writeMathMLUnit записывает единицу прямым шрифтом
+func writeMathMLUnit(b *strings.Builder, e unit.Expr) {
+	numerator, denominator := e.Split()
+	b.WriteString("<mrow>")
+	for i, term := range numerator {
+		if i > 0 {
+			mo(b, "⋅")
+		}
+		writeMathMLUnitTerm(b, term)
+	}
+	for _, term := range denominator {
+		mo(b, "/")
+		writeMathMLUnitTerm(b, term)
+	}
+	b.WriteString("</mrow>")
+}
+
+func writeMathMLUnitTerm(b *strings.Builder, term unit.Term) {
+	symbol := `<mi mathvariant="normal">` + html.EscapeString(term.Symbol) + "</mi>"
+	if term.Power == 1 {
+		b.WriteString(symbol)
+		return
+	}
+	b.WriteString("<msup>" + symbol + "<mn>" + strconv.Itoa(term.Power) + "</mn></msup>")
+}
+
+// writeMathMLNumber записывает число, порядок - степенью десяти
+func writeMathMLNumber(b *strings.Builder, text string) {
+	mantissa, exponent, ok := strings.Cut(text, "e")
+	if !ok || hasBasePrefix(text) {
+		b.WriteString("<mn>" + text + "</mn>")
+		return
+	}
+	b.WriteString("<mrow><mn>" + mantissa + "</mn>")
+	mo(b, "⋅")
+	b.WriteString("<msup><mn>10</mn>")
+	if digits, ok := strings.CutPrefix(exponent, "-"); ok {
+		b.WriteString("<mrow><mo>−</mo><mn>" + digits + "</mn></mrow>")
+	} else {
+		b.WriteString("<mn>" + exponent + "</mn>")
+	}
+	b.WriteString("</msup></mrow>")
+}
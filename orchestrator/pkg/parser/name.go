@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// ValidateName проверяет, что имя можно использовать для переменной или функции пользователя:
+// оно должно быть идентификатором и не совпадать со встроенной функцией или ссылкой на задачу
+func ValidateName(name string) error {
+	if !IsIdentifier(name) && !wordOperators[name] {
+		return fmt.Errorf("%w: %q", errors.ErrInvalidName, name)
+	}
+
+	if wordOperators[name] {
+		return fmt.Errorf("%w: %q - оператор", errors.ErrReservedName, name)
+	}
+
+	if name == conditionalFunction {
+		return fmt.Errorf("%w: %q - имя встроенной функции", errors.ErrReservedName, name)
+	}
+
+	if name == imaginaryUnit {
+		return fmt.Errorf("%w: %q - мнимая единица", errors.ErrReservedName, name)
+	}
+
+	if _, ok := shared.UnaryFunctions[name]; ok || shared.AggregateFunctions[name] || shared.MatrixFunctions[name] || shared.IterationFunctions[name] {
+		return fmt.Errorf("%w: %q - имя встроенной функции", errors.ErrReservedName, name)
+	}
+
+	// Оркестратор ссылается на результаты задач как на idN
+	if digits, ok := strings.CutPrefix(name, "id"); ok && digits != "" && strings.Trim(digits, "0123456789") == "" {
+		return fmt.Errorf("%w: %q - ссылка на задачу", errors.ErrReservedName, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package parser
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"x", nil},
+		{"rate_2", nil},
+		{"id", nil},
+		{"idx", nil},
+		{"1x", errors.ErrInvalidName},
+		{"a b", errors.ErrInvalidName},
+		{"", errors.ErrInvalidName},
+		{"sqrt", errors.ErrReservedName},
+		{"max", errors.ErrReservedName},
+		{"id12", errors.ErrReservedName},
+		{"xor", errors.ErrReservedName},
+		{"i", errors.ErrReservedName},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateName(tt.name); !stderrors.Is(err, tt.err) {
+			t.Errorf("ValidateName(%q) = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Наибольшее целое, которое float64 представляет точно
+const maxExactInteger = 1 << 53
+
+// Мнимая единица. Её имя нельзя использовать для переменных и функций
+const imaginaryUnit = "i"
+
+// scanNumber возвращает конец числового литерала, начинающегося с позиции pos.
+// Поддерживаются десятичные числа (12, 1.5, .5, 5.), экспоненциальная запись
+// (1.5e-3, 2E10), мнимые числа (2i, 1e3i) и целые с префиксом основания: 0xFF, 0b1010, 0o17.
+// Десятичный разделитель и разделители групп разрядов (1,234.5 или 1.234,5) задаёт локаль
+func scanNumber(input string, pos int, locale Locale) int {
+	end := pos
+	if hasBasePrefix(input[pos:]) {
+		end += 2
+		for end < len(input) && isAlnum(input[end]) {
+			end++
+		}
+		return end
+	}
+
+	// Группы разрядов разделяются только в целой части, первая группа - не длиннее трёх цифр
+	for end < len(input) && isDigit(rune(input[end])) {
+		end++
+	}
+	if end-pos <= 3 {
+		for {
+			size, ok := locale.isGroup(input, end)
+			if !ok {
+				break
+			}
+			end += size + 3
+		}
+	}
+
+	for end < len(input) && (isDigit(rune(input[end])) || locale.isDecimal(input, end)) {
+		end++
+	}
+	end = scanExponent(input, end)
+
+	// Суффикс i делает число мнимым, если за ним не продолжается идентификатор
+	if end < len(input) && input[end] == 'i' && (end+1 == len(input) || !isAlnum(input[end+1])) {
+		end++
+	}
+	return end
+}
+
+// scanExponent возвращает конец порядка числа (e-3, E+10), начинающегося с позиции pos.
+// Если после e нет цифр, порядка нет: e остаётся отдельным токеном
+func scanExponent(input string, pos int) int {
+	if pos >= len(input) || input[pos] != 'e' && input[pos] != 'E' {
+		return pos
+	}
+	end := pos + 1
+	if end < len(input) && (input[end] == '+' || input[end] == '-') {
+		end++
+	}
+	if end >= len(input) || !isDigit(rune(input[end])) {
+		return pos
+	}
+	for end < len(input) && isDigit(rune(input[end])) {
+		end++
+	}
+	return end
+}
+
+// parseNumber переводит числовой литерал, найденный scanNumber, в число
+func parseNumber(text string) (float64, error) {
+	if hasBasePrefix(text) {
+		value, err := strconv.ParseUint(text, 0, 64)
+		if err != nil {
+			return 0, err
+		}
+		if value > maxExactInteger {
+			return 0, strconv.ErrRange
+		}
+		return float64(value), nil
+	}
+
+	// Слишком большие литералы (1e400) ParseFloat отклоняет с ошибкой strconv.ErrRange
+	return strconv.ParseFloat(strings.TrimSuffix(text, imaginaryUnit), 64)
+}
+
+// isImaginary проверяет, записан ли литерал мнимым числом: 2i
+func isImaginary(text string) bool {
+	return !hasBasePrefix(text) && strings.HasSuffix(text, imaginaryUnit)
+}
+
+func hasBasePrefix(s string) bool {
+	if len(s) < 2 || s[0] != '0' {
+		return false
+	}
+	return strings.ContainsRune("xXbBoO", rune(s[1]))
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(rune(c)) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
@@ -0,0 +1,542 @@
+package parser
+
+import (
+	"strconv"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/unit"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Сила связывания бинарных операторов: чем больше, тем раньше выполняется операция
+var infixPower = map[string]int{
+	"or":  1,
+	"and": 2,
+	"<":   3,
+	">":   3,
+	"<=":  3,
+	">=":  3,
+	"==":  3,
+	"!=":  3,
+	"|":   4,
+	"xor": 5,
+	"&":   6,
+	"<<":  8,
+	">>":  8,
+	"+":   10,
+	"-":   10,
+	"*":   20,
+	"×":   20,
+	"·":   20,
+	"x":   20, // только между числами: 3 x 4
+	"/":   20,
+	"//":  20,
+	"%":   20,
+	"@":   20,
+	"^":   40,
+	"**":  40,
+}
+
+// Правоассоциативные операторы: 2^3^2 = 2^(3^2)
+var rightAssoc = map[string]bool{
+	"^":  true,
+	"**": true,
+}
+
+// Операция, которой соответствует оператор в синтаксическом дереве.
+// Операторы из нескольких символов записываются одним символом, как и в задачах
+var operations = map[string]rune{
+	"+":   '+',
+	"-":   '-',
+	"*":   '*',
+	"×":   '*',
+	"·":   '*',
+	"x":   '*',
+	"/":   '/',
+	"@":   '@',
+	"^":   '^',
+	"**":  '^',
+	"//":  '÷',
+	"%":   '%',
+	"&":   '&',
+	"|":   '|',
+	"xor": '⊕',
+	"<<":  '≪',
+	">>":  '≫',
+	"<":   '<',
+	">":   '>',
+	"<=":  '≤',
+	">=":  '≥',
+	"==":  '=',
+	"!=":  '≠',
+	"and": '∧',
+	"or":  '∨',
+}
+
+// Сила связывания унарных плюса и минуса - выше умножения, но ниже степени: -2^2 = -(2^2)
+const prefixPower = 30
+
+// Сила связывания not - ниже сравнений, но выше and и or: not a == b and c = (not (a == b)) and c
+const notPower = 2
+
+// Сила связывания постфиксного процента - выше степени: 2^10% = 2^(10%), -5% = -(5%)
+const percentPower = 50
+
+// Сила связывания ± - выше умножения, но ниже унарного минуса: 2 * 5 ± 0.1 = 2 * (5 ± 0.1),
+// -5 ± 0.1 - величина со средним -5
+const uncertainPower = 25
+
+// Сила связывания неявного умножения 2(3+4) или 2x - как у явного: 1/2x = (1/2)*x
+const implicitPower = 20
+
+// Встроенная функция условия: if(условие, значение если истинно, значение если ложно)
+const conditionalFunction = "if"
+
+// Оператор перевода в другие единицы: 3 km / 20 min to km/h. Связывает слабее всех
+// и разбирается только на верхнем уровне, как и тернарный оператор
+const conversionKeyword = "to"
+
+const expectedOperand = "число, переменная или '('"
+
+type parser struct {
+	tokens []Token
+	pos    int
+	locale Locale
+	names  map[string]bool // известные переменные: такое имя после числа - множитель, а не единица
+}
+
+// Parse разбирает выражение методом Пратта и возвращает его синтаксическое дерево.
+// Ошибки разбора возвращаются в виде *errors.SyntaxError
+func Parse(input string) (Node, error) {
+	tokens, err := Tokenize(input, DefaultLocale)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, locale: DefaultLocale, names: scriptNames(tokens, nil)}
+	node, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	// После разбора всего выражения не должно остаться токенов
+	switch tok := p.peek(); tok.Kind {
+	case EOF:
+		return node, nil
+	case RParen:
+		return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Err: errors.ErrMismatchedParentheses}
+	case Comma:
+		return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: p.locale.commaHint(), Err: errors.ErrUnexpectedToken}
+	default:
+		return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "оператор", Err: errors.ErrUnexpectedToken}
+	}
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() Token {
+	tok := p.tokens[p.pos]
+	if tok.Kind != EOF {
+		p.pos++
+	}
+	return tok
+}
+
+// expression разбирает выражение, в котором все бинарные операторы связывают сильнее minPower
+func (p *parser) expression(minPower int) (Node, error) {
+	left, err := p.prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		if tok.Kind == Ident && tok.Text == conversionKeyword && p.unitAt(p.pos+1) {
+			if minPower > 0 {
+				return left, nil
+			}
+			p.next()
+			target, err := p.unit(true)
+			if err != nil {
+				return nil, err
+			}
+			left = &Conversion{Position: tok.Pos, Value: left, Unit: target}
+			continue
+		}
+		if implicitOperand(tok) {
+			if implicitPower <= minPower {
+				return left, nil
+			}
+			right, err := p.expression(implicitPower)
+			if err != nil {
+				return nil, err
+			}
+			left = &BinaryExpr{Position: tok.Pos, Op: '*', Left: left, Right: right}
+			continue
+		}
+		if tok.Kind != Operator {
+			return left, nil
+		}
+		if tok.Text == "%" && p.postfixPercent() {
+			if percentPower <= minPower {
+				return left, nil
+			}
+			p.next()
+			left = &UnaryExpr{Position: tok.Pos, Op: '%', Operand: left}
+			continue
+		}
+		if tok.Text == "±" {
+			if uncertainPower <= minPower {
+				return left, nil
+			}
+			p.next()
+			uncertain, err := p.uncertain(left, tok)
+			if err != nil {
+				return nil, err
+			}
+			left = uncertain
+			continue
+		}
+		// Тернарный оператор связывает слабее всех и разбирается только на верхнем уровне
+		if tok.Text == "?" {
+			if minPower > 0 {
+				return left, nil
+			}
+			p.next()
+			conditional, err := p.ternary(left)
+			if err != nil {
+				return nil, err
+			}
+			left = conditional
+			continue
+		}
+		power, ok := infixPower[tok.Text]
+		if !ok || power <= minPower {
+			return left, nil
+		}
+		p.next()
+
+		// Для левоассоциативных операторов правый операнд должен связывать строго сильнее,
+		// для правоассоциативных - допускается тот же оператор
+		rightPower := power
+		if rightAssoc[tok.Text] {
+			rightPower--
+		}
+		right, err := p.expression(rightPower)
+		if err != nil {
+			return nil, err
+		}
+
+		left = &BinaryExpr{Position: tok.Pos, Op: operations[tok.Text], Left: left, Right: right}
+	}
+}
+
+// implicitOperand проверяет, начинает ли токен множитель неявного умножения: переменную,
+// вызов функции или выражение в скобках. Число после операнда без оператора - ошибка: 2 3
+func implicitOperand(tok Token) bool {
+	return tok.Kind == Ident || tok.Kind == LParen
+}
+
+// postfixPercent проверяет, что '%' в текущей позиции - процент, а не остаток от деления:
+// за ним не идёт операнд. Знак, отделённый от '%' пробелом и прижатый к операнду, -
+// знак операнда. 200 + 10% и 10% - 3 - проценты, 7 % 3, 7 % (-3) и 7 % -3 - остатки
+func (p *parser) postfixPercent() bool {
+	percent, next := p.tokens[p.pos], p.tokens[p.pos+1]
+	if next.Kind == Operator && (next.Text == "-" || next.Text == "+") {
+		operand := p.tokens[p.pos+2]
+		if next.Pos > percent.Pos+len(percent.Text) && operand.Pos == next.Pos+len(next.Text) && startsOperand(operand) {
+			return false
+		}
+	}
+	return !startsOperand(next)
+}
+
+// startsOperand проверяет, что с токена начинается операнд: число, интервал,
+// имя или выражение в скобках
+func startsOperand(tok Token) bool {
+	return tok.Kind == Number || tok.Kind == LBracket || implicitOperand(tok)
+}
+
+// prefix разбирает операнд: число, выражение в скобках или унарную операцию
+func (p *parser) prefix() (Node, error) {
+	tok := p.next()
+
+	switch tok.Kind {
+	case Number:
+		value, _ := parseNumber(tok.Text)
+		if isImaginary(tok.Text) {
+			return &ImaginaryLit{Position: tok.Pos, Text: tok.Text, Value: value}, nil
+		}
+		lit := &NumberLit{Position: tok.Pos, Text: tok.Text, Value: value}
+		if p.unitAt(p.pos) {
+			quantity, err := p.unit(false)
+			if err != nil {
+				return nil, err
+			}
+			return &QuantityLit{Position: tok.Pos, Value: lit, Unit: quantity}, nil
+		}
+		return lit, nil
+	case LParen:
+		return p.parenthesized(tok)
+	case LBracket:
+		return p.vector(tok)
+	case Operator:
+		if tok.Text == "-" || tok.Text == "+" {
+			operand, err := p.expression(prefixPower)
+			if err != nil {
+				return nil, err
+			}
+			return &UnaryExpr{Position: tok.Pos, Op: rune(tok.Text[0]), Operand: operand}, nil
+		}
+		if tok.Text == "not" {
+			operand, err := p.expression(notPower)
+			if err != nil {
+				return nil, err
+			}
+			return &UnaryExpr{Position: tok.Pos, Op: '¬', Operand: operand}, nil
+		}
+		return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: expectedOperand, Err: errors.ErrNotEnoughOperands}
+	case Ident:
+		if p.peek().Kind == LParen {
+			return p.call(tok)
+		}
+		if tok.Text == imaginaryUnit {
+			return &ImaginaryLit{Position: tok.Pos, Text: tok.Text, Value: 1}, nil
+		}
+		return &Variable{Position: tok.Pos, Name: tok.Text}, nil
+	default:
+		return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: expectedOperand, Err: errors.ErrNotEnoughOperands}
+	}
+}
+
+// unitAt проверяет, что токен i - обозначение единицы измерения, а не вызов функции
+// и не известная переменная
+func (p *parser) unitAt(i int) bool {
+	tok := p.tokens[i]
+	return tok.Kind == Ident && p.tokens[i+1].Kind != LParen && !p.names[tok.Text] && unit.IsUnit(tok.Text)
+}
+
+// unit разбирает единицу измерения: обозначения со степенями через '·' и '/': km/h, kg·m/s^2.
+// Множитель после '/' делит всё, что записано до него: J/kg/K = J/(kg·K).
+// В единице после to множители можно разделять и '*', после числа '*' - умножение: 5 kg * g
+func (p *parser) unit(conversion bool) (unit.Expr, error) {
+	var expr unit.Expr
+	sign := 1
+	for {
+		symbol := p.next()
+		power, err := p.unitPower()
+		if err != nil {
+			return nil, err
+		}
+		expr = append(expr, unit.Term{Symbol: symbol.Text, Power: sign * power})
+
+		op := p.peek()
+		if op.Kind != Operator || !p.unitAt(p.pos+1) {
+			return expr, nil
+		}
+		switch {
+		case op.Text == "/":
+			sign = -1
+		case op.Text == "·" || op.Text == "*" && conversion:
+			sign = 1
+		default:
+			return expr, nil
+		}
+		p.next()
+	}
+}
+
+// unitPower разбирает показатель степени после обозначения единицы: ^2, ^-1.
+// Без показателя степень равна 1
+func (p *parser) unitPower() (int, error) {
+	if tok := p.peek(); tok.Kind != Operator || tok.Text != "^" && tok.Text != "**" {
+		return 1, nil
+	}
+	p.next()
+
+	sign := 1
+	if tok := p.peek(); tok.Kind == Operator && tok.Text == "-" {
+		p.next()
+		sign = -1
+	}
+	tok := p.next()
+	power, err := strconv.Atoi(tok.Text)
+	if tok.Kind != Number || err != nil {
+		return 0, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "целый показатель степени", Err: errors.ErrUnexpectedToken}
+	}
+	return sign * power, nil
+}
+
+// parenthesized разбирает выражение после уже прочитанной открывающей скобки open
+func (p *parser) parenthesized(open Token) (Node, error) {
+	inner, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	closing := p.next()
+	switch closing.Kind {
+	case RParen:
+		return inner, nil
+	case EOF:
+		return nil, &errors.SyntaxError{Pos: open.Pos, Token: open.Text, Expected: "')'", Err: errors.ErrMismatchedParentheses}
+	default:
+		return nil, &errors.SyntaxError{Pos: closing.Pos, Token: closing.Text, Expected: "оператор или ')'", Err: errors.ErrUnexpectedToken}
+	}
+}
+
+// vector разбирает вектор [a, b, c] после уже прочитанной открывающей скобки open
+func (p *parser) vector(open Token) (Node, error) {
+	var elements []Node
+	for {
+		element, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+
+		tok := p.next()
+		if tok.Kind == RBracket {
+			break
+		}
+		switch tok.Kind {
+		case Comma:
+			continue
+		case EOF:
+			return nil, &errors.SyntaxError{Pos: open.Pos, Token: open.Text, Expected: "']'", Err: errors.ErrMismatchedParentheses}
+		default:
+			return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "оператор, ',' или ']'", Err: errors.ErrUnexpectedToken}
+		}
+	}
+	return &VectorLit{Position: open.Pos, Elements: elements}, nil
+}
+
+// bound разбирает погрешность: вещественное число, возможно со знаком.
+// Плюс перед числом ничего не меняет и в дерево не попадает
+func (p *parser) bound() (Node, error) {
+	sign := p.next()
+	tok := sign
+	if sign.Kind == Operator && (sign.Text == "-" || sign.Text == "+") {
+		tok = p.next()
+	}
+	if tok.Kind != Number || isImaginary(tok.Text) {
+		return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "вещественное число", Err: errors.ErrUnexpectedToken}
+	}
+
+	value, _ := parseNumber(tok.Text)
+	lit := &NumberLit{Position: tok.Pos, Text: tok.Text, Value: value}
+	if sign.Text == "-" {
+		return &UnaryExpr{Position: sign.Pos, Op: '-', Operand: lit}, nil
+	}
+	return lit, nil
+}
+
+// uncertain разбирает погрешность после уже прочитанного знака ± и собирает величину
+// с погрешностью. Среднее mean - уже разобранный левый операнд, он должен быть числом
+func (p *parser) uncertain(mean Node, sign Token) (Node, error) {
+	if n, ok := mean.(*UnaryExpr); ok && n.Op == '+' {
+		mean = n.Operand
+	}
+	if !isSignedNumber(mean) {
+		return nil, &errors.SyntaxError{Pos: sign.Pos, Token: sign.Text, Expected: "число перед '±'", Err: errors.ErrUnexpectedToken}
+	}
+
+	sigma, err := p.bound()
+	if err != nil {
+		return nil, err
+	}
+	if BoundValue(sigma) < 0 {
+		return nil, &errors.SyntaxError{Pos: sigma.Pos(), Token: Format(sigma), Err: errors.ErrNegativeUncertainty}
+	}
+	return &UncertainLit{Position: sign.Pos, Mean: mean, Sigma: sigma}, nil
+}
+
+// isSignedNumber проверяет, что узел - вещественный литерал, возможно с унарным минусом
+func isSignedNumber(node Node) bool {
+	if n, ok := node.(*UnaryExpr); ok && n.Op == '-' {
+		node = n.Operand
+	}
+	_, ok := node.(*NumberLit)
+	return ok
+}
+
+// BoundValue возвращает значение числового литерала, возможно с унарным минусом:
+// среднего и погрешности величины с погрешностью
+func BoundValue(bound Node) float64 {
+	if n, ok := bound.(*UnaryExpr); ok {
+		value := n.Operand.(*NumberLit).Value
+		if n.Op == '-' {
+			return -value
+		}
+		return value
+	}
+	return bound.(*NumberLit).Value
+}
+
+// ternary разбирает ветки тернарного оператора cond ? a : b после уже прочитанного '?'.
+// Ветка else разбирается целиком, поэтому a ? b : c ? d : e = a ? b : (c ? d : e)
+func (p *parser) ternary(cond Node) (Node, error) {
+	then, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.next(); tok.Kind != Operator || tok.Text != ":" {
+		return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "':'", Err: errors.ErrUnexpectedToken}
+	}
+
+	otherwise, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	return &Conditional{Position: cond.Pos(), Cond: cond, Then: then, Else: otherwise}, nil
+}
+
+// call разбирает вызов функции name(x, y, ...). Существование функции и число
+// её аргументов здесь не проверяются: функции бывают и пользовательскими.
+// Исключение - if, который разбирается в узел условия
+func (p *parser) call(name Token) (Node, error) {
+	open := p.next()
+	var args []Node
+
+	// Функция без аргументов: f()
+	if p.peek().Kind == RParen {
+		p.next()
+		return callExpr(name, args)
+	}
+
+	for {
+		arg, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		tok := p.next()
+		if tok.Kind == RParen {
+			break
+		}
+		switch tok.Kind {
+		case Comma:
+			continue
+		case EOF:
+			return nil, &errors.SyntaxError{Pos: open.Pos, Token: open.Text, Expected: "')'", Err: errors.ErrMismatchedParentheses}
+		default:
+			return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "оператор, ',' или ')'", Err: errors.ErrUnexpectedToken}
+		}
+	}
+
+	return callExpr(name, args)
+}
+
+// callExpr создаёт узел вызова функции. Вызов if(cond, a, b) становится узлом условия
+func callExpr(name Token, args []Node) (Node, error) {
+	if name.Text != conditionalFunction {
+		return &CallExpr{Position: name.Pos, Name: name.Text, Args: args}, nil
+	}
+	if len(args) != 3 {
+		return nil, &errors.SyntaxError{Pos: name.Pos, Token: name.Text, Expected: "3 аргумента", Err: errors.ErrArgumentCount}
+	}
+	return &Conditional{Position: name.Pos, Cond: args[0], Then: args[1], Else: args[2]}, nil
+}
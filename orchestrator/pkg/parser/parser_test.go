@@ -0,0 +1,245 @@
+package parser
+
+import (
+	stderrors "errors"
+	"math"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+func TestParsePrecedence(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"2 + 3 * 4", 14},
+		{"(2 + 3) * 4", 20},
+		{"10 - 4 - 3", 3},
+		{"100 / 10 / 5", 2},
+		{"-2 * 3", -6},
+		{"2 ^ 3 ^ 2", 512},
+		{"2 ** 3", 8},
+		{"-2 ^ 2", -4},
+		{"(-2) ^ 2", 4},
+		{"2 ^ -1", 0.5},
+		{"- -3", 3},
+		{"7 % 3", 1},
+		{"-7 % 2", 1},
+		{"7 // 2", 3},
+		{"-7 // 2", -4},
+		{"1 << 2 + 1", 8},
+		{"6 & 3 | 8", 10},
+		{"6 xor 3", 5},
+		{"0x1F + 0b101 + 0o17", 51},
+		{"1.5e-3 * 2E3", 3},
+		{".5 + 5.", 5.5},
+		{"sqrt(16) + 1", 5},
+		{"-abs(2 - 5)", -3},
+		{"1 + 1 == 2", 1},
+		{"1 != 1", 0},
+		{"2 < 1 or 3 >= 3", 1},
+		{"1 <= 0 and 1", 0},
+		{"not 1 == 2 and 1", 1},
+		{"if(1 > 2, 10, 20)", 20},
+		{"1 ? 2 : 3 + 1", 2},
+		{"0 ? 1 : 1 ? 2 : 3", 2},
+		{"2,5 + 1", 3.5},
+		{"3 x 4", 12},
+		{"2 × 3 · 4", 24},
+		{"2x + 1", 7},
+		{"1 / 2x", 1.5},
+		{"2x ^ 2", 18},
+		{"-2x", -6},
+		{"2(3 + 4)", 14},
+		{"(1 + 1)(2 + 2)", 8},
+		{"2 sqrt(9)", 6},
+		{"10%", 0.1},
+		{"200 + 10%", 220},
+		{"200 - 10%", 180},
+		{"50 * 10%", 5},
+		{"50 / 10%", 500},
+		{"4 ^ 50%", 2},
+		{"-5%", -0.05},
+		{"10% - 3", -2.9},
+		{"10%-3", -2.9},
+		{"7 % -3", -2},
+		{"7 % (-3)", -2},
+		{"(1 + 1)% * 100", 2},
+	}
+
+	for _, tt := range tests {
+		node, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if got := evaluate(t, node); got != tt.want {
+			t.Errorf("Parse(%q) = %s = %v, want %v", tt.input, Format(node), got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		err   error
+	}{
+		{"", 0, errors.ErrNotEnoughOperands},
+		{"1 +", 3, errors.ErrNotEnoughOperands},
+		{"(1 + 2", 0, errors.ErrMismatchedParentheses},
+		{"1 + 2)", 5, errors.ErrMismatchedParentheses},
+		{"2 3", 2, errors.ErrUnexpectedToken},
+		{"1 $ 2", 2, errors.ErrInvalidNumber},
+		{"sqrt 4", 5, errors.ErrUnexpectedToken},
+		{"max(1, 2", 3, errors.ErrMismatchedParentheses},
+		{"0x1G", 0, errors.ErrInvalidNumber},
+		{"1e400", 0, errors.ErrNumberOutOfRange},
+		{"1 + 0x20000000000001", 4, errors.ErrNumberOutOfRange},
+		{"if(1, 2)", 0, errors.ErrArgumentCount},
+		{"1 ? 2", 5, errors.ErrUnexpectedToken},
+		{"[1, 2", 0, errors.ErrMismatchedParentheses},
+		{"[1 2]", 3, errors.ErrUnexpectedToken},
+		{"(1 + 2) ± 1", 8, errors.ErrUnexpectedToken},
+		{"5 ± -1", 5, errors.ErrNegativeUncertainty},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		var syntaxErr *errors.SyntaxError
+		if !stderrors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) = %v, want *errors.SyntaxError", tt.input, err)
+			continue
+		}
+		if !stderrors.Is(err, tt.err) || syntaxErr.Pos != tt.pos {
+			t.Errorf("Parse(%q) = %v, want %v at %d", tt.input, err, tt.err, tt.pos)
+		}
+	}
+}
+
+func TestParseImaginary(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"2i", 2},
+		{"1.5i", 1.5},
+		{"1e3i", 1000},
+		{"i", 1},
+	}
+
+	for _, tt := range tests {
+		node, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if lit, ok := node.(*ImaginaryLit); !ok || lit.Value != tt.want {
+			t.Errorf("Parse(%q) = %#v, want мнимый литерал %v", tt.input, node, tt.want)
+		}
+	}
+
+	// Суффикс i не отрывается от идентификатора: 2if - это 2 * if, а не 2i и f
+	if node, err := Parse("2if"); err != nil || Format(node) != "2 * if" {
+		t.Errorf("Parse(%q) = %v, %v, want 2 * if", "2if", node, err)
+	}
+}
+
+// evaluate вычисляет дерево выражения над float64, переменные равны 3
+func evaluate(t *testing.T, node Node) float64 {
+	t.Helper()
+
+	switch n := node.(type) {
+	case *NumberLit:
+		return n.Value
+	case *Variable:
+		// Неявное умножение 2x проверяется при x = 3
+		return 3
+	case *UnaryExpr:
+		x := evaluate(t, n.Operand)
+		switch n.Op {
+		case '-':
+			return -x
+		case '+':
+			return x
+		case '¬':
+			return boolean(x == 0)
+		case '%':
+			return x / 100
+		}
+	case *BinaryExpr:
+		// Процент справа от сложения и вычитания берётся от левого операнда
+		if percent, ok := n.Right.(*UnaryExpr); ok && percent.Op == '%' && (n.Op == '+' || n.Op == '-') {
+			x, p := evaluate(t, n.Left), evaluate(t, percent.Operand)
+			if n.Op == '-' {
+				p = -p
+			}
+			return x * (100 + p) / 100
+		}
+		x, y := evaluate(t, n.Left), evaluate(t, n.Right)
+		switch n.Op {
+		case '+':
+			return x + y
+		case '-':
+			return x - y
+		case '*':
+			return x * y
+		case '/':
+			return x / y
+		case '^':
+			return math.Pow(x, y)
+		case '÷':
+			return math.Floor(x / y)
+		case '%':
+			return x - y*math.Floor(x/y)
+		case '&':
+			return float64(int64(x) & int64(y))
+		case '|':
+			return float64(int64(x) | int64(y))
+		case '⊕':
+			return float64(int64(x) ^ int64(y))
+		case '≪':
+			return float64(int64(x) << int64(y))
+		case '≫':
+			return float64(int64(x) >> int64(y))
+		case '<':
+			return boolean(x < y)
+		case '>':
+			return boolean(x > y)
+		case '≤':
+			return boolean(x <= y)
+		case '≥':
+			return boolean(x >= y)
+		case '=':
+			return boolean(x == y)
+		case '≠':
+			return boolean(x != y)
+		case '∧':
+			return boolean(x != 0 && y != 0)
+		case '∨':
+			return boolean(x != 0 || y != 0)
+		}
+	case *Conditional:
+		if evaluate(t, n.Cond) != 0 {
+			return evaluate(t, n.Then)
+		}
+		return evaluate(t, n.Else)
+	case *CallExpr:
+		x, err := shared.UnaryFunctions[n.Name](evaluate(t, n.Args[0]))
+		if err != nil {
+			t.Fatalf("evaluate: %v", err)
+		}
+		return x
+	}
+	t.Fatalf("evaluate: не поддерживается %T", node)
+	return 0
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,225 @@
+package parser
+
+import (
+	stderrors "errors"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Statement - инструкция сценария: присваивание name = выражение
+// или выражение без имени. Name пуст, если инструкция не присваивание
+type Statement struct {
+	Position int    `json:"pos"`
+	Name     string `json:"name,omitempty"`
+	Value    Node   `json:"value"`
+}
+
+// ParseScript разбирает сценарий - инструкции, разделённые ';':
+// a = 3*4; b = a + 7; b / 2. Выражение без присваиваний - сценарий из одной инструкции.
+// Выражение без имени допускается только последней инструкцией. Каждое имя
+// присваивается один раз и используется только после своего присваивания.
+// Числа записываются по правилам локали. known - имена переменных, известных снаружи сценария:
+// сохранённые переменные пользователя, переменная графика или производной.
+// Ошибки разбора возвращаются в виде *errors.SyntaxError
+func ParseScript(input string, locale Locale, known ...string) ([]Statement, error) {
+	tokens, err := Tokenize(input, locale)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, locale: locale, names: scriptNames(tokens, known)}
+
+	var statements []Statement
+	for {
+		// Пустые инструкции (";;" или ';' в конце) пропускаются
+		for p.peek().Kind == Semicolon {
+			p.next()
+		}
+		if p.peek().Kind == EOF && len(statements) > 0 {
+			break
+		}
+
+		statement, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+
+		switch tok := p.peek(); tok.Kind {
+		case Semicolon, EOF:
+		case RParen:
+			return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Err: errors.ErrMismatchedParentheses}
+		case Comma:
+			return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: p.locale.commaHint(), Err: errors.ErrUnexpectedToken}
+		default:
+			return nil, &errors.SyntaxError{Pos: tok.Pos, Token: tok.Text, Expected: "оператор или ';'", Err: errors.ErrUnexpectedToken}
+		}
+	}
+
+	for _, statement := range statements[:len(statements)-1] {
+		if statement.Name == "" {
+			return nil, &errors.SyntaxError{Pos: statement.Position, Expected: "присваивание name = выражение", Err: errors.ErrUnusedStatement}
+		}
+	}
+	if err := checkAssignments(statements); err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+// scriptNames собирает имена переменных, которые после числа означают множитель, а не единицу:
+// известные снаружи known, присваиваемые в сценарии и связанные переменные интегралов и рядов.
+// Имена собираются по токенам ещё до разбора: в integrate(2t, t, 0, 1) переменная t
+// встречается в теле раньше, чем становится известно, что она связанная
+func scriptNames(tokens []Token, known []string) map[string]bool {
+	names := make(map[string]bool, len(known))
+	for _, name := range known {
+		names[name] = true
+	}
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].Kind == Ident && tokens[i+1].Kind == Assign {
+			names[tokens[i].Text] = true
+		}
+		if name, ok := boundName(tokens, i); ok {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// boundName возвращает связанную переменную интеграла integrate(f, x, a, b) или ряда
+// sum(k, a, b, f), вызов которого начинается с токена i. Как и IterationOf, вызов
+// из четырёх аргументов, у которого на месте переменной стоит одно имя
+func boundName(tokens []Token, i int) (string, bool) {
+	if tokens[i].Kind != Ident || tokens[i+1].Kind != LParen {
+		return "", false
+	}
+	index := 0
+	switch tokens[i].Text {
+	case "integrate":
+		index = 1
+	case "sum":
+	default:
+		return "", false
+	}
+
+	// Начала аргументов вызова: запятые во вложенных скобках аргументы не разделяют
+	args := []int{i + 2}
+	// Глубина -1 - скобка вызова закрыта
+	for j, depth := i+2, 0; j < len(tokens) && depth >= 0; j++ {
+		switch tokens[j].Kind {
+		case LParen, LBracket:
+			depth++
+		case RParen, RBracket:
+			depth--
+		case Comma:
+			if depth == 0 {
+				args = append(args, j+1)
+			}
+		}
+	}
+
+	if len(args) != 4 {
+		return "", false
+	}
+	start := args[index]
+	if tokens[start].Kind != Ident || tokens[start+1].Kind != Comma {
+		return "", false
+	}
+	return tokens[start].Text, true
+}
+
+// statement разбирает одну инструкцию: присваивание или выражение
+func (p *parser) statement() (Statement, error) {
+	name := p.peek()
+	if name.Kind == Ident && p.tokens[p.pos+1].Kind == Assign {
+		if err := ValidateName(name.Text); err != nil {
+			cause := errors.ErrInvalidName
+			if stderrors.Is(err, errors.ErrReservedName) {
+				cause = errors.ErrReservedName
+			}
+			return Statement{}, &errors.SyntaxError{Pos: name.Pos, Token: name.Text, Expected: "имя переменной", Err: cause}
+		}
+		p.next()
+		p.next()
+
+		value, err := p.expression(0)
+		if err != nil {
+			return Statement{}, err
+		}
+		return Statement{Position: name.Pos, Name: name.Text, Value: value}, nil
+	}
+
+	value, err := p.expression(0)
+	if err != nil {
+		return Statement{}, err
+	}
+	return Statement{Position: value.Pos(), Value: value}, nil
+}
+
+// checkAssignments проверяет, что каждое имя присвоено один раз
+// и не используется в своей инструкции или до неё
+func checkAssignments(statements []Statement) error {
+	assigned := make(map[string]int)
+	for i, statement := range statements {
+		if statement.Name == "" {
+			continue
+		}
+		if _, ok := assigned[statement.Name]; ok {
+			return &errors.SyntaxError{Pos: statement.Position, Token: statement.Name, Expected: "новое имя переменной", Err: errors.ErrReassignment}
+		}
+		assigned[statement.Name] = i
+	}
+
+	for i, statement := range statements {
+		var err error
+		walkVariables(statement.Value, func(v *Variable) {
+			if at, ok := assigned[v.Name]; ok && at >= i && err == nil {
+				err = &errors.SyntaxError{Pos: v.Position, Token: v.Name, Err: errors.ErrUsedBeforeAssignment}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkVariables вызывает visit для каждой ссылки на переменную в дереве
+func walkVariables(node Node, visit func(*Variable)) {
+	switch n := node.(type) {
+	case *Variable:
+		visit(n)
+	case *UnaryExpr:
+		walkVariables(n.Operand, visit)
+	case *BinaryExpr:
+		walkVariables(n.Left, visit)
+		walkVariables(n.Right, visit)
+	case *CallExpr:
+		it, iteration := IterationOf(n)
+		for i, arg := range n.Args {
+			switch {
+			case !iteration:
+				walkVariables(arg, visit)
+			case i == it.Body:
+				// Связанная переменная в теле - не ссылка на переменную снаружи
+				walkVariables(arg, func(v *Variable) {
+					if v.Name != it.Variable {
+						visit(v)
+					}
+				})
+			case i != it.Index:
+				walkVariables(arg, visit)
+			}
+		}
+	case *Conditional:
+		walkVariables(n.Cond, visit)
+		walkVariables(n.Then, visit)
+		walkVariables(n.Else, visit)
+	case *Conversion:
+		walkVariables(n.Value, visit)
+	case *VectorLit:
+		for _, element := range n.Elements {
+			walkVariables(element, visit)
+		}
+	}
+}
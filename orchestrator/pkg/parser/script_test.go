@@ -0,0 +1,91 @@
+package parser
+
+import (
+	stderrors "errors"
+	"slices"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+func TestParseScript(t *testing.T) {
+	tests := []struct {
+		input string
+		names []string
+	}{
+		{"1 + 2", []string{""}},
+		{"a = 3*4; b = a + 7; b / 2", []string{"a", "b", ""}},
+		{"a = 1;; b = a;", []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		statements, err := ParseScript(tt.input, DefaultLocale)
+		if err != nil {
+			t.Errorf("ParseScript(%q): %v", tt.input, err)
+			continue
+		}
+		var names []string
+		for _, statement := range statements {
+			names = append(names, statement.Name)
+		}
+		if !slices.Equal(names, tt.names) {
+			t.Errorf("ParseScript(%q) = %q, want %q", tt.input, names, tt.names)
+		}
+	}
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		err   error
+	}{
+		{"a = 1; a = 2", 7, errors.ErrReassignment},
+		{"x = x + 1", 4, errors.ErrUsedBeforeAssignment},
+		{"b = a; a = 1; b", 4, errors.ErrUsedBeforeAssignment},
+		{"1 + 2; a = 3", 0, errors.ErrUnusedStatement},
+		{"sqrt = 4; sqrt", 0, errors.ErrReservedName},
+		{"a = 1 2", 6, errors.ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseScript(tt.input, DefaultLocale)
+		var syntaxErr *errors.SyntaxError
+		if !stderrors.As(err, &syntaxErr) || !stderrors.Is(err, tt.err) || syntaxErr.Pos != tt.pos {
+			t.Errorf("ParseScript(%q) = %v, want %v at %d", tt.input, err, tt.err, tt.pos)
+		}
+	}
+}
+
+func TestParseScriptNames(t *testing.T) {
+	tests := []struct {
+		input string
+		known []string
+		want  string
+	}{
+		// Без известной переменной h после числа - единица, с ней - множитель
+		{"2h", nil, "2 h"},
+		{"2h", []string{"h"}, "2 * h"},
+		{"x = 3; 2x + 1", nil, "x = 3; 2 * x + 1"},
+		{"m = 2; 3m", nil, "m = 2; 3 * m"},
+		{"3t^2", []string{"t"}, "3 * t ^ 2"},
+		{"2 min(1, 3)", nil, "2 * min(1, 3)"},
+		// Связанная переменная интеграла и ряда - множитель и в теле, записанном до неё
+		{"sum(t, 1, 3, 2t)", nil, "sum(t, 1, 3, 2 * t)"},
+		{"integrate(2t, t, 0, 1)", nil, "integrate(2 * t, t, 0, 1)"},
+		{"integrate(max(2t, 1), t, 0, 1)", nil, "integrate(max(2 * t, 1), t, 0, 1)"},
+		// Вызов из трёх аргументов - не ряд
+		{"sum(t, 1, 2t)", nil, "sum(t, 1, 2 t)"},
+	}
+
+	for _, tt := range tests {
+		statements, err := ParseScript(tt.input, DefaultLocale, tt.known...)
+		if err != nil {
+			t.Errorf("ParseScript(%q, %q): %v", tt.input, tt.known, err)
+			continue
+		}
+		if got := FormatScript(statements); got != tt.want {
+			t.Errorf("ParseScript(%q, %q) = %q, want %q", tt.input, tt.known, got, tt.want)
+		}
+	}
+}
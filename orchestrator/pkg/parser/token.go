@@ -0,0 +1,25 @@
+package parser
+
+// Kind - вид токена
+type Kind int
+
+const (
+	EOF Kind = iota
+	Number
+	Ident
+	Operator
+	LParen
+	RParen
+	Comma
+	Assign
+	Semicolon // разделитель инструкций сценария
+	LBracket  // скобки интервала [lo, hi]
+	RBracket
+)
+
+// Token - минимальная значимая единица выражения
+type Token struct {
+	Kind Kind
+	Text string // текст токена в том виде, в каком он записан в выражении; числа - в записи с точкой
+	Pos  int    // смещение в байтах от начала выражения
+}
@@ -0,0 +1,329 @@
+package symbolic
+
+import (
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Наибольшее число узлов в дереве, которое можно дифференцировать. Подстановка
+// переменных сценария может удваивать дерево на каждой инструкции
+const maxNodes = 10000
+
+// Derive находит производную выражения по переменной variable и упрощает её.
+// Остальные переменные считаются постоянными. Операции, у которых нет производной
+// (сравнения, целочисленные и побитовые операции, min, max, median, пределы ряда), допускаются только
+// в частях выражения, не зависящих от переменной; иначе возвращается *errors.SyntaxError
+func Derive(node parser.Node, variable string) (parser.Node, error) {
+	if parser.Size(node, maxNodes) > maxNodes {
+		return nil, &errors.SyntaxError{Pos: node.Pos(), Expected: fmt.Sprintf("не больше %d узлов дерева", maxNodes), Err: errors.ErrExpressionTooLarge}
+	}
+	d := &deriver{variable: variable}
+	return d.derive(node)
+}
+
+type deriver struct {
+	variable string
+}
+
+func (d *deriver) derive(node parser.Node) (parser.Node, error) {
+	if !d.depends(node) {
+		return number(new(big.Rat)), nil
+	}
+
+	switch n := node.(type) {
+	case *parser.Variable:
+		return number(big.NewRat(1, 1)), nil
+	case *parser.UnaryExpr:
+		du, err := d.derive(n.Operand)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Op {
+		case '+':
+			return du, nil
+		case '-':
+			return neg(du), nil
+		case '%':
+			return div(du, number(big.NewRat(100, 1))), nil
+		}
+	case *parser.BinaryExpr:
+		return d.binary(n)
+	case *parser.CallExpr:
+		return d.call(n)
+	case *parser.Conditional:
+		// Условие задаёт кусочную функцию: производная берётся в каждой ветке отдельно
+		then, err := d.derive(n.Then)
+		if err != nil {
+			return nil, err
+		}
+		otherwise, err := d.derive(n.Else)
+		if err != nil {
+			return nil, err
+		}
+		return conditional(Simplify(n.Cond), then, otherwise), nil
+	case *parser.VectorLit:
+		// Производная вектора берётся поэлементно
+		elements := make([]parser.Node, len(n.Elements))
+		for i, element := range n.Elements {
+			derivative, err := d.derive(element)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = derivative
+		}
+		return &parser.VectorLit{Elements: elements}, nil
+	}
+	return nil, d.notDifferentiable(node)
+}
+
+func (d *deriver) binary(n *parser.BinaryExpr) (parser.Node, error) {
+	switch n.Op {
+	case '+', '-', '*', '/', '^':
+	default:
+		return nil, d.notDifferentiable(n)
+	}
+
+	u, v := Simplify(n.Left), Simplify(n.Right)
+	du, err := d.derive(n.Left)
+	if err != nil {
+		return nil, err
+	}
+	dv, err := d.derive(n.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op {
+	case '+':
+		return add(du, dv), nil
+	case '-':
+		return sub(du, dv), nil
+	case '*':
+		// (uv)' = u'v + uv'
+		return add(mul(du, v), mul(u, dv)), nil
+	case '/':
+		// (u/v)' = (u'v - uv') / v^2; при постоянном знаменателе - u' / v
+		if !d.depends(n.Right) {
+			return div(du, v), nil
+		}
+		return div(sub(mul(du, v), mul(u, dv)), pow(v, number(big.NewRat(2, 1)))), nil
+	}
+
+	// (u^c)' = c * u^(c-1) * u'
+	if !d.depends(n.Right) {
+		return mul(mul(v, pow(u, sub(v, number(big.NewRat(1, 1))))), du), nil
+	}
+	// (c^v)' = c^v * ln(c) * v'
+	if !d.depends(n.Left) {
+		return mul(mul(pow(u, v), call("ln", u)), dv), nil
+	}
+	// (u^v)' = u^v * (v' * ln(u) + v * u' / u)
+	return mul(pow(u, v), add(mul(dv, call("ln", u)), div(mul(v, du), u))), nil
+}
+
+func (d *deriver) call(n *parser.CallExpr) (parser.Node, error) {
+	if it, ok := parser.IterationOf(n); ok {
+		return d.iteration(n, it)
+	}
+
+	args := make([]parser.Node, len(n.Args))
+	derivatives := make([]parser.Node, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = Simplify(arg)
+		derivative, err := d.derive(arg)
+		if err != nil {
+			return nil, err
+		}
+		derivatives[i] = derivative
+	}
+
+	switch n.Name {
+	case "sum":
+		result := derivatives[0]
+		for _, derivative := range derivatives[1:] {
+			result = add(result, derivative)
+		}
+		return result, nil
+	case "avg":
+		result := derivatives[0]
+		for _, derivative := range derivatives[1:] {
+			result = add(result, derivative)
+		}
+		return div(result, number(big.NewRat(int64(len(derivatives)), 1))), nil
+	}
+
+	if len(args) != 1 {
+		return nil, d.notDifferentiable(n)
+	}
+	u, du := args[0], derivatives[0]
+	two := number(big.NewRat(2, 1))
+
+	// Производная сложной функции: f(u)' = f'(u) * u'
+	switch n.Name {
+	case "sqrt":
+		return div(du, mul(two, call("sqrt", u))), nil
+	case "abs":
+		return div(mul(du, u), call("abs", u)), nil
+	case "ln":
+		return div(du, u), nil
+	case "log10":
+		return div(du, mul(u, call("ln", number(big.NewRat(10, 1))))), nil
+	case "exp":
+		return mul(call("exp", u), du), nil
+	case "sin":
+		return mul(call("cos", u), du), nil
+	case "cos":
+		return neg(mul(call("sin", u), du)), nil
+	case "tan":
+		return div(du, pow(call("cos", u), two)), nil
+	}
+	return nil, d.notDifferentiable(n)
+}
+
+// iteration дифференцирует интеграл и ряд под знаком: тело - по переменной, если она
+// не связанная. Интеграл с пределами, зависящими от переменной, - по правилу Лейбница:
+// (∫ f dt от a до b)' = f(b) * b' - f(a) * a' + ∫ f' dt. Пределы ряда от переменной зависеть не могут
+func (d *deriver) iteration(n *parser.CallExpr, it parser.Iteration) (parser.Node, error) {
+	args := make([]parser.Node, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = Simplify(arg)
+	}
+
+	result := number(new(big.Rat))
+	if it.Variable != d.variable && d.depends(n.Args[it.Body]) {
+		body, err := d.derive(n.Args[it.Body])
+		if err != nil {
+			return nil, err
+		}
+		inner := slices.Clone(args)
+		inner[it.Body] = body
+		result = call(n.Name, inner...)
+	}
+
+	from, to := n.Args[it.From], n.Args[it.To]
+	if !d.depends(from) && !d.depends(to) {
+		return result, nil
+	}
+	if n.Name != "integrate" {
+		return nil, d.notDifferentiable(n)
+	}
+	dfrom, err := d.derive(from)
+	if err != nil {
+		return nil, err
+	}
+	dto, err := d.derive(to)
+	if err != nil {
+		return nil, err
+	}
+	at := func(bound parser.Node) parser.Node {
+		return Simplify(substitute(args[it.Body], map[string]parser.Node{it.Variable: bound}))
+	}
+	return sub(add(result, mul(at(args[it.To]), dto)), mul(at(args[it.From]), dfrom)), nil
+}
+
+// depends проверяет, зависит ли выражение от переменной дифференцирования
+func (d *deriver) depends(node parser.Node) bool {
+	switch n := node.(type) {
+	case *parser.Variable:
+		return n.Name == d.variable
+	case *parser.UnaryExpr:
+		return d.depends(n.Operand)
+	case *parser.BinaryExpr:
+		return d.depends(n.Left) || d.depends(n.Right)
+	case *parser.CallExpr:
+		// Тело интеграла и ряда не зависит от переменной, если она там связанная
+		it, iteration := parser.IterationOf(n)
+		for i, arg := range n.Args {
+			if iteration && (i == it.Index || i == it.Body && it.Variable == d.variable) {
+				continue
+			}
+			if d.depends(arg) {
+				return true
+			}
+		}
+	case *parser.Conditional:
+		return d.depends(n.Cond) || d.depends(n.Then) || d.depends(n.Else)
+	case *parser.Conversion:
+		return d.depends(n.Value)
+	case *parser.VectorLit:
+		for _, element := range n.Elements {
+			if d.depends(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *deriver) notDifferentiable(node parser.Node) error {
+	pos, token := node.Pos(), ""
+	switch n := node.(type) {
+	case *parser.UnaryExpr:
+		token = parser.OperatorText(n.Op)
+	case *parser.BinaryExpr:
+		pos, token = n.Position, parser.OperatorText(n.Op)
+	case *parser.CallExpr:
+		token = n.Name
+	}
+	return &errors.SyntaxError{Pos: pos, Token: token, Expected: "операция, у которой есть производная по " + d.variable, Err: errors.ErrNotDifferentiable}
+}
+
+// Inline собирает сценарий в одно выражение: ссылки на переменные сценария
+// заменяются выражениями, которые им присвоены. Возвращает выражение последней инструкции
+func Inline(statements []parser.Statement) parser.Node {
+	values := make(map[string]parser.Node)
+	var result parser.Node
+	for _, statement := range statements {
+		result = substitute(statement.Value, values)
+		if statement.Name != "" {
+			values[statement.Name] = result
+		}
+	}
+	return result
+}
+
+func substitute(node parser.Node, values map[string]parser.Node) parser.Node {
+	switch n := node.(type) {
+	case *parser.Variable:
+		if value, ok := values[n.Name]; ok {
+			return value
+		}
+	case *parser.UnaryExpr:
+		return &parser.UnaryExpr{Position: n.Position, Op: n.Op, Operand: substitute(n.Operand, values)}
+	case *parser.BinaryExpr:
+		return &parser.BinaryExpr{Position: n.Position, Op: n.Op, Left: substitute(n.Left, values), Right: substitute(n.Right, values)}
+	case *parser.CallExpr:
+		// Связанная переменная интеграла и ряда закрывает в теле одноимённую переменную сценария
+		it, iteration := parser.IterationOf(n)
+		args := make([]parser.Node, len(n.Args))
+		for i, arg := range n.Args {
+			switch {
+			case iteration && i == it.Index:
+				args[i] = arg
+			case iteration && i == it.Body:
+				shadowed := maps.Clone(values)
+				delete(shadowed, it.Variable)
+				args[i] = substitute(arg, shadowed)
+			default:
+				args[i] = substitute(arg, values)
+			}
+		}
+		return &parser.CallExpr{Position: n.Position, Name: n.Name, Args: args}
+	case *parser.Conditional:
+		return &parser.Conditional{Position: n.Position, Cond: substitute(n.Cond, values), Then: substitute(n.Then, values), Else: substitute(n.Else, values)}
+	case *parser.Conversion:
+		return &parser.Conversion{Position: n.Position, Value: substitute(n.Value, values), Unit: n.Unit}
+	case *parser.VectorLit:
+		elements := make([]parser.Node, len(n.Elements))
+		for i, element := range n.Elements {
+			elements[i] = substitute(element, values)
+		}
+		return &parser.VectorLit{Position: n.Position, Elements: elements}
+	}
+	return node
+}
@@ -0,0 +1,104 @@
+package symbolic
+
+import (
+	stderrors "errors"
+	"strings"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+func TestDerive(t *testing.T) {
+	tests := []struct {
+		input    string
+		variable string
+		want     string
+	}{
+		{"x^3 + 2*x", "x", "3 * x ^ 2 + 2"},
+		{"a*x^2 + b*x + c", "x", "2 * a * x + b"},
+		{"5", "x", "0"},
+		{"y^2", "x", "0"},
+		{"x", "x", "1"},
+		{"x * x", "x", "2 * x"},
+		{"1 / x", "x", "-1 / x ^ 2"},
+		{"sin(x)", "x", "cos(x)"},
+		{"sin(2x)", "x", "2 * cos(2 * x)"},
+		{"exp(x^2)", "x", "2 * exp(x ^ 2) * x"},
+		{"ln(x)", "x", "1 / x"},
+		{"sqrt(x)", "x", "1 / (2 * sqrt(x))"},
+		{"3t^2", "t", "6 * t"},
+		{"a = x^2; a * 3", "x", "6 * x"},
+		{"if(1 > 2, x, x^2)", "x", "if(1 > 2, 1, 2 * x)"},
+		{"integrate(x * t, t, 0, 1)", "x", "integrate(t, t, 0, 1)"},
+		{"integrate(x, x, 0, 1)", "x", "0"},
+		{"integrate(t ^ 2, t, 0, x)", "x", "x ^ 2"},
+		{"sum(k, 1, 3, k * x)", "x", "sum(k, 1, 3, k)"},
+	}
+
+	for _, tt := range tests {
+		statements, err := parser.ParseScript(tt.input, parser.DefaultLocale, tt.variable)
+		if err != nil {
+			t.Errorf("ParseScript(%q): %v", tt.input, err)
+			continue
+		}
+		derivative, err := Derive(Inline(statements), tt.variable)
+		if err != nil {
+			t.Errorf("Derive(%q, %s): %v", tt.input, tt.variable, err)
+			continue
+		}
+		if got := parser.Format(derivative); got != tt.want {
+			t.Errorf("Derive(%q, %s) = %q, want %q", tt.input, tt.variable, got, tt.want)
+		}
+	}
+}
+
+func TestDeriveErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"x < 1", errors.ErrNotDifferentiable},
+		{"x // 2", errors.ErrNotDifferentiable},
+		{"max(x, 1)", errors.ErrNotDifferentiable},
+		{"sum(k, 1, x, k)", errors.ErrNotDifferentiable},
+		{strings.Repeat("x + ", 6000) + "x", errors.ErrExpressionTooLarge},
+	}
+
+	for _, tt := range tests {
+		node, err := parser.Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%.20q): %v", tt.input, err)
+			continue
+		}
+		if _, err := Derive(node, "x"); !stderrors.Is(err, tt.err) {
+			t.Errorf("Derive(%.20q, x) = %v, want %v", tt.input, err, tt.err)
+		}
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"3 - 1", "2"},
+		{"1 / 3", "1 / 3"},
+		{"x + 0", "x"},
+		{"1 * x", "x"},
+		{"2 * x + x", "3 * x"},
+		{"x * x", "x ^ 2"},
+		{"x ^ 1", "x"},
+	}
+
+	for _, tt := range tests {
+		node, err := parser.Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if got := parser.Format(Simplify(node)); got != tt.want {
+			t.Errorf("Simplify(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package symbolic
+
+import (
+	"math/big"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+)
+
+// Наибольший целый показатель, при котором степень числа сворачивается в число
+const maxFoldedExponent = 64
+
+// Simplify упрощает выражение алгебраическими правилами: сворачивает операции над числами,
+// убирает нейтральные элементы (x + 0, 1 * x, x ^ 1), выносит числовые множители вперёд,
+// приводит подобные слагаемые (x + 2 * x = 3 * x) и собирает одинаковые множители в степень: x * x = x ^ 2
+func Simplify(node parser.Node) parser.Node {
+	switch n := node.(type) {
+	case *parser.UnaryExpr:
+		operand := Simplify(n.Operand)
+		switch n.Op {
+		case '+':
+			return operand
+		case '-':
+			return neg(operand)
+		case '%':
+			return div(operand, number(big.NewRat(100, 1)))
+		}
+		return &parser.UnaryExpr{Op: n.Op, Operand: operand}
+	case *parser.BinaryExpr:
+		left, right := Simplify(n.Left), Simplify(n.Right)
+		switch n.Op {
+		case '+':
+			return add(left, right)
+		case '-':
+			return sub(left, right)
+		case '*':
+			return mul(left, right)
+		case '/':
+			return div(left, right)
+		case '^':
+			return pow(left, right)
+		}
+		return &parser.BinaryExpr{Op: n.Op, Left: left, Right: right}
+	case *parser.CallExpr:
+		args := make([]parser.Node, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = Simplify(arg)
+		}
+		return &parser.CallExpr{Name: n.Name, Args: args}
+	case *parser.Conditional:
+		return conditional(Simplify(n.Cond), Simplify(n.Then), Simplify(n.Else))
+	case *parser.VectorLit:
+		elements := make([]parser.Node, len(n.Elements))
+		for i, element := range n.Elements {
+			elements[i] = Simplify(element)
+		}
+		return &parser.VectorLit{Elements: elements}
+	}
+	return node
+}
+
+// constant возвращает значение выражения, если оно составлено только из чисел
+// и четырёх действий арифметики: 3, -2, 1/3, 2 * (1 + 0.5)
+func constant(node parser.Node) (*big.Rat, bool) {
+	switch n := node.(type) {
+	case *parser.NumberLit:
+		value, ok := new(big.Rat).SetString(n.Text)
+		if !ok {
+			value = new(big.Rat).SetFloat64(n.Value)
+		}
+		return value, value != nil
+	case *parser.UnaryExpr:
+		value, ok := constant(n.Operand)
+		if !ok {
+			return nil, false
+		}
+		switch n.Op {
+		case '+':
+			return value, true
+		case '-':
+			return value.Neg(value), true
+		}
+	case *parser.BinaryExpr:
+		left, ok := constant(n.Left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := constant(n.Right)
+		if !ok {
+			return nil, false
+		}
+		return foldBinary(n.Op, left, right)
+	}
+	return nil, false
+}
+
+// foldBinary вычисляет операцию над двумя числами. Деление на ноль,
+// дробные и слишком большие показатели степени не сворачиваются
+func foldBinary(op rune, x, y *big.Rat) (*big.Rat, bool) {
+	switch op {
+	case '+':
+		return new(big.Rat).Add(x, y), true
+	case '-':
+		return new(big.Rat).Sub(x, y), true
+	case '*':
+		return new(big.Rat).Mul(x, y), true
+	case '/':
+		if y.Sign() == 0 {
+			return nil, false
+		}
+		return new(big.Rat).Quo(x, y), true
+	case '^':
+		if !y.IsInt() || !y.Num().IsInt64() {
+			return nil, false
+		}
+		exponent := y.Num().Int64()
+		if exponent > maxFoldedExponent || exponent < -maxFoldedExponent || exponent < 0 && x.Sign() == 0 {
+			return nil, false
+		}
+		result := big.NewRat(1, 1)
+		for range abs(exponent) {
+			result.Mul(result, x)
+		}
+		if exponent < 0 {
+			result.Inv(result)
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// number записывает рациональное число выражением: 3, -2, 0.25 или 1 / 3,
+// если у числа нет конечной десятичной записи
+func number(value *big.Rat) parser.Node {
+	if value.Sign() < 0 {
+		return &parser.UnaryExpr{Op: '-', Operand: number(new(big.Rat).Neg(value))}
+	}
+	if digits, ok := decimalDigits(value.Denom()); ok {
+		text := value.FloatString(digits)
+		f, _ := value.Float64()
+		return &parser.NumberLit{Text: text, Value: f}
+	}
+	return &parser.BinaryExpr{
+		Op:    '/',
+		Left:  number(new(big.Rat).SetInt(value.Num())),
+		Right: number(new(big.Rat).SetInt(value.Denom())),
+	}
+}
+
+// decimalDigits возвращает число знаков после точки в десятичной записи дроби
+// со знаменателем denom. У дроби нет конечной записи, если в знаменателе есть множители кроме 2 и 5
+func decimalDigits(denom *big.Int) (int, bool) {
+	d := new(big.Int).Set(denom)
+	twos, fives := 0, 0
+	for _, factor := range []struct {
+		p     int64
+		count *int
+	}{{2, &twos}, {5, &fives}} {
+		p := big.NewInt(factor.p)
+		for new(big.Int).Mod(d, p).Sign() == 0 {
+			d.Quo(d, p)
+			*factor.count++
+		}
+	}
+	return max(twos, fives), d.IsInt64() && d.Int64() == 1
+}
+
+func isConstant(node parser.Node, value int64) bool {
+	c, ok := constant(node)
+	return ok && c.Cmp(big.NewRat(value, 1)) == 0
+}
+
+// same проверяет, что два выражения записываются одинаково
+func same(a, b parser.Node) bool {
+	return parser.Format(a) == parser.Format(b)
+}
+
+// negated возвращает операнд унарного минуса
+func negated(node parser.Node) (parser.Node, bool) {
+	if n, ok := node.(*parser.UnaryExpr); ok && n.Op == '-' {
+		return n.Operand, true
+	}
+	return nil, false
+}
+
+// coefficient раскладывает слагаемое на числовой множитель и остальную часть: 3 * x - (3, x), -x - (-1, x)
+func coefficient(node parser.Node) (*big.Rat, parser.Node) {
+	if inner, ok := negated(node); ok {
+		c, rest := coefficient(inner)
+		return c.Neg(c), rest
+	}
+	if n, ok := node.(*parser.BinaryExpr); ok && n.Op == '*' {
+		if c, ok := constant(n.Left); ok {
+			return c, n.Right
+		}
+	}
+	return big.NewRat(1, 1), node
+}
+
+func neg(u parser.Node) parser.Node {
+	if c, ok := constant(u); ok {
+		return number(c.Neg(c))
+	}
+	if inner, ok := negated(u); ok {
+		return inner
+	}
+	// Минус вносится в числовой множитель: -(3 * x) = -3 * x, -(1 / x) = -1 / x
+	if n, ok := u.(*parser.BinaryExpr); ok && (n.Op == '*' || n.Op == '/') {
+		if c, ok := constant(n.Left); ok {
+			return &parser.BinaryExpr{Op: n.Op, Left: number(c.Neg(c)), Right: n.Right}
+		}
+	}
+	return &parser.UnaryExpr{Op: '-', Operand: u}
+}
+
+// combine приводит подобные слагаемые: 2 * x + 3 * x = 5 * x, x - x = 0.
+// sign - знак второго слагаемого
+func combine(u, v parser.Node, sign int) (parser.Node, bool) {
+	cu, ru := coefficient(u)
+	cv, rv := coefficient(v)
+	if !same(ru, rv) {
+		return nil, false
+	}
+	if sign < 0 {
+		cv.Neg(cv)
+	}
+	return mul(number(cu.Add(cu, cv)), ru), true
+}
+
+func add(u, v parser.Node) parser.Node {
+	if x, ok := constant(u); ok {
+		if y, ok := constant(v); ok {
+			sum, _ := foldBinary('+', x, y)
+			return number(sum)
+		}
+	}
+	switch {
+	case isConstant(u, 0):
+		return v
+	case isConstant(v, 0):
+		return u
+	}
+	if sum, ok := combine(u, v, 1); ok {
+		return sum
+	}
+	// Слагаемое сокращается с вычитаемым: (a - b) + b = a
+	if difference, ok := u.(*parser.BinaryExpr); ok && difference.Op == '-' && same(difference.Right, v) {
+		return difference.Left
+	}
+	if inner, ok := negated(v); ok {
+		return sub(u, inner)
+	}
+	if inner, ok := negated(u); ok {
+		return sub(v, inner)
+	}
+	return &parser.BinaryExpr{Op: '+', Left: u, Right: v}
+}
+
+func sub(u, v parser.Node) parser.Node {
+	if x, ok := constant(u); ok {
+		if y, ok := constant(v); ok {
+			difference, _ := foldBinary('-', x, y)
+			return number(difference)
+		}
+	}
+	switch {
+	case isConstant(v, 0):
+		return u
+	case isConstant(u, 0):
+		return neg(v)
+	}
+	if difference, ok := combine(u, v, -1); ok {
+		return difference
+	}
+	// Вычитаемое сокращается со слагаемым: (a + b) - b = a, (a + b) - a = b
+	if sum, ok := u.(*parser.BinaryExpr); ok && sum.Op == '+' {
+		if same(sum.Right, v) {
+			return sum.Left
+		}
+		if same(sum.Left, v) {
+			return sum.Right
+		}
+	}
+	if inner, ok := negated(v); ok {
+		return add(u, inner)
+	}
+	return &parser.BinaryExpr{Op: '-', Left: u, Right: v}
+}
+
+func mul(u, v parser.Node) parser.Node {
+	x, uConst := constant(u)
+	y, vConst := constant(v)
+	switch {
+	case uConst && vConst:
+		product, _ := foldBinary('*', x, y)
+		return number(product)
+	case isConstant(u, 0) || isConstant(v, 0):
+		return number(new(big.Rat))
+	case isConstant(u, 1):
+		return v
+	case isConstant(v, 1):
+		return u
+	case isConstant(u, -1):
+		return neg(v)
+	case isConstant(v, -1):
+		return neg(u)
+	case vConst:
+		// Числовой множитель записывается первым: x * 3 = 3 * x
+		return mul(v, u)
+	}
+
+	if inner, ok := negated(u); ok {
+		return neg(mul(inner, v))
+	}
+	if inner, ok := negated(v); ok {
+		return neg(mul(u, inner))
+	}
+
+	// Произведение записывается цепочкой слева направо: a * (b * c) = a * b * c,
+	// а числовые множители собираются в один: 2 * (3 * x) = 6 * x
+	if product, ok := v.(*parser.BinaryExpr); ok && product.Op == '*' {
+		return mul(mul(u, product.Left), product.Right)
+	}
+
+	// Множители с одинаковым основанием складываются в степень: x * x = x ^ 2, x ^ 2 * x = x ^ 3.
+	// Числовой множитель этому не мешает: 3 * x * x = 3 * x ^ 2
+	if base, exponent, ok := samePower(u, v); ok {
+		return pow(base, exponent)
+	}
+	if product, ok := u.(*parser.BinaryExpr); ok && product.Op == '*' {
+		if _, ok := constant(product.Left); ok {
+			if base, exponent, ok := samePower(product.Right, v); ok {
+				return mul(product.Left, pow(base, exponent))
+			}
+		}
+	}
+	return &parser.BinaryExpr{Op: '*', Left: u, Right: v}
+}
+
+// samePower проверяет, что у множителей одно основание, и возвращает его и сумму показателей
+func samePower(u, v parser.Node) (parser.Node, parser.Node, bool) {
+	uBase, uExponent := powerOf(u)
+	vBase, vExponent := powerOf(v)
+	if !same(uBase, vBase) {
+		return nil, nil, false
+	}
+	return uBase, add(uExponent, vExponent), true
+}
+
+// powerOf раскладывает выражение на основание и показатель: x ^ 2 - (x, 2), x - (x, 1)
+func powerOf(node parser.Node) (parser.Node, parser.Node) {
+	if n, ok := node.(*parser.BinaryExpr); ok && n.Op == '^' {
+		return n.Left, n.Right
+	}
+	return node, number(big.NewRat(1, 1))
+}
+
+func div(u, v parser.Node) parser.Node {
+	if x, ok := constant(u); ok {
+		if y, ok := constant(v); ok {
+			if quotient, ok := foldBinary('/', x, y); ok {
+				return number(quotient)
+			}
+		}
+	}
+	switch {
+	case isConstant(v, 1):
+		return u
+	case isConstant(u, 0) && !isConstant(v, 0):
+		return number(new(big.Rat))
+	case same(u, v) && !isConstant(v, 0):
+		return number(big.NewRat(1, 1))
+	}
+	if inner, ok := negated(u); ok {
+		return neg(div(inner, v))
+	}
+	if inner, ok := negated(v); ok {
+		return neg(div(u, inner))
+	}
+	return &parser.BinaryExpr{Op: '/', Left: u, Right: v}
+}
+
+func pow(u, v parser.Node) parser.Node {
+	if x, ok := constant(u); ok {
+		if y, ok := constant(v); ok {
+			if power, ok := foldBinary('^', x, y); ok {
+				return number(power)
+			}
+		}
+	}
+	switch {
+	case isConstant(v, 1):
+		return u
+	case isConstant(v, 0):
+		return number(big.NewRat(1, 1))
+	case isConstant(u, 1):
+		return number(big.NewRat(1, 1))
+	}
+	return &parser.BinaryExpr{Op: '^', Left: u, Right: v}
+}
+
+func call(name string, args ...parser.Node) parser.Node {
+	return &parser.CallExpr{Name: name, Args: args}
+}
+
+func conditional(cond, then, otherwise parser.Node) parser.Node {
+	if same(then, otherwise) {
+		return then
+	}
+	return &parser.Conditional{Cond: cond, Then: then, Else: otherwise}
+}
@@ -0,0 +1,109 @@
+package task
+
+import (
+	"strconv"
+)
+
+// aggregate раскладывает агрегатную функцию в дерево задач и возвращает ссылку на результат
+func (p *planner) aggregate(name string, args []string) string {
+	switch name {
+	case "sum":
+		return p.reduce(args, func(a, b string) string { return p.binary(Add, a, b) })
+	case "avg":
+		sum := p.reduce(args, func(a, b string) string { return p.binary(Add, a, b) })
+		if len(args) == 1 {
+			return sum
+		}
+		return p.binary(Divide, sum, strconv.Itoa(len(args)))
+	case "min", "max":
+		return p.reduce(args, func(a, b string) string { return p.call(name, a, b) })
+	case "median":
+		return p.median(args)
+	}
+	return args[0]
+}
+
+// reduce сворачивает аргументы сбалансированным попарным деревом:
+// операции одного уровня не зависят друг от друга и вычисляются агентами параллельно
+func (p *planner) reduce(args []string, combine func(a, b string) string) string {
+	for len(args) > 1 {
+		var next []string
+		for i := 0; i+1 < len(args); i += 2 {
+			next = append(next, combine(args[i], args[i+1]))
+		}
+		if len(args)%2 == 1 {
+			next = append(next, args[len(args)-1])
+		}
+		args = next
+	}
+	return args[0]
+}
+
+// median находит медиану с помощью сортирующей сети из задач min и max.
+// Из сети оставляются только компараторы, влияющие на средние элементы
+func (p *planner) median(args []string) string {
+	n := len(args)
+	middle := []int{n / 2}
+	if n%2 == 0 {
+		middle = []int{n/2 - 1, n / 2}
+	}
+
+	// Обратным проходом отмечаем компараторы, от которых зависят средние элементы
+	comparators := sortingNetwork(n)
+	needed := make(map[int]bool)
+	for _, i := range middle {
+		needed[i] = true
+	}
+	used := make([][2]bool, len(comparators)) // нужен ли min и max каждого компаратора
+	for k := len(comparators) - 1; k >= 0; k-- {
+		i, j := comparators[k][0], comparators[k][1]
+		used[k] = [2]bool{needed[i], needed[j]}
+		if needed[i] || needed[j] {
+			needed[i], needed[j] = true, true
+		}
+	}
+
+	wires := append([]string(nil), args...)
+	for k, c := range comparators {
+		i, j := c[0], c[1]
+		low, high := wires[i], wires[j]
+		if used[k][0] {
+			low = p.call("min", wires[i], wires[j])
+		}
+		if used[k][1] {
+			high = p.call("max", wires[i], wires[j])
+		}
+		wires[i], wires[j] = low, high
+	}
+
+	if len(middle) == 1 {
+		return wires[middle[0]]
+	}
+	sum := p.binary(Add, wires[middle[0]], wires[middle[1]])
+	return p.binary(Divide, sum, "2")
+}
+
+// sortingNetwork строит сортирующую сеть Бэтчера (odd-even merge sort) для n элементов.
+// Сеть строится для ближайшей степени двойки, а компараторы, затрагивающие
+// несуществующие элементы, отбрасываются - это равносильно дополнению массива
+// бесконечно большими значениями
+func sortingNetwork(n int) [][2]int {
+	size := 1
+	for size < n {
+		size *= 2
+	}
+
+	var comparators [][2]int
+	for p := 1; p < size; p *= 2 {
+		for k := p; k >= 1; k /= 2 {
+			for j := k % p; j+k < size; j += 2 * k {
+				for i := 0; i < k && i+j+k < size; i++ {
+					if (i+j)/(2*p) == (i+j+k)/(2*p) && i+j+k < n {
+						comparators = append(comparators, [2]int{i + j, i + j + k})
+					}
+				}
+			}
+		}
+	}
+	return comparators
+}
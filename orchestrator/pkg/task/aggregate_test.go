@@ -0,0 +1,90 @@
+package task
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+// execute вычисляет задачи плана по порядку так, как это сделали бы агенты,
+// и возвращает значение операнда result
+func execute(t *testing.T, tasks []shared.Task, result string) float64 {
+	t.Helper()
+	values := make(map[string]float64)
+	value := func(operand string) float64 {
+		if v, ok := values[operand]; ok {
+			return v
+		}
+		v, err := strconv.ParseFloat(operand, 64)
+		if err != nil {
+			t.Fatalf("операнд %q не вычислен", operand)
+		}
+		return v
+	}
+
+	for _, task := range tasks {
+		x, y := value(task.FirstArgument), 0.0
+		if task.SecondArgument != "" {
+			y = value(task.SecondArgument)
+		}
+		var v float64
+		switch {
+		case task.Operator == rune(Function):
+			v, _ = shared.BinaryFunctions[task.Function](x, y)
+		case task.Operator == rune(Add):
+			v = x + y
+		case task.Operator == rune(Divide):
+			v = x / y
+		default:
+			t.Fatalf("неожиданная операция %c", task.Operator)
+		}
+		values["id"+strconv.FormatInt(task.ID, 10)] = v
+	}
+	return value(result)
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want float64
+	}{
+		{"sum", []string{"5", "1", "4", "2", "3"}, 15},
+		{"avg", []string{"5", "1", "4", "2", "3"}, 3},
+		{"avg", []string{"7"}, 7},
+		{"min", []string{"5", "1", "4", "2", "3"}, 1},
+		{"max", []string{"5", "1", "4", "2", "3"}, 5},
+		{"median", []string{"5", "1", "4", "2", "3"}, 3},
+		{"median", []string{"4", "1", "3", "2"}, 2.5},
+		{"median", []string{"9", "8", "7", "6", "5", "4", "3", "2", "1"}, 5},
+	}
+
+	for _, tt := range tests {
+		p := &planner{nextID: 1}
+		result := p.aggregate(tt.name, tt.args)
+		if got := execute(t, p.tasks, result); got != tt.want {
+			t.Errorf("%s(%v) = %v, want %v", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+// Сумма раскладывается в сбалансированное дерево: n - 1 задача и глубина log2(n)
+func TestAggregateBalanced(t *testing.T) {
+	p := &planner{nextID: 1}
+	p.aggregate("sum", []string{"1", "2", "3", "4", "5", "6", "7", "8"})
+	if len(p.tasks) != 7 {
+		t.Fatalf("sum из 8 аргументов: %d задач, want 7", len(p.tasks))
+	}
+
+	depth := make(map[string]int)
+	var deepest int
+	for _, task := range p.tasks {
+		d := max(depth[task.FirstArgument], depth[task.SecondArgument]) + 1
+		depth["id"+strconv.FormatInt(task.ID, 10)] = d
+		deepest = max(deepest, d)
+	}
+	if deepest != 3 {
+		t.Errorf("глубина дерева суммы = %d, want 3", deepest)
+	}
+}
@@ -0,0 +1,31 @@
+package task
+
+import "github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+
+// findImaginary возвращает первый мнимый литерал в дереве или nil, если их нет
+func findImaginary(node parser.Node) *parser.ImaginaryLit {
+	switch n := node.(type) {
+	case *parser.ImaginaryLit:
+		return n
+	case *parser.UnaryExpr:
+		return findImaginary(n.Operand)
+	case *parser.BinaryExpr:
+		if lit := findImaginary(n.Left); lit != nil {
+			return lit
+		}
+		return findImaginary(n.Right)
+	case *parser.CallExpr:
+		for _, arg := range n.Args {
+			if lit := findImaginary(arg); lit != nil {
+				return lit
+			}
+		}
+	case *parser.Conditional:
+		for _, part := range []parser.Node{n.Cond, n.Then, n.Else} {
+			if lit := findImaginary(part); lit != nil {
+				return lit
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package task
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Маркеры ленивых веток в обратной польской записи: условие ? then : else ;
+const (
+	branchThen = "?"
+	branchElse = ":"
+	branchEnd  = ";"
+)
+
+// branchRPN записывает условие с ветками в обратной польской записи
+func branchRPN(cond, then, otherwise []string) []string {
+	output := append(cond, branchThen)
+	output = append(output, then...)
+	output = append(output, branchElse)
+	output = append(output, otherwise...)
+	return append(output, branchEnd)
+}
+
+// isControl проверяет, выполняет ли задачу сам оркестратор
+func isControl(op Operation) bool {
+	return op == Condition || op == Forward || op == Collect || op == Pack
+}
+
+// resolveConditions выбирает ветки условных задач, условие которых уже вычислено,
+// завершает задачи Forward, чья выбранная ветка вычислена, и задачи Collect и Pack с известными аргументами.
+// Возвращает true, если хотя бы одна задача изменилась
+func (q *Queue) resolveConditions() (bool, error) {
+	rows, err := q.db.Query(
+		"SELECT "+taskColumns+" FROM tasks WHERE status = 0 AND guard = 0 AND operator IN (?, ?, ?, ?)",
+		string(Condition), string(Forward), string(Collect), string(Pack),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	// Изменяем задачи после чтения: выбор ветки затрагивает другие строки таблицы
+	var ready []shared.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			log.Printf("Ошибка при сканировании задачи: %v", err)
+			continue
+		}
+		// Ветки условия ещё не вычислены, а Collect и Pack ждут оба аргумента
+		op := Operation(task.Operator)
+		if !IsReference(task.FirstArgument) && (op != Collect && op != Pack || !IsReference(task.SecondArgument)) {
+			ready = append(ready, task)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("ошибка при итерации по строкам: %v", err)
+	}
+
+	for _, task := range ready {
+		switch Operation(task.Operator) {
+		case Forward:
+			q.forward(task)
+		case Collect:
+			q.collect(task)
+		case Pack:
+			q.pack(task)
+		default:
+			q.chooseBranch(task)
+		}
+	}
+	return len(ready) > 0, nil
+}
+
+// chooseBranch ставит в очередь задачи ветки, выбранной условием, пропускает задачи
+// другой ветки и превращает условную задачу в Forward на результат выбранной ветки
+func (q *Queue) chooseBranch(task shared.Task) {
+	truth, err := isTrue(task.FirstArgument)
+	if err != nil {
+		q.Fail(task.ID, err.Error())
+		return
+	}
+
+	taken, skipped, result := shared.BranchThen, shared.BranchElse, task.SecondArgument
+	if !truth {
+		taken, skipped, result = shared.BranchElse, shared.BranchThen, task.ThirdArgument
+	}
+	log.Printf("Условие задачи %d (%s) выбрало ветку %s\n", task.ID, task.FirstArgument, taken)
+
+	_, err = q.db.Exec(
+		"UPDATE tasks SET guard = 0, branch = '' WHERE guard = ? AND branch = ?",
+		task.ID, taken,
+	)
+	if err != nil {
+		log.Printf("Ошибка при выборе ветки задачи %d: %v", task.ID, err)
+		return
+	}
+	q.skipBranch(task.ID, skipped)
+
+	_, err = q.db.Exec(
+		"UPDATE tasks SET operator = ?, first_argument = ?, second_argument = '', third_argument = '' WHERE id = ?",
+		string(Forward), result, task.ID,
+	)
+	if err != nil {
+		log.Printf("Ошибка при обновлении задачи %d: %v", task.ID, err)
+	}
+}
+
+// skipBranch помечает задачи невыбранной ветки условия id, включая вложенные условия
+func (q *Queue) skipBranch(id int64, branch string) {
+	rows, err := q.db.Query(
+		"SELECT id, operator FROM tasks WHERE guard = ? AND branch = ? AND status = 0",
+		id, branch,
+	)
+	if err != nil {
+		log.Printf("Ошибка при пропуске ветки задачи %d: %v", id, err)
+		return
+	}
+
+	var nested []int64
+	for rows.Next() {
+		var taskID int64
+		var operator string
+		if err := rows.Scan(&taskID, &operator); err != nil {
+			log.Printf("Ошибка при сканировании задачи: %v", err)
+			continue
+		}
+		if operator == string(Condition) {
+			nested = append(nested, taskID)
+		}
+	}
+	rows.Close()
+
+	_, err = q.db.Exec(
+		"UPDATE tasks SET status = ?, error = ? WHERE guard = ? AND branch = ? AND status = 0",
+		true, errors.ErrBranchNotTaken.Error(), id, branch,
+	)
+	if err != nil {
+		log.Printf("Ошибка при пропуске ветки задачи %d: %v", id, err)
+		return
+	}
+
+	for _, taskID := range nested {
+		q.skipBranch(taskID, shared.BranchThen)
+		q.skipBranch(taskID, shared.BranchElse)
+	}
+}
+
+// forward завершает условную задачу результатом выбранной ветки
+func (q *Queue) forward(task shared.Task) {
+	value, err := approximate(task.FirstArgument)
+	if err != nil {
+		q.Fail(task.ID, err.Error())
+		return
+	}
+
+	_, err = q.db.Exec(
+		"UPDATE tasks SET status = ?, result = ?, imag = ?, value = ? WHERE id = ?",
+		true, real(value), imag(value), task.FirstArgument, task.ID,
+	)
+	if err != nil {
+		log.Printf("Ошибка при обновлении задачи %d: %v", task.ID, err)
+	}
+}
+
+// isTrue проверяет условие: истинно любое ненулевое значение. Интервал истинен,
+// если не содержит нуля, и ложен, если состоит из одного нуля. Пачка выборок
+// истинна, если истинны все её значения, и ложна, если все ложны. Иначе условие не определено
+func isTrue(value string) (bool, error) {
+	if shared.IsSamples(value) {
+		samples, err := shared.ParseSamples(value)
+		if err != nil {
+			return false, err
+		}
+		truths := 0
+		for _, x := range samples {
+			if x != 0 {
+				truths++
+			}
+		}
+		if truths != 0 && truths != len(samples) {
+			return false, fmt.Errorf("%w: истинно %d значений выборки из %d", errors.ErrUncertainCondition, truths, len(samples))
+		}
+		return truths != 0, nil
+	}
+	if shared.IsInterval(value) {
+		iv, err := shared.ParseInterval(value)
+		if err != nil {
+			return false, err
+		}
+		if iv.Contains(0) && !iv.IsPoint() {
+			return false, fmt.Errorf("%w: %s", errors.ErrUncertainCondition, value)
+		}
+		return !iv.Contains(0), nil
+	}
+	z, err := approximate(value)
+	if err != nil {
+		return false, err
+	}
+	return z != 0, nil
+}
+
+// approximate переводит результат задачи в любом режиме ("0.5", "1/3", "2+4i", "[1, 2]", "{4.9, 5.1}")
+// в complex128. Интервал заменяется своей серединой, пачка выборок - средним
+func approximate(value string) (complex128, error) {
+	if shared.IsSamples(value) {
+		samples, err := shared.ParseSamples(value)
+		if err != nil {
+			return 0, err
+		}
+		return complex(samples.Mean(), 0), nil
+	}
+	if shared.IsInterval(value) {
+		iv, err := shared.ParseInterval(value)
+		if err != nil {
+			return 0, err
+		}
+		return complex(iv.Mid(), 0), nil
+	}
+	if r, ok := new(big.Rat).SetString(value); ok {
+		f, _ := r.Float64()
+		return complex(f, 0), nil
+	}
+	z, err := strconv.ParseComplex(value, 128)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", errors.ErrInvalidNumber, value)
+	}
+	return z, nil
+}
@@ -0,0 +1,115 @@
+package task
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// runAgent выдаёт готовые задачи так же, как GetAvailableTask, и вычисляет их,
+// пока они не кончатся. Возвращает номера выданных задач
+func runAgent(t *testing.T, q *Queue) map[int64]bool {
+	t.Helper()
+
+	handed := make(map[int64]bool)
+	for {
+		var ready []shared.Task
+		for _, task := range q.GetTasks() {
+			if !task.Status && Complete(task) {
+				ready = append(ready, task)
+			}
+		}
+		if len(ready) == 0 {
+			return handed
+		}
+
+		for _, task := range ready {
+			handed[task.ID] = true
+			x, y := parseFloat(t, task.FirstArgument), parseFloat(t, task.SecondArgument)
+			var result float64
+			switch Operation(task.Operator) {
+			case Add:
+				result = x + y
+			case Multiply:
+				result = x * y
+			case Divide:
+				result = x / y
+			case Greater:
+				if x > y {
+					result = 1
+				}
+			default:
+				t.Fatalf("агенту выдана задача %d: %s %c %s", task.ID, task.FirstArgument, task.Operator, task.SecondArgument)
+			}
+			q.Done(shared.TaskResult{ID: task.ID, Result: result})
+		}
+	}
+}
+
+func parseFloat(t *testing.T, arg string) float64 {
+	t.Helper()
+	z, err := approximate(arg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return real(z)
+}
+
+// Задачи невыбранной ветки, включая вложенные условия, пропускаются и не
+// выдаются агентам, а их ошибки не проваливают выражение
+func TestQueueConditions(t *testing.T) {
+	tests := []string{
+		"if(0, 1/0, 5)",
+		"if(1 > 2, 1/0, if(0, sqrt(-1), 2 + 3))",
+	}
+
+	for _, input := range tests {
+		q := newQueue(t)
+		id, err := q.ParseExpression(userContext(), input, Scope{}, floatMode)
+		if err != nil {
+			t.Errorf("ParseExpression(%q): %v", input, err)
+			continue
+		}
+
+		handed := runAgent(t, q)
+		if err := q.UpdateTasks(); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.UpdateExpressions(); err != nil {
+			t.Fatal(err)
+		}
+
+		expr := q.FindExpression(id)
+		if expr == nil || !expr.Status || expr.Error != "" || expr.Result != "5" {
+			t.Errorf("%s: выражение = %+v, want 5", input, expr)
+		}
+
+		skipped := 0
+		for _, task := range q.GetTasks() {
+			if !task.Status {
+				t.Errorf("%s: задача %d не завершена: %+v", input, task.ID, task)
+			}
+			if task.Error == errors.ErrBranchNotTaken.Error() {
+				skipped++
+				if handed[task.ID] {
+					t.Errorf("%s: задача %d пропущенной ветки выдана агенту", input, task.ID)
+				}
+			}
+		}
+		if skipped == 0 {
+			t.Errorf("%s: ни одна задача не пропущена", input)
+		}
+	}
+}
+
+// Деление на ноль-литерал вне ветки условия отклоняется ещё при разборе
+func TestQueueDivisionByZero(t *testing.T) {
+	for _, input := range []string{"1 / 0", "5 % 0", "5 // 0x0", "1 / -0", "if(1, 2, 3) / 0"} {
+		q := newQueue(t)
+		if _, err := q.ParseExpression(userContext(), input, Scope{}, floatMode); !stderrors.Is(err, errors.ErrDivisionByZero) {
+			t.Errorf("ParseExpression(%q) = %v, want %v", input, err, errors.ErrDivisionByZero)
+		}
+	}
+}
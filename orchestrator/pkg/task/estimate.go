@@ -0,0 +1,85 @@
+package task
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+// Dependencies возвращает для каждой задачи ID задач, которые должны быть выполнены
+// до неё: задачи из её аргументов и тела участка, а для задач ветки - задачу, вычисляющую условие.
+// Сама условная задача ждёт свои ветки, поэтому задачи веток от неё не зависят
+func Dependencies(tasks []shared.Task) map[int64][]int64 {
+	byID := make(map[int64]shared.Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+
+	deps := make(map[int64][]int64, len(tasks))
+	for _, task := range tasks {
+		ids := references(append([]string{task.FirstArgument, task.SecondArgument, task.ThirdArgument}, task.Program...)...)
+		if task.Guard != 0 {
+			ids = append(ids, references(byID[task.Guard].FirstArgument)...)
+		}
+		deps[task.ID] = ids
+	}
+	return deps
+}
+
+// references возвращает ID задач, на которые ссылаются операнды
+func references(operands ...string) []int64 {
+	var ids []int64
+	for _, operand := range operands {
+		if !IsReference(operand) {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(operand, "id"), 10, 64)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Estimate оценивает время вычисления задач в миллисекундах по времени операций,
+// заданному агентам переменными среды, если свободных агентов хватает на все
+// независимые задачи. Возвращает момент завершения каждой задачи и всего плана -
+// длину критического пути. Условие учитывается по более долгой ветке
+func Estimate(tasks []shared.Task) (map[int64]float64, float64) {
+	byID := make(map[int64]shared.Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+	deps := Dependencies(tasks)
+
+	finish := make(map[int64]float64, len(tasks))
+	var estimate func(id int64) float64
+	estimate = func(id int64) float64 {
+		if t, ok := finish[id]; ok {
+			return t
+		}
+		task, ok := byID[id]
+		if !ok {
+			return 0
+		}
+
+		var start float64
+		for _, dep := range deps[id] {
+			start = max(start, estimate(dep))
+		}
+
+		var duration float64
+		if !isControl(Operation(task.Operator)) {
+			duration = shared.OperationDelay(task.Operator, task.Function)
+		}
+		finish[id] = start + duration
+		return finish[id]
+	}
+
+	var total float64
+	for _, task := range tasks {
+		total = max(total, estimate(task.ID))
+	}
+	return finish, total
+}
@@ -0,0 +1,43 @@
+package task
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+// Задачи 1-4 - (2 * 3 + 1) + 4 * 5, задача 5 - условие на результат задачи 4
+// с веткой then из задачи 6 и числом 5 в ветке else
+var estimateTasks = []shared.Task{
+	{ID: 1, FirstArgument: "2", SecondArgument: "3", Operator: '*'},
+	{ID: 2, FirstArgument: "id1", SecondArgument: "1", Operator: '+'},
+	{ID: 3, FirstArgument: "4", SecondArgument: "5", Operator: '*'},
+	{ID: 4, FirstArgument: "id2", SecondArgument: "id3", Operator: '+'},
+	{ID: 5, FirstArgument: "id4", SecondArgument: "id6", ThirdArgument: "5", Operator: rune(Condition)},
+	{ID: 6, FirstArgument: "1", SecondArgument: "1", Operator: '+', Guard: 5, Branch: shared.BranchThen},
+}
+
+func TestDependencies(t *testing.T) {
+	deps := Dependencies(estimateTasks)
+	// Задача ветки ждёт не всю условную задачу, а только её условие: условная
+	// задача сама ждёт свои ветки
+	want := map[int64][]int64{1: nil, 2: {1}, 3: nil, 4: {2, 3}, 5: {4, 6}, 6: {4}}
+	if !maps.EqualFunc(deps, want, slices.Equal) {
+		t.Errorf("Dependencies = %v, want %v", deps, want)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	t.Setenv("TIME_ADDITION_MS", "100")
+	t.Setenv("TIME_MULTIPLICATIONS_MS", "200")
+
+	finish, total := Estimate(estimateTasks)
+	// Умножения 1 и 3 идут параллельно, ветка then начинается после условия (задачи 4),
+	// а сама условная задача выполняется оркестратором мгновенно
+	want := map[int64]float64{1: 200, 2: 300, 3: 200, 4: 400, 5: 500, 6: 500}
+	if !maps.Equal(finish, want) || total != 500 {
+		t.Errorf("Estimate = %v, %v, want %v, 500", finish, total, want)
+	}
+}
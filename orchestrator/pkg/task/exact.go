@@ -0,0 +1,162 @@
+package task
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// formatLiteral записывает литерал так, как его получит агент: в точном режиме -
+// дробью по исходному тексту литерала ("0.1" - "1/10"), в десятичном - десятичной
+// записью, округлённой до точности выражения, в интервальном - наименьшим интервалом
+// из float64, который содержит литерал, иначе - числом float64
+func formatLiteral(lit *parser.NumberLit, negate bool, options Options) string {
+	if options.Mode == shared.ModeFloat {
+		if negate {
+			return formatNumber(-lit.Value)
+		}
+		return formatNumber(lit.Value)
+	}
+
+	value := literalValue(lit, negate)
+	switch options.Mode {
+	case shared.ModeDecimal:
+		return shared.RoundDecimal(value, options.Precision, options.Rounding)
+	case shared.ModeInterval:
+		return enclose(value, value)
+	}
+	return value.RatString()
+}
+
+// literalValue возвращает точное значение литерала по его исходному тексту
+func literalValue(lit *parser.NumberLit, negate bool) *big.Rat {
+	value, ok := new(big.Rat).SetString(lit.Text)
+	if !ok {
+		value = new(big.Rat).SetFloat64(lit.Value)
+	}
+	if negate {
+		value.Neg(value)
+	}
+	return value
+}
+
+// boundValue возвращает точное значение границы интервала: литерала, возможно со знаком
+func boundValue(bound parser.Node) *big.Rat {
+	if n, ok := bound.(*parser.UnaryExpr); ok {
+		return literalValue(n.Operand.(*parser.NumberLit), n.Op == '-')
+	}
+	return literalValue(bound.(*parser.NumberLit), false)
+}
+
+// enclose записывает отрезок [lo, hi] интервалом из float64, округляя границы наружу.
+// Число, точно представимое в float64, записывается без скобок: 0.5 остаётся точкой,
+// а 0.1 становится интервалом шириной в одну единицу последнего разряда
+func enclose(lo, hi *big.Rat) string {
+	iv := shared.Interval{Lo: roundFloat(lo, -1), Hi: roundFloat(hi, 1)}
+	if iv.IsPoint() {
+		return formatNumber(iv.Lo)
+	}
+	return iv.String()
+}
+
+// roundFloat округляет число до ближайшего float64 в сторону direction: -1 - вниз, 1 - вверх
+func roundFloat(x *big.Rat, direction float64) float64 {
+	f, exact := x.Float64()
+	if exact || math.IsInf(f, 0) {
+		return f
+	}
+	if cmp := new(big.Rat).SetFloat64(f).Cmp(x); cmp != 0 && float64(cmp) != direction {
+		f = math.Nextafter(f, math.Inf(int(direction)))
+	}
+	return f
+}
+
+// resultValue возвращает результат выполненной задачи без потери точности.
+// У задач, выполненных до появления столбца value, есть только result
+func resultValue(task shared.Task) string {
+	if task.Value != "" {
+		return task.Value
+	}
+	return strconv.FormatFloat(task.Result, 'f', -1, 64)
+}
+
+// displayValue записывает значение в единице unit так же, как setResult записывает результат выражения expr
+func displayValue(expr shared.Expression, value, unit string) (string, error) {
+	expr.Unit = unit
+	if err := setResult(&expr, value); err != nil {
+		return "", err
+	}
+	return expr.Result, nil
+}
+
+// setResult записывает в выражение его результат. В точном режиме value -
+// дробь, которая сохраняется как есть и округляется до Precision знаков после запятой.
+// В десятичном режиме value уже округлён агентом. В интервальном число без скобок
+// записывается интервалом из одной точки, чтобы результат всегда был интервалом.
+// Пачка выборок выражения с погрешностями сводится к среднему и стандартному отклонению.
+// Вектор записывается поэлементно: [0.3333, 0.5000], в точном режиме дробь - тоже вектор.
+// Результат выражения с единицами записывается вместе с единицей
+func setResult(expr *shared.Expression, value string) error {
+	if err := writeResult(expr, value); err != nil {
+		return err
+	}
+	if expr.Unit != "" {
+		expr.Result += " " + expr.Unit
+	}
+	return nil
+}
+
+func writeResult(expr *shared.Expression, value string) error {
+	// В режиме interval в квадратных скобках записан интервал, векторов там нет
+	if elements, ok := splitVector(value); ok && expr.Mode != shared.ModeInterval {
+		results, fractions := make([]string, len(elements)), make([]string, len(elements))
+		for i, element := range elements {
+			item := *expr
+			if err := writeResult(&item, element); err != nil {
+				return err
+			}
+			results[i], fractions[i] = item.Result, item.Fraction
+		}
+		expr.Result = "[" + strings.Join(results, ", ") + "]"
+		if expr.Mode == shared.ModeExact {
+			expr.Fraction = "[" + strings.Join(fractions, ", ") + "]"
+		}
+		return nil
+	}
+	if shared.IsSamples(value) {
+		samples, err := shared.ParseSamples(value)
+		if err != nil {
+			return err
+		}
+		distribution := shared.Summarize(samples)
+		expr.Distribution = &distribution
+		expr.Result = distribution.String()
+		return nil
+	}
+	if expr.Mode == shared.ModeInterval {
+		iv, err := shared.ParseInterval(value)
+		if err != nil {
+			return err
+		}
+		expr.Result = iv.String()
+		return nil
+	}
+	if expr.Mode != shared.ModeExact {
+		expr.Result = value
+		return nil
+	}
+
+	fraction, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return fmt.Errorf("%w: %q", errors.ErrInvalidNumber, value)
+	}
+	expr.Fraction = fraction.RatString()
+	expr.Result = fraction.FloatString(expr.Precision)
+	return nil
+}
@@ -0,0 +1,183 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Состояния задачи в графе выражения
+const (
+	StatePending = "pending" // ждёт результатов других задач или выбора ветки
+	StateReady   = "ready"   // готова, но ещё не выдана агенту
+	StateRunning = "running" // выдана агенту, результата пока нет
+	StateDone    = "done"
+	StateFailed  = "failed"
+	StateSkipped = "skipped" // ветка условия не выбрана
+)
+
+// Цвета состояний в DOT и Mermaid
+var stateColors = map[string]string{
+	StatePending: "#d9d9d9",
+	StateReady:   "#fff2a8",
+	StateRunning: "#a8d8ff",
+	StateDone:    "#b7f0b1",
+	StateFailed:  "#ffb3b3",
+	StateSkipped: "#ffffff",
+}
+
+// State возвращает состояние задачи
+func State(task shared.Task) string {
+	switch {
+	case task.Status && task.Error == errors.ErrBranchNotTaken.Error():
+		return StateSkipped
+	case task.Status && task.Error != "":
+		return StateFailed
+	case task.Status:
+		return StateDone
+	case task.StartedAt != 0:
+		return StateRunning
+	case Complete(task):
+		return StateReady
+	}
+	return StatePending
+}
+
+// Graph - граф зависимостей задач выражения
+type Graph struct {
+	Expression int64       `json:"expression"`
+	Result     string      `json:"result"` // результат выражения или ссылка на итоговую задачу
+	Nodes      []GraphNode `json:"nodes"`
+	Edges      []GraphEdge `json:"edges"`
+}
+
+// GraphNode - задача в графе
+type GraphNode struct {
+	ID     int64  `json:"id"`
+	Label  string `json:"label"`
+	State  string `json:"state"`
+	Value  string `json:"value,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Guard  int64  `json:"guard,omitempty"`
+	Branch string `json:"branch,omitempty"`
+}
+
+// GraphEdge - зависимость: задача To ждёт задачу From
+type GraphEdge struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// BuildGraph строит граф выражения по его задачам
+func BuildGraph(expr shared.Expression, tasks []shared.Task) Graph {
+	graph := Graph{Expression: expr.ID, Result: expr.Result, Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	for _, task := range tasks {
+		node := GraphNode{
+			ID:     task.ID,
+			Label:  taskLabel(task),
+			State:  State(task),
+			Error:  task.Error,
+			Guard:  task.Guard,
+			Branch: task.Branch,
+		}
+		if node.State == StateDone {
+			node.Value = resultValue(task)
+		}
+		graph.Nodes = append(graph.Nodes, node)
+
+		for _, dep := range task.DependsOn {
+			graph.Edges = append(graph.Edges, GraphEdge{From: dep, To: task.ID})
+		}
+	}
+	return graph
+}
+
+// taskLabel записывает операцию задачи с текущими аргументами: выполненные
+// зависимости уже заменены своими результатами
+func taskLabel(task shared.Task) string {
+	first, second := shortValue(task.FirstArgument), shortValue(task.SecondArgument)
+	switch Operation(task.Operator) {
+	case Function:
+		if task.SecondArgument == "" {
+			return fmt.Sprintf("%s(%s)", task.Function, first)
+		}
+		return fmt.Sprintf("%s(%s, %s)", task.Function, first, second)
+	case Condition:
+		return fmt.Sprintf("%s ? %s : %s", first, second, task.ThirdArgument)
+	case Forward:
+		return "→ " + first
+	case Integrate, Series:
+		return fmt.Sprintf("%c[%s, %s] %s: %s (%d/%d)", task.Operator, first, second, task.Variable, strings.Join(task.Program, " "), task.Chunk+1, task.Chunks)
+	case Pack:
+		if task.SecondArgument == "" {
+			return "⊞ " + first
+		}
+	}
+	return fmt.Sprintf("%s %s %s", first, parser.OperatorText(task.Operator), second)
+}
+
+// shortValue сокращает пачку выборок до числа значений в ней: "{100 значений}"
+func shortValue(value string) string {
+	if !shared.IsSamples(value) {
+		return value
+	}
+	return fmt.Sprintf("{%d значений}", strings.Count(value, ",")+1)
+}
+
+// nodeText - подпись узла: ID, операция и результат или ошибка
+func nodeText(node GraphNode) []string {
+	lines := []string{fmt.Sprintf("id%d: %s", node.ID, node.Label)}
+	switch {
+	case node.Value != "":
+		lines = append(lines, "= "+shortValue(node.Value))
+	case node.State == StateFailed:
+		lines = append(lines, node.Error)
+	default:
+		lines = append(lines, node.State)
+	}
+	return lines
+}
+
+// DOT записывает граф на языке Graphviz
+func (g Graph) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph expression_%d {\n", g.Expression)
+	b.WriteString("\trankdir=LR;\n\tnode [shape=box, style=\"rounded,filled\"];\n")
+	for _, node := range g.Nodes {
+		label := strings.Join(nodeText(node), "\n")
+		label = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(label)
+		style := ""
+		if node.State == StateSkipped {
+			style = `, style="rounded,dashed"`
+		}
+		fmt.Fprintf(&b, "\tt%d [label=\"%s\", fillcolor=\"%s\"%s];\n", node.ID, label, stateColors[node.State], style)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "\tt%d -> t%d;\n", edge.From, edge.To)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid записывает граф блок-схемой Mermaid
+func (g Graph) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, node := range g.Nodes {
+		lines := nodeText(node)
+		for i, line := range lines {
+			lines[i] = strings.ReplaceAll(line, `"`, "#quot;")
+		}
+		fmt.Fprintf(&b, "    t%d[\"%s\"]:::%s\n", node.ID, strings.Join(lines, "<br/>"), node.State)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "    t%d --> t%d\n", edge.From, edge.To)
+	}
+	for _, state := range []string{StatePending, StateReady, StateRunning, StateDone, StateFailed, StateSkipped} {
+		fmt.Fprintf(&b, "    classDef %s fill:%s,stroke:#555\n", state, stateColors[state])
+	}
+	return b.String()
+}
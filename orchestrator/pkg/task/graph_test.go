@@ -0,0 +1,87 @@
+package task
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Граф if(2 * 3 > 5, 1 / 0, 7) после выбора ветки then и провала деления
+// и ещё не выданная агенту задача sqrt(4)
+var graphExpression = shared.Expression{ID: 3, Result: "id4"}
+
+var graphTasks = []shared.Task{
+	{ID: 1, FirstArgument: "2", SecondArgument: "3", Operator: '*', Status: true, Result: 6, Value: "6"},
+	{ID: 2, FirstArgument: "6", SecondArgument: "5", Operator: '>', DependsOn: []int64{1}, StartedAt: 1},
+	{ID: 3, FirstArgument: "1", SecondArgument: "0", Operator: '/', Status: true, Error: "на ноль делить нельзя", DependsOn: []int64{2}},
+	{ID: 4, FirstArgument: "id2", SecondArgument: "id3", ThirdArgument: "7", Operator: rune(Condition), DependsOn: []int64{2, 3}},
+	{ID: 5, FirstArgument: "7", Operator: '+', SecondArgument: "1", Status: true, Error: errors.ErrBranchNotTaken.Error(), Guard: 4, Branch: shared.BranchElse},
+	{ID: 6, FirstArgument: "4", Operator: rune(Function), Function: "sqrt"},
+}
+
+func TestBuildGraph(t *testing.T) {
+	graph := BuildGraph(graphExpression, graphTasks)
+
+	want := `{"expression":3,"result":"id4","nodes":[` +
+		`{"id":1,"label":"2 * 3","state":"done","value":"6"},` +
+		`{"id":2,"label":"6 \u003e 5","state":"running"},` +
+		`{"id":3,"label":"1 / 0","state":"failed","error":"на ноль делить нельзя"},` +
+		`{"id":4,"label":"id2 ? id3 : 7","state":"pending"},` +
+		`{"id":5,"label":"7 + 1","state":"skipped","error":"ветка условия не выбрана","guard":4,"branch":"else"},` +
+		`{"id":6,"label":"sqrt(4)","state":"ready"}],` +
+		`"edges":[{"from":1,"to":2},{"from":2,"to":3},{"from":2,"to":4},{"from":3,"to":4}]}`
+	data, err := json.Marshal(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("граф = %s\nwant %s", data, want)
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	want := `digraph expression_3 {
+	rankdir=LR;
+	node [shape=box, style="rounded,filled"];
+	t1 [label="id1: 2 * 3\n= 6", fillcolor="#b7f0b1"];
+	t2 [label="id2: 6 > 5\nrunning", fillcolor="#a8d8ff"];
+	t3 [label="id3: 1 / 0\nна ноль делить нельзя", fillcolor="#ffb3b3"];
+	t4 [label="id4: id2 ? id3 : 7\npending", fillcolor="#d9d9d9"];
+	t5 [label="id5: 7 + 1\nskipped", fillcolor="#ffffff", style="rounded,dashed"];
+	t6 [label="id6: sqrt(4)\nready", fillcolor="#fff2a8"];
+	t1 -> t2;
+	t2 -> t3;
+	t2 -> t4;
+	t3 -> t4;
+}
+`
+	if got := BuildGraph(graphExpression, graphTasks).DOT(); got != want {
+		t.Errorf("DOT = %s\nwant %s", got, want)
+	}
+}
+
+func TestGraphMermaid(t *testing.T) {
+	want := `flowchart LR
+    t1["id1: 2 * 3<br/>= 6"]:::done
+    t2["id2: 6 > 5<br/>running"]:::running
+    t3["id3: 1 / 0<br/>на ноль делить нельзя"]:::failed
+    t4["id4: id2 ? id3 : 7<br/>pending"]:::pending
+    t5["id5: 7 + 1<br/>skipped"]:::skipped
+    t6["id6: sqrt(4)<br/>ready"]:::ready
+    t1 --> t2
+    t2 --> t3
+    t2 --> t4
+    t3 --> t4
+    classDef pending fill:#d9d9d9,stroke:#555
+    classDef ready fill:#fff2a8,stroke:#555
+    classDef running fill:#a8d8ff,stroke:#555
+    classDef done fill:#b7f0b1,stroke:#555
+    classDef failed fill:#ffb3b3,stroke:#555
+    classDef skipped fill:#ffffff,stroke:#555
+`
+	if got := BuildGraph(graphExpression, graphTasks).Mermaid(); got != want {
+		t.Errorf("Mermaid = %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+package task
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Операции участков по имени функции со связанной переменной
+var iterationOperations = map[string]Operation{
+	"integrate": Integrate,
+	"sum":       Series,
+}
+
+// findIteration возвращает первый интеграл или ряд в дереве или nil, если их нет.
+// Тело агент вычисляет над вещественными числами сам, поэтому в нём не может
+// быть другого интеграла или ряда, а в вызове - мнимых чисел
+func findIteration(node parser.Node) (*parser.CallExpr, error) {
+	var parts []parser.Node
+	switch n := node.(type) {
+	case *parser.UnaryExpr:
+		parts = []parser.Node{n.Operand}
+	case *parser.BinaryExpr:
+		parts = []parser.Node{n.Left, n.Right}
+	case *parser.Conditional:
+		parts = []parser.Node{n.Cond, n.Then, n.Else}
+	case *parser.Conversion:
+		parts = []parser.Node{n.Value}
+	case *parser.VectorLit:
+		parts = n.Elements
+	case *parser.CallExpr:
+		it, ok := parser.IterationOf(n)
+		if !ok {
+			parts = n.Args
+			break
+		}
+		nested, err := findIteration(n.Args[it.Body])
+		if err != nil {
+			return nil, err
+		}
+		if nested != nil {
+			return nil, &errors.SyntaxError{Pos: nested.Position, Token: nested.Name, Err: errors.ErrNestedIteration}
+		}
+		if lit := findImaginary(n); lit != nil {
+			return nil, &errors.SyntaxError{Pos: lit.Position, Token: lit.Text, Err: errors.ErrIterationMode}
+		}
+		return n, nil
+	}
+
+	for _, part := range parts {
+		if call, err := findIteration(part); call != nil || err != nil {
+			return call, err
+		}
+	}
+	return nil, nil
+}
+
+// iterationToken записывает интеграл или ряд в обратной польской записи одним токеном:
+// операция, связанная переменная и тело в скобках - "∫x[x sin/1]". Пределы идут перед токеном
+func iterationToken(name, variable string, program []string) string {
+	return string(iterationOperations[name]) + variable + "[" + strings.Join(program, " ") + "]"
+}
+
+// parseIterationToken разбирает токен, записанный iterationToken
+func parseIterationToken(token string) (name, variable string, program []string, ok bool) {
+	op, size := utf8.DecodeRuneInString(token)
+	switch Operation(op) {
+	case Integrate:
+		name = "integrate"
+	case Series:
+		name = "sum"
+	default:
+		return "", "", nil, false
+	}
+
+	variable, body, found := strings.Cut(token[size:], "[")
+	body, closed := strings.CutSuffix(body, "]")
+	program = strings.Fields(body)
+	if !found || !closed || variable == "" || len(program) == 0 {
+		return "", "", nil, false
+	}
+	return name, variable, program, true
+}
+
+// iterate делит отрезок [from, to] интеграла или ряда на участки: каждый участок -
+// задача, которую агент вычисляет целиком. Результаты участков складываются деревом задач
+func (p *planner) iterate(name, variable string, program []string, from, to string) string {
+	chunks := make([]string, shared.IterationChunks)
+	for i := range chunks {
+		chunks[i] = p.add(shared.Task{
+			FirstArgument:  from,
+			SecondArgument: to,
+			Operator:       rune(iterationOperations[name]),
+			Function:       name,
+			Program:        program,
+			Variable:       variable,
+			Chunk:          i,
+			Chunks:         len(chunks),
+		})
+	}
+	return p.aggregate("sum", chunks)
+}
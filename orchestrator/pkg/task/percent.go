@@ -0,0 +1,31 @@
+package task
+
+// Процент - сотая доля
+const percentBase = "100"
+
+// percentRPN записывает бинарную операцию с процентом справа так, как её понимает калькулятор:
+// процент при сложении и вычитании берётся от левого операнда, при умножении и делении - доля.
+//
+//	a + b% = a * (100 + b) / 100
+//	a - b% = a * (100 - b) / 100
+//	a * b% = a * b / 100
+//	a / b% = a * 100 / b
+//
+// Левый операнд участвует в записи один раз, поэтому вычисляется одной задачей, как и без процента.
+// Для остальных операций возвращает nil: процент в них - просто доля, a ^ b% = a ^ (b / 100)
+func percentRPN(op rune, left, percent []string) []string {
+	switch op {
+	case '+', '-':
+		output := append(left, percentBase)
+		output = append(output, percent...)
+		return append(output, string(op), "*", percentBase, "/")
+	case '*':
+		output := append(left, percent...)
+		return append(output, "*", percentBase, "/")
+	case '/':
+		output := append(left, percentBase, "*")
+		output = append(output, percent...)
+		return append(output, "/")
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package task
+
+import (
+	"maps"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/function"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/unit"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Plan - разобранное выражение или сценарий и задачи, на которые он раскладывается
+type Plan struct {
+	Statements []parser.Statement
+	RPN        [][]string // обратная польская запись каждой инструкции, величины с погрешностью - без выборок
+	Results    []string   // операнд с результатом каждой инструкции: число или idN
+	Units      []string   // единица результата каждой инструкции, у безразмерного - пустая строка
+	Shapes     [][]int    // форма результата каждой инструкции: у числа пустая, у вектора [n], у матрицы [m, n]
+	Uncertain  bool       // в сценарии есть величины с погрешностью
+	Tasks      []shared.Task
+	Result     string            // операнд с результатом всего сценария
+	Locals     map[string]string // операнды переменных сценария
+	Variables  map[string]float64
+	Functions  map[string]int
+}
+
+// PlanExpression разбирает выражение или сценарий и раскладывает его на задачи,
+// не трогая очередь. firstID - ID, который получит первая задача.
+// scope - переменные и функции пользователя, options - режим арифметики задач
+func PlanExpression(expression string, scope Scope, options Options, firstID int64) (*Plan, error) {
+	statements, err := parser.ParseScript(expression, options.Locale, scope.Names()...)
+	if err != nil {
+		return nil, err
+	}
+	return planStatements(statements, scope, options, firstID)
+}
+
+// planStatements раскладывает на задачи уже разобранный сценарий
+func planStatements(statements []parser.Statement, scope Scope, options Options, firstID int64) (*Plan, error) {
+	// Все инструкции сценария раскладываются в один план до того, как хоть одна
+	// задача попадёт в очередь. Переменные сценария ссылаются на задачи предыдущих инструкций
+	planner := &planner{nextID: firstID}
+	plan := &Plan{
+		Statements: statements,
+		Locals:     make(map[string]string),
+		Variables:  make(map[string]float64),
+		Functions:  make(map[string]int),
+	}
+
+	trees := make([]tensor, len(statements))
+	units := make([]unit.Expr, len(statements))
+	localUnits := make(map[string]unit.Expr)
+	vectors := &vectorizer{shapes: make(map[string][]int), intervals: options.Mode == shared.ModeInterval}
+	uncertain := false
+	var iteration *parser.CallExpr // первый интеграл или ряд сценария: с погрешностями они не сочетаются
+	for i, statement := range statements {
+		tree, functions, err := function.Expand(statement.Value, scope.Functions)
+		if err != nil {
+			return nil, err
+		}
+
+		// Тело интеграла и ряда агенты вычисляют сами над числами с плавающей точкой
+		call, err := findIteration(tree)
+		if err != nil {
+			return nil, err
+		}
+		if call != nil {
+			if options.Mode != shared.ModeFloat {
+				return nil, &errors.SyntaxError{Pos: call.Position, Token: call.Name, Err: errors.ErrIterationMode}
+			}
+			if iteration == nil {
+				iteration = call
+			}
+		}
+
+		// Размерности проверяются до раскладки на задачи: задачи получают числа в единицах СИ
+		if tree, units[i], err = resolveUnits(tree, localUnits); err != nil {
+			return nil, err
+		}
+		if statement.Name != "" {
+			localUnits[statement.Name] = units[i]
+		}
+		plan.Units = append(plan.Units, units[i].String())
+
+		// Векторы раскладываются на элементы: дальше инструкция - набор деревьев над числами
+		if trees[i], err = vectors.expand(tree); err != nil {
+			return nil, err
+		}
+		if statement.Name != "" {
+			vectors.shapes[statement.Name] = trees[i].shape
+		}
+		plan.Shapes = append(plan.Shapes, trees[i].shape)
+
+		for _, tree := range trees[i].elements {
+			// Точный и десятичный режимы работают только с вещественными числами
+			if lit := findImaginary(tree); lit != nil && options.Mode != shared.ModeFloat {
+				return nil, &errors.SyntaxError{Pos: lit.Position, Token: lit.Text, Err: errors.ErrComplexMode}
+			}
+			if lit := findUncertain(tree); lit != nil {
+				if options.Mode != shared.ModeFloat {
+					return nil, &errors.SyntaxError{Pos: lit.Position, Token: "±", Err: errors.ErrUncertainMode}
+				}
+				uncertain = true
+			}
+		}
+		maps.Copy(plan.Functions, functions)
+	}
+	plan.Uncertain = uncertain
+	if uncertain {
+		if vectors.first != nil {
+			return nil, &errors.SyntaxError{Pos: vectors.first.Pos(), Token: "[", Err: errors.ErrVectorMode}
+		}
+		if iteration != nil {
+			return nil, &errors.SyntaxError{Pos: iteration.Position, Token: iteration.Name, Err: errors.ErrIterationMode}
+		}
+		for _, tree := range trees {
+			if lit := findImaginary(tree.elements[0]); lit != nil {
+				return nil, &errors.SyntaxError{Pos: lit.Position, Token: lit.Text, Err: errors.ErrComplexArgument}
+			}
+		}
+	}
+
+	// Выражение с погрешностями раскладывается на задачи отдельно для каждой пачки выборок.
+	// Переменные сценария в каждой пачке ссылаются на задачи этой же пачки
+	sizes := []int{0}
+	if uncertain {
+		sizes = batchSizes(options.Samples)
+	}
+	results := make([][]string, len(statements)) // результат каждой инструкции в каждой пачке
+	for batch, size := range sizes {
+		locals := make(map[string][]string)
+		for i, statement := range statements {
+			// Каждый элемент вектора раскладывается на задачи отдельно,
+			// и агенты вычисляют элементы параллельно
+			var outputs [][]string
+			var elements []string
+			for _, tree := range trees[i].elements {
+				tree, variables, err := substituteVariables(tree, scope.Variables, locals)
+				if err != nil {
+					return nil, err
+				}
+				maps.Copy(plan.Variables, variables)
+
+				output := convertToRPN(tree, options)
+				outputs = append(outputs, output)
+				if uncertain {
+					output = convertToRPN(sampleUncertain(tree, size), options)
+				}
+
+				result, err := generateTasksFromRPN(planner, output)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, result)
+			}
+			if batch == 0 {
+				plan.RPN = append(plan.RPN, packRPN(trees[i].shape, outputs))
+			}
+			if statement.Name != "" {
+				locals[statement.Name] = elements
+			}
+
+			// Результат инструкции показывается в её единицах, переменные сценария
+			// в следующих инструкциях - по-прежнему в единицах СИ
+			displayed := make([]string, len(elements))
+			for j, result := range elements {
+				if conversion := unitConversion(result, units[i]); conversion != nil {
+					var err error
+					if result, err = generateTasksFromRPN(planner, convertToRPN(conversion, options)); err != nil {
+						return nil, err
+					}
+				}
+				displayed[j] = result
+			}
+			results[i] = append(results[i], planner.pack(displayed, trees[i].shape))
+		}
+	}
+
+	for i, statement := range statements {
+		result := results[i][0]
+		if uncertain {
+			result = planner.collect(results[i], sizes)
+		}
+		if statement.Name != "" {
+			plan.Locals[statement.Name] = result
+		}
+		plan.Results = append(plan.Results, result)
+		plan.Result = result
+	}
+
+	plan.Tasks = planner.tasks
+	for i := range plan.Tasks {
+		plan.Tasks[i].Mode, plan.Tasks[i].Precision, plan.Tasks[i].Rounding = options.Mode, options.Precision, options.Rounding
+	}
+	return plan, nil
+}
@@ -0,0 +1,226 @@
+package task
+
+import (
+	stderrors "errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/function"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// describe записывает задачу плана коротко: "id1 + 2", "sqrt(4)", "1 ? id2 : 5"
+func describe(task shared.Task) string {
+	switch {
+	case task.Function != "" && task.SecondArgument == "":
+		return task.Function + "(" + task.FirstArgument + ")"
+	case task.Function != "":
+		return task.Function + "(" + task.FirstArgument + ", " + task.SecondArgument + ")"
+	case task.Operator == '?':
+		return task.FirstArgument + " ? " + task.SecondArgument + " : " + task.ThirdArgument
+	}
+	return task.FirstArgument + " " + string(task.Operator) + " " + task.SecondArgument
+}
+
+func TestPlanExpression(t *testing.T) {
+	scope := Scope{Variables: map[string]float64{"x": 2}}
+	tests := []struct {
+		input  string
+		tasks  []string // задачи по порядку ID
+		result string
+	}{
+		{"1 + 2 * 3", []string{"2 * 3", "1 + id1"}, "id2"},
+		{"x * 3", []string{"2 * 3"}, "id1"},
+		{"a = 4; a - x", []string{"4 - 2"}, "id1"},
+		{"sqrt(4) + max(1, 2, 3)", []string{"sqrt(4)", "max(1, 2)", "max(id2, 3)", "id1 + id3"}, "id4"},
+		{"if(0, 1 / 0, 5)", []string{"0 ? id2 : 5", "1 / 0"}, "id1"},
+		{"0 and 1 / 0", []string{"0 ? id3 : 0", "1 / 0", "id2 ≠ 0"}, "id1"},
+	}
+
+	for _, tt := range tests {
+		plan, err := PlanExpression(tt.input, scope, Options{Mode: shared.ModeFloat, Locale: parser.DefaultLocale}, 1)
+		if err != nil {
+			t.Errorf("PlanExpression(%q): %v", tt.input, err)
+			continue
+		}
+
+		tasks := make(map[int64]string, len(plan.Tasks))
+		for _, task := range plan.Tasks {
+			tasks[task.ID] = describe(task)
+		}
+		var got []string
+		for id := int64(1); id <= int64(len(tasks)); id++ {
+			got = append(got, tasks[id])
+		}
+		if strings.Join(got, "; ") != strings.Join(tt.tasks, "; ") || plan.Result != tt.result {
+			t.Errorf("PlanExpression(%q) = %q, результат %s, want %q, %s", tt.input, got, plan.Result, tt.tasks, tt.result)
+		}
+	}
+}
+
+func TestPlanExpressionErrors(t *testing.T) {
+	g, err := function.Parse("g(x) = x * x * x * x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := Scope{Functions: map[string]*function.Function{"g": g}}
+
+	tests := []struct {
+		input string
+		mode  shared.Mode
+		err   error
+	}{
+		{"1 / 0", shared.ModeFloat, errors.ErrDivisionByZero},
+		{"5 // -0", shared.ModeExact, errors.ErrDivisionByZero},
+		{"3 / 0%", shared.ModeDecimal, errors.ErrDivisionByZero},
+		{"y + 1", shared.ModeFloat, errors.ErrUndefinedVariable},
+		{"a = 1; a + b", shared.ModeFloat, errors.ErrUndefinedVariable},
+		{"2i + 1", shared.ModeExact, errors.ErrComplexMode},
+		{"[2, 1] + 1", shared.ModeInterval, errors.ErrInvalidInterval},
+		{"[1, 2, 3]", shared.ModeInterval, errors.ErrVectorMode},
+		{"a = 5 ± 0.1; [a, 1]", shared.ModeFloat, errors.ErrVectorMode},
+		{"[1, 2] + [1, 2, 3]", shared.ModeFloat, errors.ErrShapeMismatch},
+		{"[[1, 2], [3]]", shared.ModeFloat, errors.ErrShapeMismatch},
+		{"x = 0; 1 / x", shared.ModeInterval, errors.ErrDivisionByZero},
+		{"5 ± 0.1", shared.ModeExact, errors.ErrUncertainMode},
+		{"a = 5 ± 0.1; a * 2i", shared.ModeFloat, errors.ErrComplexArgument},
+		{"1 m + 1 s", shared.ModeFloat, errors.ErrIncompatibleUnits},
+		{"3 km to h", shared.ModeFloat, errors.ErrIncompatibleUnits},
+		{"integrate(x, x, 0, 1)", shared.ModeExact, errors.ErrIterationMode},
+		{"sum(k, 1, 3, k * 2i)", shared.ModeFloat, errors.ErrIterationMode},
+		{"sum(k, 1, 3, [k, 1])", shared.ModeFloat, errors.ErrIterationMode},
+		{"a = 5 ± 0.1; a + sum(k, 1, 3, k)", shared.ModeFloat, errors.ErrIterationMode},
+		{"sum(k, 1, 3, integrate(x, x, 0, k))", shared.ModeFloat, errors.ErrNestedIteration},
+		{"g(g(g(g(g(g(g(g(1))))))))", shared.ModeFloat, errors.ErrExpressionTooLarge},
+	}
+
+	for _, tt := range tests {
+		options := Options{Mode: tt.mode, Precision: 10, Rounding: shared.RoundHalfEven, Locale: parser.DefaultLocale}
+		if _, err := PlanExpression(tt.input, scope, options, 1); !stderrors.Is(err, tt.err) {
+			t.Errorf("PlanExpression(%q, %s) = %v, want %v", tt.input, tt.mode, err, tt.err)
+		}
+	}
+}
+
+// Величины переводятся в единицы СИ, а результат инструкции - обратно в её единицы
+func TestPlanUnits(t *testing.T) {
+	tests := []struct {
+		input string
+		tasks []string
+		units []string
+	}{
+		{"1 m + 20 cm", []string{"1 + 0.2"}, []string{"m"}},
+		{"3 km / 20 min to km/h", []string{"3000 / 1200", "id1 * 3.6"}, []string{"km/h"}},
+		{"d = 3 km; d / 20 m", []string{"3000 / 1000", "3000 / 20"}, []string{"km", ""}},
+	}
+
+	for _, tt := range tests {
+		plan, err := PlanExpression(tt.input, Scope{}, Options{Mode: shared.ModeFloat, Locale: parser.DefaultLocale}, 1)
+		if err != nil {
+			t.Errorf("PlanExpression(%q): %v", tt.input, err)
+			continue
+		}
+		var tasks []string
+		for _, task := range plan.Tasks {
+			tasks = append(tasks, describe(task))
+		}
+		if !slices.Equal(tasks, tt.tasks) || !slices.Equal(plan.Units, tt.units) {
+			t.Errorf("PlanExpression(%q) = %q, единицы %q, want %q, %q", tt.input, tasks, plan.Units, tt.tasks, tt.units)
+		}
+	}
+}
+
+// Интеграл делится на участки, тело которых агент вычисляет сам, а переменная
+// тела в области видимости x = 2 не подставляется
+func TestPlanIteration(t *testing.T) {
+	scope := Scope{Variables: map[string]float64{"x": 2}}
+	plan, err := PlanExpression("integrate(x ^ 2, x, 0, x)", scope, Options{Mode: shared.ModeFloat, Locale: parser.DefaultLocale}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var chunks []int
+	for _, task := range plan.Tasks {
+		if Operation(task.Operator) != Integrate {
+			continue
+		}
+		if task.FirstArgument != "0" || task.SecondArgument != "2" || task.Variable != "x" ||
+			!slices.Equal(task.Program, []string{"x", "2", "^"}) || task.Chunks != shared.IterationChunks {
+			t.Errorf("участок интеграла: %+v", task)
+		}
+		chunks = append(chunks, task.Chunk)
+	}
+	if want := []int{0, 1, 2, 3, 4, 5, 6, 7}; !slices.Equal(chunks, want) {
+		t.Errorf("участки = %v, want %v", chunks, want)
+	}
+}
+
+// Ветки условия раскладываются в задачи, которые ждут выбора ветки
+func TestPlanBranches(t *testing.T) {
+	plan, err := PlanExpression("1 > 2 ? 1 / 0 : 3 + 4", Scope{}, Options{Mode: shared.ModeFloat, Locale: parser.DefaultLocale}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	branches := make(map[string]int)
+	for _, task := range plan.Tasks {
+		if task.Guard != 0 {
+			branches[task.Branch]++
+		}
+	}
+	if branches[shared.BranchThen] != 1 || branches[shared.BranchElse] != 1 {
+		t.Errorf("задачи веток: %v, want по одной в then и else", branches)
+	}
+}
+
+// Выражение с погрешностями раскладывается на задачи для каждой пачки выборок,
+// а результаты пачек объединяются задачами Collect
+func TestPlanUncertain(t *testing.T) {
+	options := Options{Mode: shared.ModeFloat, Locale: parser.DefaultLocale, Samples: 250}
+	plan, err := PlanExpression("a = 5 ± 0.1; a * 2 + 1", Scope{}, options, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batches []int
+	var result shared.Task
+	for _, task := range plan.Tasks {
+		if task.Operator == '*' {
+			samples, err := shared.ParseSamples(task.FirstArgument)
+			if err != nil {
+				t.Fatal(err)
+			}
+			batches = append(batches, len(samples))
+		}
+		if "id"+strconv.FormatInt(task.ID, 10) == plan.Result {
+			result = task
+		}
+	}
+	if !slices.Equal(batches, []int{100, 100, 50}) {
+		t.Errorf("пачки выборок: %v, want [100 100 50]", batches)
+	}
+	if Operation(result.Operator) != Collect || result.ThirdArgument != "200,50" {
+		t.Errorf("итоговая задача %+v, want объединение пачек 200,50", result)
+	}
+}
+
+func TestBatchSizes(t *testing.T) {
+	tests := []struct {
+		samples int
+		want    []int
+	}{
+		{0, slices.Repeat([]int{shared.SamplesPerBatch}, shared.DefaultSamples/shared.SamplesPerBatch)},
+		{100, []int{100}},
+		{250, []int{100, 100, 50}},
+	}
+
+	for _, tt := range tests {
+		if got := batchSizes(tt.samples); !slices.Equal(got, tt.want) {
+			t.Errorf("batchSizes(%d) = %v, want %v", tt.samples, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package task
+
+import (
+	"fmt"
+
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+// planner раскладывает выражение в список задач, назначая им последовательные ID.
+// Каждый метод возвращает операнд, которым можно сослаться на результат задачи
+type planner struct {
+	nextID   int64
+	tasks    []shared.Task
+	branches []branch // разбираемые сейчас ветки условий, внутренняя - последняя
+}
+
+// branch - ветка условия. Её задачи ждут условную задачу id и ставятся
+// в очередь, только если условие выберет эту ветку
+type branch struct {
+	id    int64
+	name  string // shared.BranchThen или shared.BranchElse
+	cond  string // операнд условия
+	then  string // результат ветки then, известен после её разбора
+	depth int    // размер стека операндов перед веткой
+}
+
+// add добавляет задачу и возвращает ссылку на её результат вида idN.
+// Задача, добавленная внутри ветки условия, ждёт выбора этой ветки
+func (p *planner) add(task shared.Task) string {
+	if task.ID == 0 {
+		task.ID = p.nextID
+		p.nextID++
+	}
+	if len(p.branches) > 0 {
+		current := p.branches[len(p.branches)-1]
+		task.Guard, task.Branch = current.id, current.name
+	}
+	p.tasks = append(p.tasks, task)
+	return fmt.Sprintf("id%d", task.ID)
+}
+
+// openBranch начинает ветку then условия cond. ID условной задачи выделяется
+// сразу: на него ссылаются задачи обеих веток
+func (p *planner) openBranch(cond string, depth int) {
+	p.branches = append(p.branches, branch{id: p.nextID, name: shared.BranchThen, cond: cond, depth: depth})
+	p.nextID++
+}
+
+// elseBranch завершает ветку then с результатом then и начинает ветку else
+func (p *planner) elseBranch(then string) {
+	current := &p.branches[len(p.branches)-1]
+	current.then, current.name = then, shared.BranchElse
+}
+
+// closeBranch завершает ветку else с результатом otherwise и добавляет условную задачу
+func (p *planner) closeBranch(otherwise string) string {
+	current := p.branches[len(p.branches)-1]
+	p.branches = p.branches[:len(p.branches)-1]
+	return p.add(shared.Task{
+		ID:             current.id,
+		FirstArgument:  current.cond,
+		SecondArgument: current.then,
+		ThirdArgument:  otherwise,
+		Operator:       rune(Condition),
+	})
+}
+
+// balanced проверяет, что внутри текущей ветки на стеке операндов появился ровно один операнд
+func (p *planner) balanced(depth int) bool {
+	return len(p.branches) > 0 && depth == p.branches[len(p.branches)-1].depth+1
+}
+
+// binary добавляет задачу с бинарной операцией
+func (p *planner) binary(op Operation, arg1, arg2 string) string {
+	return p.add(shared.Task{
+		FirstArgument:  arg1,
+		SecondArgument: arg2,
+		Operator:       rune(op),
+	})
+}
+
+// call добавляет задачу-вызов функции одного или двух аргументов
+func (p *planner) call(name string, args ...string) string {
+	task := shared.Task{
+		FirstArgument: args[0],
+		Operator:      rune(Function),
+		Function:      name,
+	}
+	if len(args) > 1 {
+		task.SecondArgument = args[1]
+	}
+	return p.add(task)
+}
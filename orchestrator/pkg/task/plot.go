@@ -0,0 +1,326 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Столбцы таблицы plots в том порядке, в котором их читает scanPlot
+const plotColumns = "id, user_id, expression, variable, range_from, range_to, status, error, unit, points"
+
+// PlotPlan - выражение, разложенное на задачи в каждой точке графика
+type PlotPlan struct {
+	Statements []parser.Statement
+	X          []float64
+	Points     []string // значение в каждой точке: число, idN или пустая строка, если выражение в точке не определено
+	Unit       string   // единица значений выражения
+	Error      string   // почему не вычислена первая из точек без значения
+	Tasks      []shared.Task
+}
+
+// abscissas расставляет points точек на отрезке [from, to] с равным шагом. Крайние точки - сами концы отрезка
+func abscissas(from, to float64, points int) []float64 {
+	xs := make([]float64, points)
+	for i := range xs {
+		xs[i] = from + (to-from)*float64(i)/float64(points-1)
+	}
+	xs[points-1] = to
+	return xs
+}
+
+// PlanPlot разбирает выражение или сценарий и раскладывает его на задачи в каждой
+// из points точек отрезка [from, to]: в точке variable - сохранённая переменная с её абсциссой.
+// Точки раскладываются независимо, и агенты вычисляют их параллельно. Ошибка разбора
+// одинакова во всех точках и возвращается сразу; точка, в которой выражение не определено
+// ещё при раскладке (1/x при x = 0), остаётся без значения
+func PlanPlot(expression, variable string, from, to float64, points int, scope Scope, options Options, firstID int64) (*PlotPlan, error) {
+	statements, err := parser.ParseScript(expression, options.Locale, append(scope.Names(), variable)...)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &PlotPlan{Statements: statements, X: abscissas(from, to, points)}
+	variables := make(map[string]float64, len(scope.Variables)+1)
+	maps.Copy(variables, scope.Variables)
+
+	var failure error
+	for _, x := range plan.X {
+		variables[variable] = x
+		point, err := planStatements(statements, Scope{Variables: variables, Functions: scope.Functions}, options, firstID)
+		if err != nil {
+			var syntaxErr *errors.SyntaxError
+			if stderrors.As(err, &syntaxErr) {
+				return nil, err
+			}
+			if failure == nil {
+				failure = err
+			}
+			plan.Points = append(plan.Points, "")
+			continue
+		}
+
+		last := len(statements) - 1
+		if point.Uncertain || len(point.Shapes[last]) > 0 {
+			return nil, errors.ErrPlotValue
+		}
+		plan.Unit = point.Units[last]
+		plan.Points = append(plan.Points, point.Result)
+		plan.Tasks = append(plan.Tasks, point.Tasks...)
+		firstID += int64(len(point.Tasks))
+	}
+
+	if failure != nil {
+		// Выражение, не определённое ни в одной точке, - ошибка самого запроса
+		if strings.Join(plan.Points, "") == "" {
+			return nil, failure
+		}
+		plan.Error = failure.Error()
+	}
+	return plan, nil
+}
+
+// ParsePlot разбирает выражение графика и ставит задачи всех его точек в очередь.
+// Возвращает ID графика, по которому его можно опрашивать, как выражение
+func (q *Queue) ParsePlot(ctx context.Context, request shared.PlotRequest, scope Scope, options Options) (int64, error) {
+	// ID задач и графика выбираются и занимаются в одной транзакции, как у выражений
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // после Commit ничего не делает
+
+	firstID, err := nextID(tx, "tasks")
+	if err != nil {
+		log.Printf("Ошибка при получении следующего ID задачи: %v", err)
+		return 0, err
+	}
+	plan, err := PlanPlot(request.Expression, request.Variable, request.From, request.To, request.Points, scope, options, firstID)
+	if err != nil {
+		return 0, err
+	}
+
+	points, err := json.Marshal(plan.Points)
+	if err != nil {
+		return 0, err
+	}
+
+	nextPlotID, err := nextID(tx, "plots")
+	if err != nil {
+		log.Printf("Ошибка при получении следующего ID графика: %v", err)
+		return 0, err
+	}
+
+	deps := Dependencies(plan.Tasks)
+	for _, task := range plan.Tasks {
+		task.DependsOn = deps[task.ID]
+		if err := insertTask(tx, task); err != nil {
+			log.Printf("Ошибка при добавлении задачи %d: %v", task.ID, err)
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO plots (id, user_id, expression, variable, range_from, range_to, status, error, unit, points) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		nextPlotID,
+		ctx.Value(middleware.UserID).(int64),
+		parser.FormatScript(plan.Statements),
+		request.Variable,
+		request.From,
+		request.To,
+		len(plan.Tasks) == 0, // график без задач (константа) построен сразу
+		plan.Error,
+		plan.Unit,
+		string(points),
+	)
+	if err != nil {
+		log.Printf("Ошибка при добавлении графика %d: %v", nextPlotID, err)
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка при сохранении графика %d: %v", nextPlotID, err)
+		return 0, err
+	}
+
+	// Условия, которые уже можно проверить, выполняются сразу, как и у выражений
+	for _, task := range plan.Tasks {
+		if isControl(Operation(task.Operator)) {
+			if err := q.UpdateTasks(); err != nil {
+				log.Printf("Ошибка при обновлении задач графика %d: %v", nextPlotID, err)
+			}
+			if err := q.UpdatePlots(); err != nil {
+				log.Printf("Ошибка при обновлении графиков после графика %d: %v", nextPlotID, err)
+			}
+			break
+		}
+	}
+
+	return nextPlotID, nil
+}
+
+// plotRecord - строка таблицы plots: график вместе со значениями в точках
+type plotRecord struct {
+	shared.Plot
+	points []string
+}
+
+// scanPlot читает график из строки результата запроса по столбцам plotColumns
+func scanPlot(row scanner) (plotRecord, error) {
+	var record plotRecord
+	var points string
+	err := row.Scan(&record.ID, &record.UserID, &record.Expression, &record.Variable, &record.From, &record.To, &record.Status, &record.Error, &record.Unit, &points)
+	if err != nil {
+		return record, err
+	}
+	return record, json.Unmarshal([]byte(points), &record.points)
+}
+
+// UpdatePlots подставляет в графики значения точек, задачи которых выполнены
+func (q *Queue) UpdatePlots() error {
+	rows, err := q.db.Query("SELECT " + plotColumns + " FROM plots WHERE status = 0")
+	if err != nil {
+		return err
+	}
+
+	// Графики обновляются после чтения, как и выражения
+	var pending []plotRecord
+	for rows.Next() {
+		record, err := scanPlot(rows)
+		if err != nil {
+			log.Printf("Ошибка при сканировании графика: %v", err)
+			continue
+		}
+		pending = append(pending, record)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при итерации по строкам: %v", err)
+	}
+
+	for _, record := range pending {
+		if err := q.updatePlot(record); err != nil {
+			log.Printf("Ошибка при обновлении графика %d: %v", record.ID, err)
+		}
+	}
+	return nil
+}
+
+// updatePlot заменяет ссылки на выполненные задачи значениями в точках. Проваленная
+// задача оставляет точку без значения. График построен, когда ссылок не осталось
+func (q *Queue) updatePlot(record plotRecord) error {
+	// Задачи всех точек графика читаются одним запросом: точек может быть до shared.MaxPlotPoints
+	var ids []any
+	for _, point := range record.points {
+		if IsReference(point) {
+			id, err := strconv.ParseInt(strings.TrimPrefix(point, "id"), 10, 64)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+		rows, err := q.db.Query("SELECT "+taskColumns+" FROM tasks WHERE status = 1 AND id IN ("+placeholders+")", ids...)
+		if err != nil {
+			return err
+		}
+		finished := make(map[string]shared.Task)
+		for rows.Next() {
+			task, err := scanTask(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			finished[fmt.Sprintf("id%d", task.ID)] = task
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(finished) == 0 {
+			return nil
+		}
+
+		for i, point := range record.points {
+			task, ok := finished[point]
+			if !ok {
+				continue
+			}
+			record.points[i] = ""
+			if task.Error == "" {
+				record.points[i] = resultValue(task)
+			} else if record.Error == "" {
+				record.Error = task.Error
+			}
+		}
+	}
+
+	record.Status = true
+	for _, point := range record.points {
+		record.Status = record.Status && !IsReference(point)
+	}
+	if record.Status {
+		log.Printf("График %d построен!\n", record.ID)
+	}
+
+	points, err := json.Marshal(record.points)
+	if err != nil {
+		return err
+	}
+	_, err = q.db.Exec(
+		"UPDATE plots SET status = ?, error = ?, points = ? WHERE id = ?",
+		record.Status, record.Error, string(points), record.ID,
+	)
+	return err
+}
+
+// FindPlot возвращает график с уже известными значениями в точках
+func (q *Queue) FindPlot(id int64) *shared.Plot {
+	record, err := scanPlot(q.db.QueryRow("SELECT "+plotColumns+" FROM plots WHERE id = ?", id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Ошибка при поиске графика %d: %v", id, err)
+		}
+		return nil
+	}
+
+	plot := record.Plot
+	known := 0
+	for i, x := range abscissas(plot.From, plot.To, len(record.points)) {
+		point := shared.PlotPoint{X: x}
+		if value := record.points[i]; !IsReference(value) {
+			known++
+			point.Y = plotValue(value)
+		}
+		plot.Series = append(plot.Series, point)
+	}
+	plot.Progress = float64(known) / float64(len(record.points))
+	return &plot
+}
+
+// plotValue переводит значение в точке в ординату. У точки без значения
+// и с комплексным или бесконечным значением ординаты нет
+func plotValue(value string) *float64 {
+	if value == "" {
+		return nil
+	}
+	z, err := approximate(value)
+	if err != nil || imag(z) != 0 || math.IsInf(real(z), 0) || math.IsNaN(real(z)) {
+		return nil
+	}
+	y := real(z)
+	return &y
+}
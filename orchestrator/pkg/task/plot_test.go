@@ -0,0 +1,161 @@
+package task
+
+import (
+	stderrors "errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+func TestAbscissas(t *testing.T) {
+	if got := abscissas(-1, 1, 5); !slices.Equal(got, []float64{-1, -0.5, 0, 0.5, 1}) {
+		t.Errorf("abscissas(-1, 1, 5) = %v", got)
+	}
+	// Последняя точка - ровно конец отрезка, без ошибки округления шага
+	if got := abscissas(0, 0.3, 4); got[3] != 0.3 {
+		t.Errorf("abscissas(0, 0.3, 4) = %v, want последнюю точку 0.3", got)
+	}
+}
+
+func TestPlanPlot(t *testing.T) {
+	// Переменная графика после числа - множитель, а не единица: t - не тонна
+	plan, err := PlanPlot("2t + 1", "t", 0, 1, 3, Scope{}, floatMode, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parser.FormatScript(plan.Statements); got != "2 * t + 1" {
+		t.Errorf("выражение графика = %q, want %q", got, "2 * t + 1")
+	}
+	if !slices.Equal(plan.Points, []string{"id2", "id4", "id6"}) || len(plan.Tasks) != 6 {
+		t.Errorf("точки %q, задач %d, want [id2 id4 id6] и 6 задач", plan.Points, len(plan.Tasks))
+	}
+
+	tests := []struct {
+		input    string
+		from, to float64
+		err      error
+	}{
+		{"[x, 1]", 0, 1, errors.ErrPlotValue},
+		{"x + (5 ± 0.1)", 0, 1, errors.ErrPlotValue},
+		{"1 / x", 0, 0, errors.ErrDivisionByZero},
+		{"x +", 0, 1, errors.ErrNotEnoughOperands},
+	}
+	for _, tt := range tests {
+		if _, err := PlanPlot(tt.input, "x", tt.from, tt.to, 3, Scope{}, floatMode, 1); !stderrors.Is(err, tt.err) {
+			t.Errorf("PlanPlot(%q) = %v, want %v", tt.input, err, tt.err)
+		}
+	}
+}
+
+// ordinates возвращает ординаты серии графика, NaN - точка без значения
+func ordinates(plot *shared.Plot) []float64 {
+	ys := make([]float64, len(plot.Series))
+	for i, point := range plot.Series {
+		ys[i] = math.NaN()
+		if point.Y != nil {
+			ys[i] = *point.Y
+		}
+	}
+	return ys
+}
+
+func equalOrdinates(a, b []float64) bool {
+	return slices.EqualFunc(a, b, func(x, y float64) bool {
+		return x == y || math.IsNaN(x) && math.IsNaN(y)
+	})
+}
+
+// Точка, не определённая ещё при раскладке, остаётся пустой, остальные заполняются
+// по мере выполнения задач
+func TestQueuePlot(t *testing.T) {
+	q := newQueue(t)
+	request := shared.PlotRequest{Expression: "1 / x", Variable: "x", From: -2, To: 2, Points: 5}
+	id, err := q.ParsePlot(userContext(), request, Scope{}, floatMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plot := q.FindPlot(id)
+	if plot == nil || plot.Status || plot.Progress != 0.2 || plot.UserID != 1 {
+		t.Fatalf("график до вычисления = %+v, want 1 из 5 точек", plot)
+	}
+
+	runAgent(t, q)
+	if err := q.UpdatePlots(); err != nil {
+		t.Fatal(err)
+	}
+
+	plot = q.FindPlot(id)
+	want := []float64{-0.5, -1, math.NaN(), 1, 0.5}
+	if !plot.Status || plot.Progress != 1 || !equalOrdinates(ordinates(plot), want) {
+		t.Errorf("график = %+v, ординаты %v, want %v", plot, ordinates(plot), want)
+	}
+	if !strings.Contains(plot.Error, errors.ErrDivisionByZero.Error()) {
+		t.Errorf("ошибка графика = %q, want деление на ноль", plot.Error)
+	}
+	if q.FindPlot(id+1) != nil {
+		t.Errorf("FindPlot(%d) нашёл несуществующий график", id+1)
+	}
+}
+
+// Проваленная задача оставляет точку без значения, а график всё равно строится
+func TestQueuePlotFailedPoint(t *testing.T) {
+	q := newQueue(t)
+	request := shared.PlotRequest{Expression: "sqrt(x) + 1", Variable: "x", From: -1, To: 1, Points: 3}
+	id, err := q.ParsePlot(userContext(), request, Scope{}, floatMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, task := range q.GetTasks() {
+		if task.Function != "sqrt" {
+			continue
+		}
+		if x := parseFloat(t, task.FirstArgument); x < 0 {
+			q.Fail(task.ID, "sqrt(-1): вне области определения")
+		} else {
+			q.Done(shared.TaskResult{ID: task.ID, Result: math.Sqrt(x)})
+		}
+	}
+	if err := q.UpdateTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.UpdatePlots(); err != nil {
+		t.Fatal(err)
+	}
+	if plot := q.FindPlot(id); plot.Status || plot.Progress != 1.0/3 {
+		t.Errorf("график до сложений = %+v, want 1 из 3 точек", plot)
+	}
+
+	runAgent(t, q)
+	if err := q.UpdatePlots(); err != nil {
+		t.Fatal(err)
+	}
+
+	plot := q.FindPlot(id)
+	want := []float64{math.NaN(), 1, 2}
+	if !plot.Status || !equalOrdinates(ordinates(plot), want) || plot.Error != "sqrt(-1): вне области определения" {
+		t.Errorf("график = %+v, ординаты %v, want %v", plot, ordinates(plot), want)
+	}
+}
+
+// Если график не удалось записать, его задачи тоже не остаются в очереди
+func TestQueuePlotRollback(t *testing.T) {
+	q := newQueue(t)
+	if _, err := q.db.Exec("DROP TABLE plots"); err != nil {
+		t.Fatal(err)
+	}
+
+	request := shared.PlotRequest{Expression: "x * 2", Variable: "x", From: 0, To: 1, Points: 3}
+	if _, err := q.ParsePlot(userContext(), request, Scope{}, floatMode); err == nil {
+		t.Fatal("ParsePlot без таблицы графиков без ошибки")
+	}
+	if tasks := q.GetTasks(); len(tasks) != 0 {
+		t.Errorf("после отката осталось задач: %d", len(tasks))
+	}
+}
@@ -0,0 +1,176 @@
+package task
+
+import (
+	"context"
+	stderrors "errors"
+	"maps"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/function"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// newQueue открывает очередь во временной базе, своей для каждого теста
+func newQueue(t *testing.T) *Queue {
+	t.Helper()
+	q, err := NewQueue(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+// Режим арифметики по умолчанию
+var floatMode = Options{Mode: shared.ModeFloat, Locale: parser.DefaultLocale}
+
+// userContext - контекст запроса пользователя 1
+func userContext() context.Context {
+	return context.WithValue(context.Background(), middleware.UserID, int64(1))
+}
+
+// Ошибка функции проваливает зависящие от неё задачи и всё выражение
+func TestQueueFailure(t *testing.T) {
+	q := newQueue(t)
+	id, err := q.ParseExpression(userContext(), "sqrt(-1) + 1", Scope{}, floatMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Fail(1, "sqrt(-1): вне области определения")
+	if task := q.FindTask(2); task == nil || !task.Status || task.Error == "" {
+		t.Errorf("задача id2 = %+v, want проваленную", task)
+	}
+	expr := q.FindExpression(id)
+	if expr == nil || !expr.Status || expr.Error != "sqrt(-1): вне области определения" {
+		t.Errorf("выражение = %+v, want ошибку sqrt", expr)
+	}
+}
+
+// Выражение запоминает значения переменных, с которыми оно вычисляется
+func TestQueueVariablesSnapshot(t *testing.T) {
+	q := newQueue(t)
+	id, err := q.ParseExpression(userContext(), "x + 1", Scope{Variables: map[string]float64{"x": 2, "y": 5}}, floatMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expr := q.FindExpression(id)
+	if expr == nil || len(expr.Variables) != 1 || expr.Variables["x"] != 2 {
+		t.Errorf("выражение = %+v, want переменные {x: 2}", expr)
+	}
+	if task := q.FindTask(1); task == nil || task.FirstArgument != "2" {
+		t.Errorf("задача id1 = %+v, want 2 + 1", task)
+	}
+}
+
+// Тело функции пользователя подставляется в выражение, а её версия запоминается
+func TestQueueFunctionsSnapshot(t *testing.T) {
+	sq, err := function.Parse("sq(x) = x * x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sq.Version = 2
+
+	q := newQueue(t)
+	id, err := q.ParseExpression(userContext(), "sq(3)", Scope{Functions: map[string]*function.Function{"sq": sq}}, floatMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expr := q.FindExpression(id)
+	if expr == nil || len(expr.Functions) != 1 || expr.Functions["sq"] != 2 {
+		t.Errorf("выражение = %+v, want функции {sq: 2}", expr)
+	}
+	if task := q.FindTask(1); task == nil || task.FirstArgument != "3" || task.SecondArgument != "3" || task.Operator != '*' {
+		t.Errorf("задача id1 = %+v, want 3 * 3", task)
+	}
+}
+
+// Бесконечный результат не записывается: задача и выражение проваливаются
+func TestQueueDoneInfinite(t *testing.T) {
+	q := newQueue(t)
+	id, err := q.ParseExpression(userContext(), "10 ^ 400 - 1", Scope{}, floatMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Done(shared.TaskResult{ID: 1, Result: math.Inf(1), Value: "+Inf"})
+	expr := q.FindExpression(id)
+	if expr == nil || !expr.Status || expr.Error != errors.ErrOverflow.Error() {
+		t.Errorf("выражение = %+v, want ошибку %q", expr, errors.ErrOverflow)
+	}
+}
+
+// В точном режиме литералы передаются агентам дробями, а результат хранится без потерь
+func TestQueueExact(t *testing.T) {
+	q := newQueue(t)
+	id, err := q.ParseExpression(userContext(), "0.1 + 0.2", Scope{}, Options{Mode: shared.ModeExact, Precision: 3, Locale: parser.DefaultLocale})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task := q.FindTask(1); task == nil || task.FirstArgument != "1/10" || task.SecondArgument != "1/5" {
+		t.Fatalf("задача id1 = %+v, want 1/10 + 1/5", task)
+	}
+
+	q.Done(shared.TaskResult{ID: 1, Result: 0.3, Value: "3/10"})
+	expr := q.FindExpression(id)
+	if expr == nil || expr.Result != "0.300" || expr.Fraction != "3/10" {
+		t.Errorf("выражение = %+v, want 0.300 = 3/10", expr)
+	}
+}
+
+// Мнимые числа доступны только в режиме float
+func TestQueueComplexMode(t *testing.T) {
+	q := newQueue(t)
+	_, err := q.ParseExpression(userContext(), "1 + 2i", Scope{}, Options{Mode: shared.ModeExact, Locale: parser.DefaultLocale})
+	var syntaxErr *errors.SyntaxError
+	if !stderrors.As(err, &syntaxErr) || !stderrors.Is(err, errors.ErrComplexMode) || syntaxErr.Pos != 4 {
+		t.Errorf("ParseExpression(1 + 2i, exact) = %v, want %v на позиции 4", err, errors.ErrComplexMode)
+	}
+}
+
+// Переменные сценария вычисляются один раз, их значения возвращаются в results
+func TestQueueScript(t *testing.T) {
+	q := newQueue(t)
+	id, err := q.ParseExpression(userContext(), "a = 3*4; b = a + 7; b / 2", Scope{}, floatMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handed := runAgent(t, q); len(handed) != 3 {
+		t.Errorf("агентам выдано %d задач, want 3", len(handed))
+	}
+
+	expr := q.FindExpression(id)
+	if expr == nil || expr.Result != "9.5" || !maps.Equal(expr.Results, map[string]string{"a": "12", "b": "19"}) {
+		t.Errorf("выражение = %+v, want 9.5, a = 12, b = 19", expr)
+	}
+
+	// Ошибка в любой инструкции отклоняет сценарий целиком
+	if _, err := q.ParseExpression(userContext(), "a = 1; b = c", Scope{}, floatMode); !stderrors.Is(err, errors.ErrUndefinedVariable) {
+		t.Errorf("ParseExpression(a = 1; b = c) = %v, want %v", err, errors.ErrUndefinedVariable)
+	}
+	if tasks := q.GetTasks(); len(tasks) != 3 {
+		t.Errorf("после ошибки в очереди %d задач, want 3", len(tasks))
+	}
+}
+
+// Если выражение не удалось записать, его задачи тоже не остаются в очереди
+func TestQueueParseRollback(t *testing.T) {
+	q := newQueue(t)
+	if _, err := q.db.Exec("DROP TABLE expressions"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := q.ParseExpression(userContext(), "1 + 2 * 3", Scope{}, floatMode); err == nil {
+		t.Fatal("ParseExpression без таблицы выражений без ошибки")
+	}
+	if tasks := q.GetTasks(); len(tasks) != 0 {
+		t.Errorf("после отката осталось задач: %d", len(tasks))
+	}
+}
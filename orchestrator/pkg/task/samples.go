@@ -0,0 +1,169 @@
+package task
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// findUncertain возвращает первую величину с погрешностью в дереве или nil, если их нет
+func findUncertain(node parser.Node) *parser.UncertainLit {
+	switch n := node.(type) {
+	case *parser.UncertainLit:
+		return n
+	case *parser.UnaryExpr:
+		return findUncertain(n.Operand)
+	case *parser.BinaryExpr:
+		if lit := findUncertain(n.Left); lit != nil {
+			return lit
+		}
+		return findUncertain(n.Right)
+	case *parser.CallExpr:
+		for _, arg := range n.Args {
+			if lit := findUncertain(arg); lit != nil {
+				return lit
+			}
+		}
+	case *parser.Conditional:
+		for _, part := range []parser.Node{n.Cond, n.Then, n.Else} {
+			if lit := findUncertain(part); lit != nil {
+				return lit
+			}
+		}
+	}
+	return nil
+}
+
+// batchSizes делит выборки на пачки по shared.SamplesPerBatch значений, последняя может быть меньше
+func batchSizes(samples int) []int {
+	if samples == 0 {
+		samples = shared.DefaultSamples
+	}
+	var sizes []int
+	for ; samples > 0; samples -= shared.SamplesPerBatch {
+		sizes = append(sizes, min(samples, shared.SamplesPerBatch))
+	}
+	return sizes
+}
+
+// sampleUncertain заменяет величины с погрешностью пачками из size случайных значений их
+// нормального распределения. Величина, подставленная в несколько мест (аргумент
+// функции пользователя), получает одну пачку на все вхождения: это одно и то же измерение
+func sampleUncertain(node parser.Node, size int) parser.Node {
+	drawn := make(map[*parser.UncertainLit]parser.Node)
+
+	var sample func(node parser.Node) parser.Node
+	sample = func(node parser.Node) parser.Node {
+		switch n := node.(type) {
+		case *parser.UncertainLit:
+			if samples, ok := drawn[n]; ok {
+				return samples
+			}
+			mean, sigma := parser.BoundValue(n.Mean), parser.BoundValue(n.Sigma)
+			values := make(shared.Samples, size)
+			for i := range values {
+				values[i] = mean + sigma*rand.NormFloat64()
+			}
+			drawn[n] = &operand{Position: n.Position, Value: values.String()}
+			return drawn[n]
+		case *parser.UnaryExpr:
+			return &parser.UnaryExpr{Position: n.Position, Op: n.Op, Operand: sample(n.Operand)}
+		case *parser.BinaryExpr:
+			return &parser.BinaryExpr{Position: n.Position, Op: n.Op, Left: sample(n.Left), Right: sample(n.Right)}
+		case *parser.CallExpr:
+			args := make([]parser.Node, len(n.Args))
+			for i, arg := range n.Args {
+				args[i] = sample(arg)
+			}
+			return &parser.CallExpr{Position: n.Position, Name: n.Name, Args: args}
+		case *parser.Conditional:
+			return &parser.Conditional{Position: n.Position, Cond: sample(n.Cond), Then: sample(n.Then), Else: sample(n.Else)}
+		}
+		return node
+	}
+	return sample(node)
+}
+
+// collect объединяет результаты пачек сбалансированным деревом задач Collect.
+// sizes - число значений в каждой пачке: результат пачки может оказаться числом
+// (ветка условия без погрешностей), и тогда он повторяется столько раз
+func (p *planner) collect(operands []string, sizes []int) string {
+	for len(operands) > 1 {
+		var next []string
+		var nextSizes []int
+		for i := 0; i+1 < len(operands); i += 2 {
+			next = append(next, p.add(shared.Task{
+				FirstArgument:  operands[i],
+				SecondArgument: operands[i+1],
+				ThirdArgument:  fmt.Sprintf("%d,%d", sizes[i], sizes[i+1]),
+				Operator:       rune(Collect),
+			}))
+			nextSizes = append(nextSizes, sizes[i]+sizes[i+1])
+		}
+		if len(operands)%2 == 1 {
+			next = append(next, operands[len(operands)-1])
+			nextSizes = append(nextSizes, sizes[len(sizes)-1])
+		}
+		operands, sizes = next, nextSizes
+	}
+	return operands[0]
+}
+
+// collect завершает задачу Collect объединённой пачкой выборок
+func (q *Queue) collect(task shared.Task) {
+	value, err := concatSamples(task)
+	if err != nil {
+		q.Fail(task.ID, err.Error())
+		return
+	}
+	mean, err := approximate(value)
+	if err != nil {
+		q.Fail(task.ID, err.Error())
+		return
+	}
+
+	_, err = q.db.Exec(
+		"UPDATE tasks SET status = ?, result = ?, imag = 0, value = ? WHERE id = ?",
+		true, real(mean), value, task.ID,
+	)
+	if err != nil {
+		log.Printf("Ошибка при обновлении задачи %d: %v", task.ID, err)
+	}
+}
+
+// concatSamples объединяет пачки из аргументов задачи Collect. Два одинаковых числа
+// остаются числом: результат не зависит от выборки
+func concatSamples(task shared.Task) (string, error) {
+	if !shared.IsSamples(task.FirstArgument) && task.FirstArgument == task.SecondArgument {
+		return task.FirstArgument, nil
+	}
+
+	sizes := strings.Split(task.ThirdArgument, ",")
+	if len(sizes) != 2 {
+		return "", fmt.Errorf("%w: размеры пачек %q", errors.ErrInvalidNumber, task.ThirdArgument)
+	}
+	var samples shared.Samples
+	for i, arg := range []string{task.FirstArgument, task.SecondArgument} {
+		values, err := shared.ParseSamples(arg)
+		if err != nil {
+			return "", err
+		}
+		if !shared.IsSamples(arg) {
+			size, err := strconv.Atoi(sizes[i])
+			if err != nil {
+				return "", fmt.Errorf("%w: размеры пачек %q", errors.ErrInvalidNumber, task.ThirdArgument)
+			}
+			for range size - 1 {
+				values = append(values, values[0])
+			}
+		}
+		samples = append(samples, values...)
+	}
+	return samples.String(), nil
+}
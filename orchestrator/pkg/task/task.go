@@ -3,13 +3,20 @@ package task
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/nktauserum/web-calculation/orchestrator/internal/controller/middleware"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/function"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
 	"github.com/nktauserum/web-calculation/shared"
 	"github.com/nktauserum/web-calculation/shared/errors"
 )
@@ -22,12 +29,146 @@ const (
 	Subtract Operation = '-'
 	Multiply Operation = '*'
 	Divide   Operation = '/'
+	Power    Operation = '^'
+	Function Operation = 'f' // вызов функции, её имя хранится в Task.Function
+
+	// Целочисленные операции. Многосимвольные операторы записываются одним символом
+	Modulo     Operation = '%'
+	IntDivide  Operation = '÷' // //
+	BitAnd     Operation = '&'
+	BitOr      Operation = '|'
+	BitXor     Operation = '⊕' // xor
+	ShiftLeft  Operation = '≪' // <<
+	ShiftRight Operation = '≫' // >>
+
+	// Сравнения. Результат - 1, если сравнение истинно, иначе 0
+	Less         Operation = '<'
+	Greater      Operation = '>'
+	LessEqual    Operation = '≤' // <=
+	GreaterEqual Operation = '≥' // >=
+	Equal        Operation = '=' // ==
+	NotEqual     Operation = '≠' // !=
+
+	// Участки интеграла и ряда. Агент вычисляет тело Task.Program на своём участке отрезка
+	// [FirstArgument, SecondArgument] сам, а оркестратор складывает результаты участков
+	Integrate Operation = '∫'
+	Series    Operation = 'Σ'
+
+	// Задачи, которые выполняет сам оркестратор, а не агенты.
+	// Condition выбирает ветку по условию в первом аргументе, ветки - во втором и третьем.
+	// Forward передаёт результат выбранной ветки задачам, которые ссылаются на условие.
+	// Collect объединяет пачки выборок выражения с погрешностями, размеры пачек - в третьем аргументе.
+	// Pack собирает вектор из результатов элементов, размеры частей - в третьем аргументе
+	Condition Operation = '?'
+	Forward   Operation = '→'
+	Collect   Operation = '∪'
+	Pack      Operation = '⊞'
 )
 
+// Столбцы таблицы tasks в том порядке, в котором их читает scanTask
+const taskColumns = "id, first_argument, second_argument, third_argument, operator, function, status, result, imag, value, mode, precision, rounding, error, guard, branch, expression_id, depends_on, started_at, program, variable, chunk, chunks"
+
+// Столбцы таблицы expressions в том порядке, в котором их читает scanExpression
+const expressionColumns = "id, user_id, expression, status, result, error, variables, functions, mode, precision, rounding, fraction, results, distribution, unit, units"
+
+// scanner - общий интерфейс для *sql.Row и *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTask читает задачу из строки результата запроса по столбцам taskColumns
+func scanTask(row scanner) (shared.Task, error) {
+	var task shared.Task
+	var operatorStr, dependsOn, program string
+	err := row.Scan(&task.ID, &task.FirstArgument, &task.SecondArgument, &task.ThirdArgument, &operatorStr, &task.Function, &task.Status, &task.Result, &task.Imag, &task.Value, &task.Mode, &task.Precision, &task.Rounding, &task.Error, &task.Guard, &task.Branch, &task.ExpressionID, &dependsOn, &task.StartedAt, &program, &task.Variable, &task.Chunk, &task.Chunks)
+	if err != nil {
+		return task, err
+	}
+
+	task.Operator, _ = utf8.DecodeRuneInString(operatorStr)
+	if dependsOn != "" {
+		if err := json.Unmarshal([]byte(dependsOn), &task.DependsOn); err != nil {
+			return task, err
+		}
+	}
+	if program != "" {
+		if err := json.Unmarshal([]byte(program), &task.Program); err != nil {
+			return task, err
+		}
+	}
+
+	return task, nil
+}
+
+// scanExpression читает выражение из строки результата запроса по столбцам expressionColumns
+func scanExpression(row scanner) (shared.Expression, error) {
+	var expr shared.Expression
+	var variables, functions, results, distribution, units string
+	err := row.Scan(&expr.ID, &expr.UserID, &expr.Expression, &expr.Status, &expr.Result, &expr.Error, &variables, &functions, &expr.Mode, &expr.Precision, &expr.Rounding, &expr.Fraction, &results, &distribution, &expr.Unit, &units)
+	if err != nil {
+		return expr, err
+	}
+
+	if variables != "" {
+		if err := json.Unmarshal([]byte(variables), &expr.Variables); err != nil {
+			return expr, err
+		}
+	}
+	if functions != "" {
+		if err := json.Unmarshal([]byte(functions), &expr.Functions); err != nil {
+			return expr, err
+		}
+	}
+	if results != "" {
+		if err := json.Unmarshal([]byte(results), &expr.Results); err != nil {
+			return expr, err
+		}
+	}
+	if distribution != "" {
+		if err := json.Unmarshal([]byte(distribution), &expr.Distribution); err != nil {
+			return expr, err
+		}
+	}
+	if units != "" {
+		if err := json.Unmarshal([]byte(units), &expr.Units); err != nil {
+			return expr, err
+		}
+	}
+	return expr, nil
+}
+
+// Scope - переменные и функции пользователя, доступные в выражении
+type Scope struct {
+	Variables map[string]float64
+	Functions map[string]*function.Function
+}
+
+// Names возвращает имена переменных пользователя. Парсер читает такое имя
+// после числа как множитель, а не как единицу: при сохранённой h 2h - это 2*h, а не 2 часа
+func (s Scope) Names() []string {
+	return slices.Collect(maps.Keys(s.Variables))
+}
+
+// Options - режим арифметики выражения, точность, способ округления и число выборок
+type Options struct {
+	Mode      shared.Mode
+	Precision int
+	Rounding  shared.Rounding
+	Locale    parser.Locale // запись чисел в выражении
+	Samples   int           // число выборок для величин с погрешностью, 0 - shared.DefaultSamples
+}
+
 type Queue struct {
 	db *sql.DB
 }
 
+// executor - общее у *sql.DB и *sql.Tx. Задачи и выражение одного запроса
+// записываются в транзакции, чтобы ID не достались двум выражениям сразу
+type executor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 // NewQueue создает новую очередь с SQLite
 func NewQueue(dbPath string) (*Queue, error) {
 	db, err := sql.Open("sqlite3", dbPath)
@@ -62,9 +203,26 @@ func (q *Queue) initTables() error {
 			id INTEGER PRIMARY KEY,
 			first_argument TEXT NOT NULL,
 			second_argument TEXT NOT NULL,
+			third_argument TEXT NOT NULL DEFAULT '',
 			operator TEXT NOT NULL,
+			function TEXT NOT NULL DEFAULT '',
 			status BOOLEAN NOT NULL DEFAULT 0,
-			result REAL
+			result REAL,
+			imag REAL NOT NULL DEFAULT 0,
+			value TEXT NOT NULL DEFAULT '',
+			mode TEXT NOT NULL DEFAULT 'float',
+			precision INTEGER NOT NULL DEFAULT 0,
+			rounding TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			guard INTEGER NOT NULL DEFAULT 0,
+			branch TEXT NOT NULL DEFAULT '',
+			expression_id INTEGER NOT NULL DEFAULT 0,
+			depends_on TEXT NOT NULL DEFAULT '',
+			started_at INTEGER NOT NULL DEFAULT 0,
+			program TEXT NOT NULL DEFAULT '',
+			variable TEXT NOT NULL DEFAULT '',
+			chunk INTEGER NOT NULL DEFAULT 0,
+			chunks INTEGER NOT NULL DEFAULT 0
 		)
 	`)
 	if err != nil {
@@ -76,11 +234,101 @@ func (q *Queue) initTables() error {
 		CREATE TABLE IF NOT EXISTS expressions (
 			id INTEGER PRIMARY KEY,
 			user_id INTEGER NOT NULL,
+			expression TEXT NOT NULL DEFAULT '',
 			status BOOLEAN NOT NULL DEFAULT 0,
 			result TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			variables TEXT NOT NULL DEFAULT '',
+			functions TEXT NOT NULL DEFAULT '',
+			mode TEXT NOT NULL DEFAULT 'float',
+			precision INTEGER NOT NULL DEFAULT 0,
+			rounding TEXT NOT NULL DEFAULT '',
+			fraction TEXT NOT NULL DEFAULT '',
+			results TEXT NOT NULL DEFAULT '',
+			distribution TEXT NOT NULL DEFAULT '',
+			unit TEXT NOT NULL DEFAULT '',
+			units TEXT NOT NULL DEFAULT '',
 			FOREIGN KEY(user_id) REFERENCES users(id)
 		)
 	`)
+	if err != nil {
+		return err
+	}
+
+	// Создаем таблицу для графиков. points - значения в точках графика
+	// или ссылки на задачи, которые их вычисляют
+	_, err = q.db.Exec(`
+		CREATE TABLE IF NOT EXISTS plots (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			expression TEXT NOT NULL DEFAULT '',
+			variable TEXT NOT NULL,
+			range_from REAL NOT NULL,
+			range_to REAL NOT NULL,
+			status BOOLEAN NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			unit TEXT NOT NULL DEFAULT '',
+			points TEXT NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Базы, созданные прошлыми версиями, дополняем недостающими столбцами
+	migrations := []struct{ table, column, definition string }{
+		{"tasks", "function", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "error", "TEXT NOT NULL DEFAULT ''"},
+		{"expressions", "error", "TEXT NOT NULL DEFAULT ''"},
+		{"expressions", "variables", "TEXT NOT NULL DEFAULT ''"},
+		{"expressions", "functions", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "value", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "mode", "TEXT NOT NULL DEFAULT 'float'"},
+		{"expressions", "mode", "TEXT NOT NULL DEFAULT 'float'"},
+		{"expressions", "precision", "INTEGER NOT NULL DEFAULT 0"},
+		{"expressions", "fraction", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "precision", "INTEGER NOT NULL DEFAULT 0"},
+		{"tasks", "rounding", "TEXT NOT NULL DEFAULT ''"},
+		{"expressions", "rounding", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "imag", "REAL NOT NULL DEFAULT 0"},
+		{"tasks", "third_argument", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "guard", "INTEGER NOT NULL DEFAULT 0"},
+		{"tasks", "branch", "TEXT NOT NULL DEFAULT ''"},
+		{"expressions", "results", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "expression_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"tasks", "depends_on", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "started_at", "INTEGER NOT NULL DEFAULT 0"},
+		{"expressions", "expression", "TEXT NOT NULL DEFAULT ''"},
+		{"expressions", "distribution", "TEXT NOT NULL DEFAULT ''"},
+		{"expressions", "unit", "TEXT NOT NULL DEFAULT ''"},
+		{"expressions", "units", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "program", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "variable", "TEXT NOT NULL DEFAULT ''"},
+		{"tasks", "chunk", "INTEGER NOT NULL DEFAULT 0"},
+		{"tasks", "chunks", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, m := range migrations {
+		if err := q.addColumn(m.table, m.column, m.definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addColumn добавляет столбец в таблицу, если его там ещё нет
+func (q *Queue) addColumn(table, column, definition string) error {
+	var count int
+	err := q.db.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?",
+		table, column,
+	).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	_, err = q.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
 	return err
 }
 
@@ -99,11 +347,7 @@ func (q *Queue) AddTask(task shared.Task) int64 {
 	task.ID = newID
 
 	// Добавляем задачу в базу данных
-	_, err = q.db.Exec(
-		"INSERT INTO tasks (id, first_argument, second_argument, operator, status, result) VALUES (?, ?, ?, ?, ?, ?)",
-		task.ID, task.FirstArgument, task.SecondArgument, string(task.Operator), task.Status, task.Result,
-	)
-	if err != nil {
+	if err := insertTask(q.db, task); err != nil {
 		log.Printf("Ошибка при добавлении задачи: %v", err)
 		return 0
 	}
@@ -111,6 +355,62 @@ func (q *Queue) AddTask(task shared.Task) int64 {
 	return newID
 }
 
+// insertTask записывает новую задачу в таблицу tasks
+func insertTask(db executor, task shared.Task) error {
+	var dependsOn, program []byte
+	if len(task.DependsOn) > 0 {
+		var err error
+		if dependsOn, err = json.Marshal(task.DependsOn); err != nil {
+			return err
+		}
+	}
+	if len(task.Program) > 0 {
+		var err error
+		if program, err = json.Marshal(task.Program); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec(
+		`INSERT INTO tasks (id, first_argument, second_argument, third_argument, operator, function, status, result, mode, precision, rounding, guard, branch, expression_id, depends_on, program, variable, chunk, chunks)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.ID, task.FirstArgument, task.SecondArgument, task.ThirdArgument, string(task.Operator), task.Function, task.Status, task.Result,
+		task.Mode, task.Precision, task.Rounding, task.Guard, task.Branch, task.ExpressionID, string(dependsOn),
+		string(program), task.Variable, task.Chunk, task.Chunks,
+	)
+	return err
+}
+
+// Start отмечает, что задача выдана агенту. Повторная выдача время не меняет
+func (q *Queue) Start(id int64) {
+	_, err := q.db.Exec(
+		"UPDATE tasks SET started_at = ? WHERE id = ? AND started_at = 0",
+		time.Now().UnixMilli(), id,
+	)
+	if err != nil {
+		log.Printf("Ошибка при обновлении задачи %d: %v", id, err)
+	}
+}
+
+// ExpressionTasks возвращает задачи выражения в порядке их ID
+func (q *Queue) ExpressionTasks(expressionID int64) ([]shared.Task, error) {
+	rows, err := q.db.Query("SELECT "+taskColumns+" FROM tasks WHERE expression_id = ? ORDER BY id", expressionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []shared.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
 func (q *Queue) AddExpression(expression shared.Expression, userID int64) int64 {
 	var maxID int64
 	err := q.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM expressions").Scan(&maxID)
@@ -136,31 +436,70 @@ func (q *Queue) AddExpression(expression shared.Expression, userID int64) int64
 	return newID
 }
 
-// Done помечает задачу как выполненную
-func (q *Queue) Done(id int64, result float64) {
-	// Получаем задачу из базы данных
-	var task shared.Task
-	var operatorStr string
-	err := q.db.QueryRow(
-		"SELECT id, first_argument, second_argument, operator, status FROM tasks WHERE id = ?",
-		id,
-	).Scan(&task.ID, &task.FirstArgument, &task.SecondArgument, &operatorStr, &task.Status)
+// Done помечает задачу как выполненную. result.Value - результат без потери точности,
+// пустая строка означает, что агент передал только числовой результат
+func (q *Queue) Done(result shared.TaskResult) {
+	id := result.ID
 
+	// Получаем задачу из базы данных
+	task, err := scanTask(q.db.QueryRow("SELECT "+taskColumns+" FROM tasks WHERE id = ?", id))
 	if err != nil {
 		log.Printf("Ошибка при получении задачи %d: %v", id, err)
 		return
 	}
 
-	if len(operatorStr) > 0 {
-		task.Operator = rune(operatorStr[0])
+	if err := shared.CheckFinite(result.Imag); err != nil {
+		q.Fail(id, err.Error())
+		return
+	}
+	if err := shared.CheckFinite(result.Result); err != nil {
+		// Бесконечность или NaN в базу не записываются: такая задача проваливается
+		if task.Mode == shared.ModeFloat {
+			q.Fail(id, err.Error())
+			return
+		}
+		// В точном и десятичном режимах result - лишь приближение, которое может
+		// не поместиться в float64. Само значение хранится в value
+		result.Result = 0
+	}
+	if result.Value == "" {
+		result.Value = shared.FormatComplex(complex(result.Result, result.Imag))
 	}
 
 	// Обновляем статус и результат задачи
 	task.Status = true
-	task.Result = result
+	task.Result = result.Result
+	task.Imag = result.Imag
+	task.Value = result.Value
 	_, err = q.db.Exec(
-		"UPDATE tasks SET status = ?, result = ? WHERE id = ?",
-		task.Status, task.Result, task.ID,
+		"UPDATE tasks SET status = ?, result = ?, imag = ?, value = ? WHERE id = ?",
+		task.Status, task.Result, task.Imag, task.Value, task.ID,
+	)
+	if err != nil {
+		log.Printf("Ошибка при обновлении задачи %d: %v", id, err)
+		return
+	}
+
+	if err := q.UpdateTasks(); err != nil {
+		log.Printf("Ошибка при обновлении задач после задачи %d: %v", id, err)
+		return
+	}
+	if err := q.UpdateExpressions(); err != nil {
+		log.Printf("Ошибка при обновлении выражений после задачи %d: %v", id, err)
+		return
+	}
+	if err := q.UpdatePlots(); err != nil {
+		log.Printf("Ошибка при обновлении графиков после задачи %d: %v", id, err)
+		return
+	}
+}
+
+// Fail помечает задачу как невыполнимой. Вместе с ней не будут вычислены
+// все зависящие от неё задачи и выражения
+func (q *Queue) Fail(id int64, reason string) {
+	_, err := q.db.Exec(
+		"UPDATE tasks SET status = ?, error = ? WHERE id = ?",
+		true, reason, id,
 	)
 	if err != nil {
 		log.Printf("Ошибка при обновлении задачи %d: %v", id, err)
@@ -175,13 +514,17 @@ func (q *Queue) Done(id int64, result float64) {
 		log.Printf("Ошибка при обновлении выражений после задачи %d: %v", id, err)
 		return
 	}
+	if err := q.UpdatePlots(); err != nil {
+		log.Printf("Ошибка при обновлении графиков после задачи %d: %v", id, err)
+		return
+	}
 }
 
 // GetTasks получает абсолютно все задачи из очереди
 func (q *Queue) GetTasks() map[int64]shared.Task {
 	tasks := make(map[int64]shared.Task)
 
-	rows, err := q.db.Query("SELECT id, first_argument, second_argument, operator, status, result FROM tasks")
+	rows, err := q.db.Query("SELECT " + taskColumns + " FROM tasks")
 	if err != nil {
 		log.Printf("Ошибка при получении задач: %v", err)
 		return tasks
@@ -189,18 +532,12 @@ func (q *Queue) GetTasks() map[int64]shared.Task {
 	defer rows.Close()
 
 	for rows.Next() {
-		var task shared.Task
-		var operatorStr string
-		err := rows.Scan(&task.ID, &task.FirstArgument, &task.SecondArgument, &operatorStr, &task.Status, &task.Result)
+		task, err := scanTask(rows)
 		if err != nil {
 			log.Printf("Ошибка при сканировании задачи: %v", err)
 			continue
 		}
 
-		if len(operatorStr) > 0 {
-			task.Operator = rune(operatorStr[0])
-		}
-
 		tasks[task.ID] = task
 	}
 
@@ -215,7 +552,7 @@ func (q *Queue) GetExpressions() map[int64]shared.Expression {
 
 	expressions := make(map[int64]shared.Expression)
 
-	rows, err := q.db.Query("SELECT id, user_id, status, result FROM expressions")
+	rows, err := q.db.Query("SELECT " + expressionColumns + " FROM expressions")
 	if err != nil {
 		log.Printf("Ошибка при получении выражений: %v", err)
 		return expressions
@@ -223,8 +560,7 @@ func (q *Queue) GetExpressions() map[int64]shared.Expression {
 	defer rows.Close()
 
 	for rows.Next() {
-		var expr shared.Expression
-		err := rows.Scan(&expr.ID, &expr.UserID, &expr.Status, &expr.Result)
+		expr, err := scanExpression(rows)
 		if err != nil {
 			log.Printf("Ошибка при сканировании выражения: %v", err)
 			continue
@@ -236,76 +572,144 @@ func (q *Queue) GetExpressions() map[int64]shared.Expression {
 	return expressions
 }
 
+// UpdateExpressions записывает результаты выражений, задачи которых выполнены.
+// Переменные сценария заполняются по мере выполнения их задач; выражение
+// выполнено, когда известны его результат и все переменные
 func (q *Queue) UpdateExpressions() error {
-	rows, err := q.db.Query("SELECT id, user_id, status, result FROM expressions")
+	rows, err := q.db.Query("SELECT " + expressionColumns + " FROM expressions WHERE status = 0")
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 
+	// Выражения обновляются после чтения: обновление таблицы во время обхода строк блокирует базу
+	var pending []shared.Expression
 	for rows.Next() {
-		var expr shared.Expression
-		err := rows.Scan(&expr.ID, &expr.UserID, &expr.Status, &expr.Result)
+		expr, err := scanExpression(rows)
 		if err != nil {
 			log.Printf("Ошибка при сканировании выражения: %v", err)
 			continue
 		}
+		pending = append(pending, expr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при итерации по строкам: %v", err)
+	}
 
-		if !IsNumeric(expr.Result) {
-			id, err := strconv.ParseInt(strings.TrimPrefix(expr.Result, "id"), 10, 64)
-			if err != nil {
-				log.Printf("Ошибка в парсинге %s", strings.TrimPrefix(expr.Result, "id"))
-				continue
-			}
+	for _, expr := range pending {
+		q.updateExpression(expr)
+	}
 
-			var relatedTask shared.Task
-			var operatorStr string
-			err = q.db.QueryRow(
-				"SELECT id, first_argument, second_argument, operator, status, result FROM tasks WHERE id = ?",
-				id,
-			).Scan(&relatedTask.ID, &relatedTask.FirstArgument, &relatedTask.SecondArgument, &operatorStr, &relatedTask.Status, &relatedTask.Result)
+	return nil
+}
 
-			if err != nil {
-				log.Printf("Задача ID: %d не найдена: %v\n", id, err)
-				continue
-			}
+// updateExpression подставляет в выражение результаты выполненных задач
+func (q *Queue) updateExpression(expr shared.Expression) {
+	changed, waiting := false, false
 
-			if len(operatorStr) > 0 {
-				relatedTask.Operator = rune(operatorStr[0])
+	for name, value := range expr.Results {
+		related, ok := q.finishedTask(value)
+		if !ok {
+			waiting = waiting || IsReference(value)
+			continue
+		}
+		if related.Error != "" {
+			if expr.Error == "" {
+				expr.Error = related.Error
 			}
+			delete(expr.Results, name)
+			changed = true
+			continue
+		}
 
-			if !relatedTask.Status {
-				log.Printf("Задача ID: %d ещё не выполнена\n", id)
-				continue
-			}
+		value, err := displayValue(expr, resultValue(*related), expr.Units[name])
+		if err != nil {
+			log.Printf("Ошибка в записи переменной %s выражения %d: %v", name, expr.ID, err)
+			return
+		}
+		expr.Results[name] = value
+		changed = true
+	}
 
+	if related, ok := q.finishedTask(expr.Result); ok {
+		if related.Error != "" {
+			if expr.Error == "" {
+				expr.Error = related.Error
+			}
+		} else {
 			log.Printf("Результат с id %d", expr.ID)
-			expr.Result = strconv.FormatFloat(relatedTask.Result, 'f', -1, 64)
-			log.Printf(" преобразован в значение (%s)\n", expr.Result)
-			expr.Status = true
-			log.Printf("Выражение %d выполнено!\n", expr.ID)
-
-			_, err = q.db.Exec(
-				"UPDATE expressions SET status = ?, result = ? WHERE id = ?",
-				expr.Status, expr.Result, expr.ID,
-			)
-			if err != nil {
-				log.Printf("Ошибка при обновлении выражения %d: %v", expr.ID, err)
-				continue
+			if err := setResult(&expr, resultValue(*related)); err != nil {
+				log.Printf("Ошибка в записи результата выражения %d: %v", expr.ID, err)
+				return
 			}
+			log.Printf(" преобразован в значение (%s)\n", expr.Result)
 		}
+		changed = true
+	} else {
+		waiting = waiting || IsReference(expr.Result)
 	}
 
-	return nil
+	// Выражение с проваленной задачей завершается, не дожидаясь остальных задач
+	if expr.Error != "" {
+		expr.Result = ""
+		expr.Status = true
+		log.Printf("Выражение %d не удалось вычислить: %s\n", expr.ID, expr.Error)
+	} else if !waiting {
+		expr.Status = true
+		log.Printf("Выражение %d выполнено!\n", expr.ID)
+	}
+	if !changed && !expr.Status {
+		return
+	}
+
+	results, err := marshalSnapshot(expr.Results)
+	if err != nil {
+		log.Printf("Ошибка в записи переменных выражения %d: %v", expr.ID, err)
+		return
+	}
+	distribution, err := marshalDistribution(expr.Distribution)
+	if err != nil {
+		log.Printf("Ошибка в записи выборки выражения %d: %v", expr.ID, err)
+		return
+	}
+	_, err = q.db.Exec(
+		"UPDATE expressions SET status = ?, result = ?, fraction = ?, error = ?, results = ?, distribution = ? WHERE id = ?",
+		expr.Status, expr.Result, expr.Fraction, expr.Error, results, distribution, expr.ID,
+	)
+	if err != nil {
+		log.Printf("Ошибка при обновлении выражения %d: %v", expr.ID, err)
+	}
 }
 
-func (q *Queue) FindExpression(id int64) *shared.Expression {
-	var expr shared.Expression
-	err := q.db.QueryRow(
-		"SELECT id, user_id, status, result FROM expressions WHERE id = ?",
-		id,
-	).Scan(&expr.ID, &expr.UserID, &expr.Status, &expr.Result)
+// finishedTask возвращает выполненную или проваленную задачу, на которую ссылается operand.
+// ok - false, если operand не ссылка или задача ещё не выполнена
+func (q *Queue) finishedTask(operand string) (*shared.Task, bool) {
+	if !IsReference(operand) {
+		return nil, false
+	}
 
+	id, err := strconv.ParseInt(strings.TrimPrefix(operand, "id"), 10, 64)
+	if err != nil {
+		log.Printf("Ошибка в парсинге %s", operand)
+		return nil, false
+	}
+
+	relatedTask := q.FindTask(id)
+	if relatedTask == nil {
+		log.Printf("Задача ID: %d не найдена\n", id)
+		return nil, false
+	}
+
+	if !relatedTask.Status {
+		log.Printf("Задача ID: %d ещё не выполнена\n", id)
+		return nil, false
+	}
+
+	return relatedTask, true
+}
+
+func (q *Queue) FindExpression(id int64) *shared.Expression {
+	expr, err := scanExpression(q.db.QueryRow("SELECT "+expressionColumns+" FROM expressions WHERE id = ?", id))
 	if err != nil {
 		if err != sql.ErrNoRows {
 			log.Printf("Ошибка при поиске выражения %d: %v", id, err)
@@ -317,13 +721,7 @@ func (q *Queue) FindExpression(id int64) *shared.Expression {
 }
 
 func (q *Queue) FindTask(id int64) *shared.Task {
-	var task shared.Task
-	var operatorStr string
-	err := q.db.QueryRow(
-		"SELECT id, first_argument, second_argument, operator, status, result FROM tasks WHERE id = ?",
-		id,
-	).Scan(&task.ID, &task.FirstArgument, &task.SecondArgument, &operatorStr, &task.Status, &task.Result)
-
+	task, err := scanTask(q.db.QueryRow("SELECT "+taskColumns+" FROM tasks WHERE id = ?", id))
 	if err != nil {
 		if err != sql.ErrNoRows {
 			log.Printf("Ошибка при поиске задачи %d: %v", id, err)
@@ -331,40 +729,63 @@ func (q *Queue) FindTask(id int64) *shared.Task {
 		return nil
 	}
 
-	if len(operatorStr) > 0 {
-		task.Operator = rune(operatorStr[0])
-	}
-
 	return &task
 }
 
+// UpdateTasks подставляет результаты выполненных задач в зависящие от них задачи
+// и разрешает условия. Повторяется, пока что-то меняется: провал задачи или
+// выбор ветки может сделать готовыми следующие задачи
 func (q *Queue) UpdateTasks() error {
-	rows, err := q.db.Query("SELECT id, first_argument, second_argument, operator, status, result FROM tasks")
+	for {
+		failed, err := q.propagateResults()
+		if err != nil {
+			return err
+		}
+		resolved, err := q.resolveConditions()
+		if err != nil {
+			return err
+		}
+		if !failed && !resolved {
+			return nil
+		}
+	}
+}
+
+// propagateResults подставляет результаты выполненных задач в аргументы невыполненных.
+// Возвращает true, если какая-то задача провалилась вслед за своей зависимостью
+func (q *Queue) propagateResults() (bool, error) {
+	rows, err := q.db.Query("SELECT " + taskColumns + " FROM tasks WHERE status = 0")
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer rows.Close()
 
+	failed := false
+
 	for rows.Next() {
-		var task shared.Task
-		var operatorStr string
-		err := rows.Scan(&task.ID, &task.FirstArgument, &task.SecondArgument, &operatorStr, &task.Status, &task.Result)
+		task, err := scanTask(rows)
 		if err != nil {
 			log.Printf("Ошибка при сканировании задачи: %v", err)
 			continue
 		}
 
-		if len(operatorStr) > 0 {
-			task.Operator = rune(operatorStr[0])
-		}
-
 		updated := false
+		failure := ""
+
+		// Тело участка интеграла или ряда тоже может ссылаться на задачи: на переменные сценария
+		args := []*string{&task.FirstArgument, &task.SecondArgument}
+		for i := range task.Program {
+			args = append(args, &task.Program[i])
+		}
+		for i, arg := range args {
+			if !IsReference(*arg) {
+				continue
+			}
 
-		if !IsNumeric(task.FirstArgument) {
-			log.Printf("Аргумент 1 не является числом: %s\n", task.FirstArgument)
-			id, err := strconv.ParseInt(strings.TrimPrefix(task.FirstArgument, "id"), 10, 64)
+			log.Printf("Аргумент %d ссылается на задачу: %s\n", i+1, *arg)
+			id, err := strconv.ParseInt(strings.TrimPrefix(*arg, "id"), 10, 64)
 			if err != nil {
-				log.Printf("Ошибка в парсинге %s", task.FirstArgument)
+				log.Printf("Ошибка в парсинге %s", *arg)
 				continue
 			}
 
@@ -379,41 +800,48 @@ func (q *Queue) UpdateTasks() error {
 				continue
 			}
 
-			log.Printf("Аргумент 1 (%s)", task.FirstArgument)
-			task.FirstArgument = strconv.FormatFloat(relatedTask.Result, 'f', -1, 64)
-			log.Printf(" преобразован в значение (%s)\n", task.FirstArgument)
+			// Задача, от которой зависит текущая, провалилась - текущую тоже не вычислить
+			if relatedTask.Error != "" {
+				failure = relatedTask.Error
+				break
+			}
+
+			log.Printf("Аргумент %d (%s)", i+1, *arg)
+			*arg = resultValue(*relatedTask)
+			log.Printf(" преобразован в значение (%s)\n", *arg)
 			updated = true
 		}
 
-		if !IsNumeric(task.SecondArgument) {
-			log.Printf("Аргумент 2 не является числом: %s\n", task.SecondArgument)
-			id, err := strconv.ParseInt(strings.TrimPrefix(task.SecondArgument, "id"), 10, 64)
+		if failure != "" {
+			_, err = q.db.Exec(
+				"UPDATE tasks SET status = ?, error = ? WHERE id = ?",
+				true, failure, task.ID,
+			)
 			if err != nil {
-				log.Printf("Ошибка в парсинге %s", task.SecondArgument)
-				continue
-			}
-
-			relatedTask := q.FindTask(id)
-			if relatedTask == nil {
-				log.Printf("Задача ID %d равна nil\n", id)
+				log.Printf("Ошибка при обновлении задачи %d: %v", task.ID, err)
 				continue
 			}
+			failed = true
 
-			if !relatedTask.Status {
-				log.Printf("Задача ID %d ещё не выполнена\n", id)
-				continue
+			// Условие не вычислено - ни одна из веток не понадобится
+			if Operation(task.Operator) == Condition {
+				q.skipBranch(task.ID, shared.BranchThen)
+				q.skipBranch(task.ID, shared.BranchElse)
 			}
-
-			log.Printf("Аргумент 2 (%s)", task.SecondArgument)
-			task.SecondArgument = strconv.FormatFloat(relatedTask.Result, 'f', -1, 64)
-			log.Printf(" преобразован в значение (%s)\n", task.SecondArgument)
-			updated = true
+			continue
 		}
 
 		if updated {
+			var program []byte
+			if len(task.Program) > 0 {
+				if program, err = json.Marshal(task.Program); err != nil {
+					log.Printf("Ошибка при обновлении задачи %d: %v", task.ID, err)
+					continue
+				}
+			}
 			_, err = q.db.Exec(
-				"UPDATE tasks SET first_argument = ?, second_argument = ? WHERE id = ?",
-				task.FirstArgument, task.SecondArgument, task.ID,
+				"UPDATE tasks SET first_argument = ?, second_argument = ?, program = ? WHERE id = ?",
+				task.FirstArgument, task.SecondArgument, string(program), task.ID,
 			)
 			if err != nil {
 				log.Printf("Ошибка при обновлении задачи %d: %v", task.ID, err)
@@ -423,114 +851,246 @@ func (q *Queue) UpdateTasks() error {
 	}
 
 	if err = rows.Err(); err != nil {
-		return fmt.Errorf("ошибка при итерации по строкам: %v", err)
+		return false, fmt.Errorf("ошибка при итерации по строкам: %v", err)
 	}
 
-	return nil
+	return failed, nil
 }
 
-func (q *Queue) generateTasksFromRPN(output []string) ([]shared.Task, map[int]string, error) {
-	var tasks []shared.Task
-	var operandStack []string
-	taskIDs := make(map[int]string)
+// nextID возвращает ID, который получит следующая запись таблицы table
+func nextID(db executor, table string) (int64, error) {
+	var id int64
+	err := db.QueryRow(fmt.Sprintf("SELECT COALESCE(MAX(id), 0) + 1 FROM %s", table)).Scan(&id)
+	return id, err
+}
 
-	// Получаем максимальный ID существующих задач
-	var nextID int64
-	err := q.db.QueryRow("SELECT COALESCE(MAX(id), 0) + 1 FROM tasks").Scan(&nextID)
-	if err != nil {
-		log.Printf("Ошибка при получении следующего ID задачи: %v", err)
-		nextID = 1
-	}
+// generateTasksFromRPN раскладывает выражение в обратной польской записи на задачи плана.
+// Возвращает операнд с результатом выражения: число или ссылку на последнюю задачу
+func generateTasksFromRPN(plan *planner, output []string) (string, error) {
+	var operandStack []string
 
 	for _, token := range output {
-		if isOperator(token) {
+		if token == branchThen || token == branchElse || token == branchEnd {
+			// Ветка должна оставить на стеке ровно один операнд - свой результат
+			var operand string
+			if token != branchThen && !plan.balanced(len(operandStack)) {
+				return "", errors.ErrInvalidExpression
+			}
+			if len(operandStack) == 0 {
+				return "", errors.ErrNotEnoughOperands
+			}
+			operand, operandStack = operandStack[len(operandStack)-1], operandStack[:len(operandStack)-1]
+
+			switch token {
+			case branchThen:
+				plan.openBranch(operand, len(operandStack))
+			case branchElse:
+				plan.elseBranch(operand)
+			case branchEnd:
+				operandStack = append(operandStack, plan.closeBranch(operand))
+			}
+		} else if op, variable, program, ok := parseIterationToken(token); ok {
+			// Отрезок интеграла или ряда - два операнда перед его телом
 			if len(operandStack) < 2 {
-				return nil, nil, errors.ErrNotEnoughOperands
+				return "", errors.ErrNotEnoughOperands
+			}
+			from, to := operandStack[len(operandStack)-2], operandStack[len(operandStack)-1]
+			operandStack = operandStack[:len(operandStack)-2]
+			operandStack = append(operandStack, plan.iterate(op, variable, program, from, to))
+		} else if name, arity, ok := parseFunctionToken(token); ok {
+			if len(operandStack) < arity || arity == 0 {
+				return "", errors.ErrNotEnoughOperands
+			}
+			args := operandStack[len(operandStack)-arity:]
+			operandStack = operandStack[:len(operandStack)-arity]
+
+			var result string
+			if shared.AggregateFunctions[name] {
+				result = plan.aggregate(name, args)
+			} else {
+				result = plan.call(name, args...)
+			}
+			operandStack = append(operandStack, result)
+		} else if isOperator(token) {
+			if len(operandStack) < 2 {
+				return "", errors.ErrNotEnoughOperands
 			}
 			arg2 := operandStack[len(operandStack)-1]
 			arg1 := operandStack[len(operandStack)-2]
 			operandStack = operandStack[:len(operandStack)-2]
 
-			if token == "/" && arg2 == "0" {
-				return nil, nil, errors.ErrDivisionByZero
+			op, _ := utf8.DecodeRuneInString(token)
+			// Деление на ноль внутри ветки условия - не ошибка, пока ветка не выбрана:
+			// if(0, 1/0, 5), 0 and 1/0
+			if isDivision(Operation(op)) && strings.TrimPrefix(arg2, "-") == "0" && len(plan.branches) == 0 {
+				return "", errors.ErrDivisionByZero
 			}
 
-			task := shared.Task{
-				ID:             nextID,
-				FirstArgument:  arg1,
-				SecondArgument: arg2,
-				Operator:       rune(token[0]),
-				Status:         false,
-			}
-			tasks = append(tasks, task)
-			taskIDs[int(nextID)] = fmt.Sprintf("id%d", nextID)
-			operandStack = append(operandStack, fmt.Sprintf("id%d", nextID))
-			nextID++
+			operandStack = append(operandStack, plan.binary(Operation(op), arg1, arg2))
 		} else {
 			operandStack = append(operandStack, token)
 		}
 	}
 
-	return tasks, taskIDs, nil
+	if len(operandStack) != 1 || len(plan.branches) != 0 {
+		return "", errors.ErrInvalidExpression
+	}
+
+	return operandStack[0], nil
 }
 
-func (q *Queue) ParseExpression(ctx context.Context, expression string) (int64, error) {
-	tokens := tokenize(expression)
-	output, err := convertToRPN(tokens)
+// ParseExpression разбирает выражение или сценарий и ставит задачи в очередь.
+// scope - переменные и функции пользователя, доступные в выражении,
+// options - режим арифметики, в котором его вычисляют агенты
+func (q *Queue) ParseExpression(ctx context.Context, expression string, scope Scope, options Options) (int64, error) {
+	// ID задач и выражения выбираются и занимаются в одной транзакции
+	tx, err := q.db.Begin()
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback() // после Commit ничего не делает
 
-	tasks, _, err := q.generateTasksFromRPN(output)
+	firstID, err := nextID(tx, "tasks")
+	if err != nil {
+		log.Printf("Ошибка при получении следующего ID задачи: %v", err)
+		return 0, err
+	}
+	plan, err := PlanExpression(expression, scope, options, firstID)
 	if err != nil {
 		return 0, err
 	}
+	tasks, result, locals := plan.Tasks, plan.Result, plan.Locals
+
+	expr := shared.Expression{Mode: options.Mode, Precision: options.Precision, Rounding: options.Rounding, Result: result}
+	expr.Unit = plan.Units[len(plan.Units)-1]
+	for i, statement := range plan.Statements {
+		if statement.Name != "" && plan.Units[i] != "" {
+			if expr.Units == nil {
+				expr.Units = make(map[string]string)
+			}
+			expr.Units[statement.Name] = plan.Units[i]
+		}
+	}
 
-	if len(tasks) == 0 {
-		return 0, errors.ErrInvalidExpression
+	// Выражение без операций (например, "-3" или "max(5)") сразу считается выполненным
+	expr.Status = len(tasks) == 0
+	if !IsReference(result) {
+		if err := setResult(&expr, result); err != nil {
+			return 0, err
+		}
 	}
 
-	// Добавляем задачи в базу данных
-	for _, task := range tasks {
-		_, err := q.db.Exec(
-			"INSERT INTO tasks (id, first_argument, second_argument, operator, status, result) VALUES (?, ?, ?, ?, ?, ?)",
-			task.ID, task.FirstArgument, task.SecondArgument, string(task.Operator), task.Status, task.Result,
-		)
-		if err != nil {
-			log.Printf("Ошибка при добавлении задачи %d: %v", task.ID, err)
+	// Значения переменных сценария, которые уже известны, записываются сразу,
+	// остальные - ссылками на задачи, пока задачи не будут выполнены
+	for name, operand := range locals {
+		if IsReference(operand) {
+			continue
+		}
+		if locals[name], err = displayValue(expr, operand, expr.Units[name]); err != nil {
 			return 0, err
 		}
 	}
+	results, err := marshalSnapshot(locals)
+	if err != nil {
+		return 0, err
+	}
 
 	// Получаем максимальный ID существующих выражений
-	var nextExprID int64
-	err = q.db.QueryRow("SELECT COALESCE(MAX(id), 0) + 1 FROM expressions").Scan(&nextExprID)
+	nextExprID, err := nextID(tx, "expressions")
 	if err != nil {
 		log.Printf("Ошибка при получении следующего ID выражения: %v", err)
-		nextExprID = 1
+		return 0, err
 	}
 
-	// userID := ctx.Value(middleware.UserID).(int64)
+	// Добавляем задачи в базу данных. Зависимости запоминаются отдельно от аргументов:
+	// ссылки в аргументах заменяются результатами, как только те становятся известны
+	deps := Dependencies(tasks)
+	for _, task := range tasks {
+		task.ExpressionID, task.DependsOn = nextExprID, deps[task.ID]
+		if err := insertTask(tx, task); err != nil {
+			log.Printf("Ошибка при добавлении задачи %d: %v", task.ID, err)
+			return 0, err
+		}
+	}
 
-	// // Добавляем выражение в базу данных
-	// expr := shared.Expression{
-	// 	ID:     nextExprID,
-	// 	UserID: userID,
-	// 	Status: false,
-	// 	Result: fmt.Sprintf("id%d", tasks[len(tasks)-1].ID),
-	// }
+	distribution, err := marshalDistribution(expr.Distribution)
+	if err != nil {
+		return 0, err
+	}
 
-	_, err = q.db.Exec(
-		"INSERT INTO expressions (id, user_id, status, result) VALUES (?, ?, ?, ?)",
+	units, err := marshalSnapshot(expr.Units)
+	if err != nil {
+		return 0, err
+	}
+
+	// Запоминаем значения переменных и версии функций, с которыми вычисляется выражение
+	variables, err := marshalSnapshot(plan.Variables)
+	if err != nil {
+		return 0, err
+	}
+	functions, err := marshalSnapshot(plan.Functions)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO expressions (id, user_id, expression, status, result, variables, functions, mode, precision, rounding, fraction, results, distribution, unit, units) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		nextExprID,                           // ID нашего выражения
 		ctx.Value(middleware.UserID).(int64), // кому принадлежит выражение
-		false,                                // статус - ещё не выполнено
-		fmt.Sprintf("id%d", tasks[len(tasks)-1].ID), // Результат - ID последней таски
+		parser.FormatScript(plan.Statements), // выражение в каноническом виде
+		expr.Status,                          // статус - выполнено ли выражение
+		expr.Result,                          // результат или ссылка на итоговую задачу
+		variables,                            // использованные значения переменных
+		functions,                            // использованные версии функций
+		expr.Mode,                            // режим арифметики
+		expr.Precision,                       // точность результата
+		expr.Rounding,                        // способ округления в десятичном режиме
+		expr.Fraction,                        // результат точного режима дробью
+		results,                              // переменные сценария
+		distribution,                         // сводка выборки выражения с погрешностями
+		expr.Unit,                            // единица результата
+		units,                                // единицы переменных сценария
 	)
 	if err != nil {
 		log.Printf("Ошибка при добавлении выражения %d: %v", nextExprID, err)
 		return 0, err
 	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка при сохранении выражения %d: %v", nextExprID, err)
+		return 0, err
+	}
+
+	// Условия, которые уже можно проверить (if(1, a, b)), и объединения готовых пачек выборок
+	// выполняются сразу: агенты их не получают
+	for _, task := range tasks {
+		if isControl(Operation(task.Operator)) {
+			if err := q.UpdateTasks(); err != nil {
+				log.Printf("Ошибка при обновлении задач выражения %d: %v", nextExprID, err)
+			}
+			if err := q.UpdateExpressions(); err != nil {
+				log.Printf("Ошибка при обновлении выражений после выражения %d: %v", nextExprID, err)
+			}
+			break
+		}
+	}
 
 	return nextExprID, nil
 }
+
+// marshalDistribution сериализует сводку выборки. Выражение без погрешностей - пустая строка
+func marshalDistribution(d *shared.Distribution) (string, error) {
+	if d == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(d)
+	return string(data), err
+}
+
+// marshalSnapshot сериализует значения, использованные выражением. Пустой набор - пустая строка
+func marshalSnapshot[T any](values map[string]T) (string, error) {
+	if len(values) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(values)
+	return string(data), err
+}
@@ -0,0 +1,285 @@
+package task
+
+import (
+	"maps"
+	"math/big"
+	"slices"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/unit"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// resolveUnits проверяет размерности в дереве и убирает из него единицы: величина 3 km
+// становится числом 3000 - значением в единицах СИ, перевод x to km/h - самим x.
+// Агенты вычисляют только числа, а единицу результата оркестратор находит по дереву.
+// locals - единицы переменных сценария. Возвращает дерево без единиц и единицу
+// его значения; у безразмерного значения она пуста
+func resolveUnits(node parser.Node, locals map[string]unit.Expr) (parser.Node, unit.Expr, error) {
+	switch n := node.(type) {
+	case *parser.QuantityLit:
+		u := n.Unit.Unit()
+		value := literalValue(n.Value, false)
+		return ratLiteral(n.Position, value.Mul(value, u.Factor)), dimensional(unit.Mul(nil, n.Unit)), nil
+	case *parser.Variable:
+		return n, locals[n.Name], nil
+	case *parser.Conversion:
+		value, u, err := resolveUnits(n.Value, locals)
+		if err != nil {
+			return nil, nil, err
+		}
+		if u.Dimension() != n.Unit.Dimension() {
+			return nil, nil, &errors.SyntaxError{Pos: n.Position, Token: "to", Expected: "единица, совместимая с " + unitText(u), Err: errors.ErrIncompatibleUnits}
+		}
+		return value, dimensional(n.Unit), nil
+	case *parser.UnaryExpr:
+		operand, u, err := resolveUnits(n.Operand, locals)
+		if err != nil {
+			return nil, nil, err
+		}
+		result := &parser.UnaryExpr{Position: n.Position, Op: n.Op, Operand: operand}
+		switch n.Op {
+		case '%':
+			if len(u) > 0 {
+				return nil, nil, &errors.SyntaxError{Pos: n.Position, Token: "%", Expected: "безразмерная величина", Err: errors.ErrIncompatibleUnits}
+			}
+			return result, nil, nil
+		case '¬':
+			return result, nil, nil
+		}
+		return result, u, nil
+	case *parser.BinaryExpr:
+		return resolveBinaryUnits(n, locals)
+	case *parser.CallExpr:
+		return resolveCallUnits(n, locals)
+	case *parser.Conditional:
+		parts := make([]parser.Node, 3)
+		units := make([]unit.Expr, 3)
+		for i, part := range []parser.Node{n.Cond, n.Then, n.Else} {
+			resolved, u, err := resolveUnits(part, locals)
+			if err != nil {
+				return nil, nil, err
+			}
+			parts[i], units[i] = resolved, u
+		}
+		if units[1].Dimension() != units[2].Dimension() {
+			return nil, nil, &errors.SyntaxError{Pos: n.Else.Pos(), Token: parser.Format(n.Else), Expected: "ветка в единицах, совместимых с " + unitText(units[1]), Err: errors.ErrIncompatibleUnits}
+		}
+		return &parser.Conditional{Position: n.Position, Cond: parts[0], Then: parts[1], Else: parts[2]}, units[1], nil
+	case *parser.VectorLit:
+		// Элементы вектора - величины одной размерности, вектор записывается в единицах первого
+		elements := make([]parser.Node, len(n.Elements))
+		var first unit.Expr
+		for i, element := range n.Elements {
+			resolved, u, err := resolveUnits(element, locals)
+			if err != nil {
+				return nil, nil, err
+			}
+			if i == 0 {
+				first = u
+			} else if u.Dimension() != first.Dimension() {
+				return nil, nil, &errors.SyntaxError{Pos: element.Pos(), Token: parser.Format(element), Expected: quantityText(first), Err: errors.ErrIncompatibleUnits}
+			}
+			elements[i] = resolved
+		}
+		return &parser.VectorLit{Position: n.Position, Elements: elements}, first, nil
+	}
+	return node, nil, nil
+}
+
+// resolveBinaryUnits находит единицу бинарной операции. Складывать, вычитать и сравнивать
+// можно величины одной размерности, результат - в единицах левого операнда: 1 m + 20 cm = 1.2 m.
+// Степень величины с единицей - только целая и записанная числом: (3 m)^2 = 9 m^2
+func resolveBinaryUnits(n *parser.BinaryExpr, locals map[string]unit.Expr) (parser.Node, unit.Expr, error) {
+	left, lu, err := resolveUnits(n.Left, locals)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, ru, err := resolveUnits(n.Right, locals)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := &parser.BinaryExpr{Position: n.Position, Op: n.Op, Left: left, Right: right}
+	incompatible := func(expected string) error {
+		return &errors.SyntaxError{Pos: n.Position, Token: parser.OperatorText(n.Op), Expected: expected, Err: errors.ErrIncompatibleUnits}
+	}
+
+	switch Operation(n.Op) {
+	case Multiply, '@':
+		return result, dimensional(unit.Mul(lu, ru)), nil
+	case Divide, IntDivide:
+		return result, dimensional(unit.Div(lu, ru)), nil
+	case Power:
+		if len(ru) > 0 {
+			return nil, nil, incompatible("безразмерный показатель степени")
+		}
+		if len(lu) == 0 {
+			return result, nil, nil
+		}
+		exponent, ok := integerLiteral(n.Right)
+		if !ok {
+			return nil, nil, incompatible("целый показатель степени, записанный числом")
+		}
+		return result, dimensional(lu.Pow(exponent)), nil
+	case Add, Subtract, Modulo:
+		// Процент прибавляется к величине в её единицах: 200 m + 10% = 220 m
+		if percent, ok := n.Right.(*parser.UnaryExpr); ok && percent.Op == '%' && n.Op != '%' {
+			return result, lu, nil
+		}
+		if lu.Dimension() != ru.Dimension() {
+			return nil, nil, incompatible(quantityText(lu))
+		}
+		return result, lu, nil
+	case Less, Greater, LessEqual, GreaterEqual, Equal, NotEqual:
+		if lu.Dimension() != ru.Dimension() {
+			return nil, nil, incompatible(quantityText(lu))
+		}
+		return result, nil, nil
+	case '∧', '∨':
+		return result, nil, nil
+	}
+
+	// Побитовые операции определены только для безразмерных целых чисел
+	if len(lu) > 0 || len(ru) > 0 {
+		return nil, nil, incompatible("безразмерная величина")
+	}
+	return result, nil, nil
+}
+
+// resolveCallUnits находит единицу вызова встроенной функции. abs, transpose и агрегатные
+// функции сохраняют единицу первого аргумента, sqrt извлекает из неё корень, остальные
+// функции (и det) определены только для безразмерных величин
+func resolveCallUnits(n *parser.CallExpr, locals map[string]unit.Expr) (parser.Node, unit.Expr, error) {
+	if it, ok := parser.IterationOf(n); ok {
+		return resolveIterationUnits(n, it, locals)
+	}
+
+	args := make([]parser.Node, len(n.Args))
+	units := make([]unit.Expr, len(n.Args))
+	for i, arg := range n.Args {
+		resolved, u, err := resolveUnits(arg, locals)
+		if err != nil {
+			return nil, nil, err
+		}
+		args[i], units[i] = resolved, u
+	}
+	result := &parser.CallExpr{Position: n.Position, Name: n.Name, Args: args}
+	incompatible := func(expected string) error {
+		return &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: expected, Err: errors.ErrIncompatibleUnits}
+	}
+
+	switch {
+	case n.Name == "sqrt":
+		root, ok := units[0].Root(2)
+		if !ok {
+			return nil, nil, incompatible("единица в чётной степени: m^2, km^2/h^2")
+		}
+		return result, root, nil
+	case n.Name == "abs" || n.Name == "transpose" || shared.AggregateFunctions[n.Name]:
+		for _, u := range units[1:] {
+			if u.Dimension() != units[0].Dimension() {
+				return nil, nil, incompatible("аргументы одной размерности")
+			}
+		}
+		return result, units[0], nil
+	}
+
+	for _, u := range units {
+		if len(u) > 0 {
+			return nil, nil, incompatible("безразмерный аргумент")
+		}
+	}
+	return result, nil, nil
+}
+
+// resolveIterationUnits проверяет, что пределы и тело интеграла или ряда безразмерны.
+// Связанная переменная закрывает в теле одноимённую переменную сценария
+func resolveIterationUnits(n *parser.CallExpr, it parser.Iteration, locals map[string]unit.Expr) (parser.Node, unit.Expr, error) {
+	args := slices.Clone(n.Args)
+	for i, arg := range n.Args {
+		if i == it.Index {
+			continue
+		}
+		scope := locals
+		if i == it.Body {
+			scope = maps.Clone(locals)
+			delete(scope, it.Variable)
+		}
+		resolved, u, err := resolveUnits(arg, scope)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(u) > 0 {
+			return nil, nil, &errors.SyntaxError{Pos: arg.Pos(), Token: parser.Format(arg), Expected: "безразмерная величина", Err: errors.ErrIncompatibleUnits}
+		}
+		args[i] = resolved
+	}
+	return &parser.CallExpr{Position: n.Position, Name: n.Name, Args: args}, nil, nil
+}
+
+// unitConversion возвращает дерево, которое переводит значение value из единиц СИ
+// в единицу u: делит на множитель единицы. Если единица - единица СИ, перевод не нужен и дерево пусто
+func unitConversion(value string, u unit.Expr) parser.Node {
+	factor := u.Unit().Factor
+	if factor.Cmp(big.NewRat(1, 1)) == 0 {
+		return nil
+	}
+
+	// Деление и умножение на целое выполняются точнее, чем умножение на дробь:
+	// x / 1000, а не x * 0.001
+	operand := &operand{Value: value}
+	switch {
+	case factor.IsInt():
+		return &parser.BinaryExpr{Op: rune(Divide), Left: operand, Right: ratLiteral(0, factor)}
+	case factor.Num().IsInt64() && factor.Num().Int64() == 1:
+		return &parser.BinaryExpr{Op: rune(Multiply), Left: operand, Right: ratLiteral(0, new(big.Rat).SetInt(factor.Denom()))}
+	}
+	return &parser.BinaryExpr{Op: rune(Multiply), Left: operand, Right: ratLiteral(0, new(big.Rat).Inv(factor))}
+}
+
+// ratLiteral записывает точное значение числовым литералом. Текст литерала - дробь
+// ("3000", "25/18"): по нему formatLiteral без потери точности записывает число в любом режиме
+func ratLiteral(pos int, value *big.Rat) *parser.NumberLit {
+	f, _ := value.Float64()
+	return &parser.NumberLit{Position: pos, Text: value.RatString(), Value: f}
+}
+
+// integerLiteral возвращает значение целого числового литерала, возможно со знаком
+func integerLiteral(node parser.Node) (int, bool) {
+	sign := 1
+	if n, ok := node.(*parser.UnaryExpr); ok && (n.Op == '-' || n.Op == '+') {
+		if n.Op == '-' {
+			sign = -1
+		}
+		node = n.Operand
+	}
+	lit, ok := node.(*parser.NumberLit)
+	if !ok || lit.Value != float64(int(lit.Value)) {
+		return 0, false
+	}
+	return sign * int(lit.Value), true
+}
+
+// dimensional возвращает единицу, если у неё есть размерность. Отношение величин
+// одной размерности - просто число: 3 km / 20 m = 150
+func dimensional(u unit.Expr) unit.Expr {
+	if u.Dimension().IsZero() {
+		return nil
+	}
+	return u
+}
+
+func unitText(u unit.Expr) string {
+	if len(u) == 0 {
+		return "безразмерной величиной"
+	}
+	return u.String()
+}
+
+func quantityText(u unit.Expr) string {
+	if len(u) == 0 {
+		return "безразмерная величина"
+	}
+	return "величина в единицах, совместимых с " + u.String()
+}
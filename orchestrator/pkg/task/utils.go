@@ -1,130 +1,167 @@
 package task
 
 import (
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
 	"github.com/nktauserum/web-calculation/shared"
-	"github.com/nktauserum/web-calculation/shared/errors"
 )
 
-func convertToRPN(tokens []string) ([]string, error) {
-	var stack []string
-	var output []string
-	var precedence = map[rune]int{
-		'+': 1,
-		'-': 1,
-		'*': 2,
-		'/': 2,
-	}
-
-	parenthesesCount := 0
-
-	for i, token := range tokens {
-		switch token {
-		case "(":
-			parenthesesCount++
-			stack = append(stack, token)
-		case ")":
-			parenthesesCount--
-			if parenthesesCount < 0 {
-				return nil, errors.ErrMismatchedParentheses
-			}
-			for len(stack) > 0 && stack[len(stack)-1] != "(" {
-				output = append(output, stack[len(stack)-1])
-				stack = stack[:len(stack)-1]
-			}
-			if len(stack) > 0 {
-				stack = stack[:len(stack)-1] // Remove "("
-			}
-		case "+", "-", "*", "/":
-			if token == "/" && i < len(tokens)-1 && tokens[i+1] == "0" {
-				return nil, errors.ErrDivisionByZero
-			}
-			// Проверяем, является ли минус унарным
-			if token == "-" && (i == 0 || tokens[i-1] == "(" || isOperator(tokens[i-1])) {
-				// Добавляем 0 перед унарным минусом
-				output = append(output, "0")
-			}
-			for len(stack) > 0 {
-				top := stack[len(stack)-1]
-				if top != "(" && precedence[rune(top[0])] >= precedence[rune(token[0])] {
-					output = append(output, stack[len(stack)-1])
-					stack = stack[:len(stack)-1]
-				} else {
-					break
-				}
-			}
-			stack = append(stack, token)
-		default:
-			if !IsNumeric(token) && !strings.HasPrefix(token, "id") {
-				return nil, errors.ErrInvalidNumber
+// convertToRPN обходит синтаксическое дерево и возвращает выражение в обратной польской записи.
+// Числа записываются в виде, который требует режим арифметики (см. formatLiteral)
+func convertToRPN(node parser.Node, options Options) []string {
+	switch n := node.(type) {
+	case *parser.NumberLit:
+		return []string{formatLiteral(n, false, options)}
+	case *parser.ImaginaryLit:
+		return []string{shared.FormatComplex(complex(0, n.Value))}
+	case *parser.UncertainLit:
+		// Запись для просмотра: в задачи величина попадает пачками выборок (см. sampleUncertain)
+		return []string{parser.Format(n)}
+	case *parser.UnaryExpr:
+		if n.Op == '+' {
+			return convertToRPN(n.Operand, options)
+		}
+		// Логическое отрицание - сравнение с нулём
+		if n.Op == '¬' {
+			return append(convertToRPN(n.Operand, options), "0", string(Equal))
+		}
+		// Процент без левого операнда - сотая доля: 10% = 10 / 100
+		if n.Op == '%' {
+			return append(convertToRPN(n.Operand, options), percentBase, "/")
+		}
+		// Отрицательное число записываем сразу, без отдельной задачи
+		switch lit := n.Operand.(type) {
+		case *parser.NumberLit:
+			return []string{formatLiteral(lit, true, options)}
+		case *parser.ImaginaryLit:
+			return []string{shared.FormatComplex(complex(0, -lit.Value))}
+		}
+		// Унарный минус превращается в вычитание из нуля
+		output := append([]string{"0"}, convertToRPN(n.Operand, options)...)
+		return append(output, "-")
+	case *parser.BinaryExpr:
+		// Каждый операнд записывается ровно один раз: повторная запись поддерева
+		// удваивала бы работу на каждом уровне вложенности
+		left := convertToRPN(n.Left, options)
+		// and и or вычисляются лениво, как условия: правый операнд нужен не всегда
+		switch n.Op {
+		case '∧':
+			return branchRPN(left, append(convertToRPN(n.Right, options), "0", string(NotEqual)), []string{"0"})
+		case '∨':
+			return branchRPN(left, []string{"1"}, append(convertToRPN(n.Right, options), "0", string(NotEqual)))
+		}
+		var right []string
+		if percent, ok := n.Right.(*parser.UnaryExpr); ok && percent.Op == '%' {
+			operand := convertToRPN(percent.Operand, options)
+			if output := percentRPN(n.Op, left, operand); output != nil {
+				return output
 			}
-			output = append(output, token)
+			right = append(operand, percentBase, "/")
+		} else {
+			right = convertToRPN(n.Right, options)
 		}
+		output := append(left, right...)
+		return append(output, string(n.Op))
+	case *parser.Variable:
+		// Остаётся только связанная переменная в теле интеграла или ряда
+		return []string{n.Name}
+	case *parser.CallExpr:
+		if it, ok := parser.IterationOf(n); ok {
+			output := append(convertToRPN(n.Args[it.From], options), convertToRPN(n.Args[it.To], options)...)
+			return append(output, iterationToken(n.Name, it.Variable, convertToRPN(n.Args[it.Body], options)))
+		}
+		var output []string
+		for _, arg := range n.Args {
+			output = append(output, convertToRPN(arg, options)...)
+		}
+		// Имя функции в записи идёт после аргументов, как и оператор
+		return append(output, functionToken(n.Name, len(n.Args)))
+	case *operand:
+		return []string{n.Value}
+	case *parser.Conditional:
+		return branchRPN(convertToRPN(n.Cond, options), convertToRPN(n.Then, options), convertToRPN(n.Else, options))
 	}
+	return nil
+}
 
-	if parenthesesCount != 0 {
-		return nil, errors.ErrMismatchedParentheses
-	}
-
-	for len(stack) > 0 {
-		output = append(output, stack[len(stack)-1])
-		stack = stack[:len(stack)-1]
-	}
-
-	return output, nil
+// Приводит число к виду, в котором оно передаётся агентам
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
 // Проверяет, является ли токен оператором
 func isOperator(token string) bool {
 	switch token {
-	case "+", "-", "*", "/":
+	case "+", "-", "*", "/", "^", "%", "÷", "&", "|", "⊕", "≪", "≫", "<", ">", "≤", "≥", "=", "≠":
 		return true
 	default:
 		return false
 	}
 }
 
-// IsNumeric проверяет, является ли строка числом
-func IsNumeric(s string) bool {
-	for _, r := range s {
-		if !unicode.IsDigit(r) && r != '.' && r != ',' && r != '-' {
-			return false
-		}
-	}
-	return true
+// Проверяет, нельзя ли выполнять операцию с нулём в качестве второго аргумента
+func isDivision(op Operation) bool {
+	return op == Divide || op == IntDivide || op == Modulo
 }
 
-// tokenize разбивает выражение на токены
-func tokenize(expression string) []string {
-	var tokens []string // слайс для хранения токенов
-	var current string  // текущее накапливаемое число
+// functionToken записывает вызов функции в обратной польской записи вместе
+// с числом аргументов, которые нужно снять со стека: "sqrt/1", "avg/4"
+func functionToken(name string, arity int) string {
+	return name + "/" + strconv.Itoa(arity)
+}
 
-	// Перебираем каждый символ в выражении
-	for _, char := range expression {
-		if unicode.IsDigit(char) || char == '.' {
-			current += string(char)
-		} else if char == ',' {
-			current += string(".")
-		} else {
-			if current != "" {
-				tokens = append(tokens, current)
-				current = ""
-			}
-			if !unicode.IsSpace(char) {
-				tokens = append(tokens, string(char))
-			}
-		}
+// parseFunctionToken разбирает токен вызова функции, записанный functionToken.
+// Имя функции начинается с буквы, что отличает вызов от дроби "1/10" точного режима
+func parseFunctionToken(token string) (name string, arity int, ok bool) {
+	name, count, found := strings.Cut(token, "/")
+	if !found || name == "" {
+		return "", 0, false
+	}
+	if first, _ := utf8.DecodeRuneInString(name); !unicode.IsLetter(first) && first != '_' {
+		return "", 0, false
 	}
+	arity, err := strconv.Atoi(count)
+	if err != nil {
+		return "", 0, false
+	}
+	return name, arity, true
+}
+
+// IsNumeric проверяет, является ли строка конечным числом
+func IsNumeric(s string) bool {
+	value, err := strconv.ParseFloat(s, 64)
+	return err == nil && shared.CheckFinite(value) == nil
+}
 
-	if current != "" {
-		tokens = append(tokens, current)
+// IsReference проверяет, ссылается ли аргумент на результат другой задачи: id12
+func IsReference(s string) bool {
+	digits, found := strings.CutPrefix(s, "id")
+	if !found || digits == "" {
+		return false
+	}
+	for _, r := range digits {
+		if !unicode.IsDigit(r) {
+			return false
+		}
 	}
-	return tokens
+	return true
 }
 
-// Complete проверяет, готова ли задача к выполнению
+// Complete проверяет, готова ли задача к выполнению агентом: ни один аргумент
+// не ждёт результата другой задачи. У функций одного аргумента второй аргумент пуст.
+// Задачи невыбранных веток и условные задачи агентам не выдаются
 func Complete(task shared.Task) bool {
-	return IsNumeric(task.FirstArgument) && IsNumeric(task.SecondArgument)
+	if task.Guard != 0 || isControl(Operation(task.Operator)) {
+		return false
+	}
+	for _, token := range task.Program {
+		if IsReference(token) {
+			return false
+		}
+	}
+	return !IsReference(task.FirstArgument) && !IsReference(task.SecondArgument)
 }
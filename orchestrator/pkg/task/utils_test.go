@@ -0,0 +1,145 @@
+package task
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+func TestConvertToRPN(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 + 2 * 3", "1 2 3 * +"},
+		{"(1 + 2) * 3", "1 2 + 3 *"},
+		{"10 - 4 - 3", "10 4 - 3 -"},
+		{"2 ^ 3 ^ 2", "2 3 2 ^ ^"},
+		{"2 ** 3 * 4", "2 3 ^ 4 *"},
+		{"7 // 2 % 3", "7 2 ÷ 3 %"},
+		{"1 << 2 | 0xF", "1 2 ≪ 15 |"},
+		{"-3", "-3"},
+		{"-(1 + 2)", "0 1 2 + -"},
+		{"+2 * 3", "2 3 *"},
+		{"sqrt(4) + max(1, 2, 3)", "4 sqrt/1 1 2 3 max/3 +"},
+		{"-sin(1 + 2)", "0 1 2 + sin/1 -"},
+		{"2i * (1 - i)", "2i 1 1i - *"},
+		{"-1.5i", "-1.5i"},
+		{"1 + 2 >= 3", "1 2 + 3 ≥"},
+		{"2(3 + 4)", "2 3 4 + *"},
+		{"6 x 7", "6 7 *"},
+		{"10%", "10 100 /"},
+		{"200 + 10%", "200 100 10 + * 100 /"},
+		{"200 - 10%", "200 100 10 - * 100 /"},
+		{"50 * 10%", "50 10 * 100 /"},
+		{"50 / 10%", "50 100 * 10 /"},
+		{"2 ^ 50%", "2 50 100 / ^"},
+		{"1 + 10% + 2", "1 100 10 + * 100 / 2 +"},
+		{"7 % -3", "7 -3 %"},
+		{"not 1", "1 0 ="},
+		{"1 and 2", "1 ? 2 0 ≠ : 0 ;"},
+		{"1 or 0", "1 ? 1 : 0 0 ≠ ;"},
+		{"if(1 < 2, 3, 4)", "1 2 < ? 3 : 4 ;"},
+		{"sum(k, 1, 3, k ^ 2)", "1 3 Σk[k 2 ^]"},
+	}
+
+	for _, tt := range tests {
+		node, err := parser.Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if got := strings.Join(convertToRPN(node, Options{Mode: shared.ModeFloat}), " "); got != tt.want {
+			t.Errorf("convertToRPN(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// Каждое поддерево записывается один раз: глубокая вложенность справа
+// не должна удваивать работу на каждом уровне
+func TestConvertToRPNNested(t *testing.T) {
+	input, want := "1", 1
+	for i := 0; i < 60; i++ {
+		op := []string{"+", "and", "or", "^"}[i%4]
+		input = "1 " + op + " (" + input + ")"
+		if op == "and" || op == "or" {
+			want += 7 // левый операнд, ? : ; и сравнение правого с нулём
+		} else {
+			want += 2
+		}
+	}
+
+	node, err := parser.Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := convertToRPN(node, Options{Mode: shared.ModeFloat}); len(got) != want {
+		t.Errorf("convertToRPN: %d токенов, want %d", len(got), want)
+	}
+}
+
+// В точном режиме литералы записываются дробями по исходному тексту
+func TestConvertToRPNExact(t *testing.T) {
+	node, err := parser.Parse("0.1 + -0.25 * 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(convertToRPN(node, Options{Mode: shared.ModeExact}), " "); got != "1/10 -1/4 3 * +" {
+		t.Errorf("convertToRPN(0.1 + -0.25 * 3, exact) = %q", got)
+	}
+}
+
+// В интервальном режиме литералы, не представимые в float64, становятся интервалами
+// шириной в одну единицу последнего разряда
+func TestConvertToRPNInterval(t *testing.T) {
+	plan, err := PlanExpression("[-1, 0.1] * 0.5 + 0.1", Scope{}, Options{Mode: shared.ModeInterval, Locale: parser.DefaultLocale}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[-1, 0.1] 0.5 * [0.09999999999999999, 0.1] +"
+	if got := strings.Join(plan.RPN[0], " "); got != want {
+		t.Errorf("convertToRPN(interval) = %q, want %q", got, want)
+	}
+}
+
+func TestFunctionToken(t *testing.T) {
+	token := functionToken("avg", 4)
+	name, arity, ok := parseFunctionToken(token)
+	if token != "avg/4" || !ok || name != "avg" || arity != 4 {
+		t.Errorf("functionToken(avg, 4) = %q, разобран как %q, %d, %v", token, name, arity, ok)
+	}
+	if _, _, ok := parseFunctionToken("/"); ok {
+		t.Errorf("parseFunctionToken(%q) = true, want false", "/")
+	}
+	if !isOperator("≠") || isOperator("?") {
+		t.Errorf("isOperator: сравнения - операторы агента, маркеры веток - нет")
+	}
+}
+
+func TestIsReference(t *testing.T) {
+	tests := []struct {
+		arg       string
+		reference bool
+		numeric   bool
+	}{
+		{"id12", true, false},
+		{"id", false, false},
+		{"idx", false, false},
+		{"-1.5e-3", false, true},
+		{"12", false, true},
+		{"+Inf", false, false},
+		{"NaN", false, false},
+		{"", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsReference(tt.arg); got != tt.reference {
+			t.Errorf("IsReference(%q) = %v, want %v", tt.arg, got, tt.reference)
+		}
+		if got := IsNumeric(tt.arg); got != tt.numeric {
+			t.Errorf("IsNumeric(%q) = %v, want %v", tt.arg, got, tt.numeric)
+		}
+	}
+}
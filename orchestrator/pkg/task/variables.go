@@ -0,0 +1,100 @@
+package task
+
+import (
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// operand - уже вычисленная часть сценария: ссылка на задачу (idN) или число,
+// присвоенное переменной сценария. В обратную польскую запись попадает как есть
+type operand struct {
+	Position int
+	Value    string
+}
+
+func (n *operand) Pos() int { return n.Position }
+
+// substituteVariables заменяет ссылки на переменные их текущими значениями.
+// locals - результаты элементов переменных, присвоенных в предыдущих инструкциях сценария
+// (у числа элемент один); они закрывают сохранённые переменные пользователя, а связанная
+// переменная интеграла или ряда в его теле закрывает их все и остаётся в дереве. Возвращает новое дерево и значения использованных
+// сохранённых переменных: выражение вычисляется с этими значениями, даже если переменные потом изменятся
+func substituteVariables(node parser.Node, values map[string]float64, locals map[string][]string) (parser.Node, map[string]float64, error) {
+	used := make(map[string]float64)
+	bound := make(map[string]int) // связанные переменные интегралов и рядов, в телах которых идёт обход
+
+	var substitute func(node parser.Node) (parser.Node, error)
+	substitute = func(node parser.Node) (parser.Node, error) {
+		switch n := node.(type) {
+		case *parser.Variable:
+			if bound[n.Name] > 0 {
+				return n, nil
+			}
+			if local, ok := locals[n.Name]; ok {
+				return &operand{Position: n.Position, Value: local[0]}, nil
+			}
+			value, ok := values[n.Name]
+			if !ok {
+				return nil, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: "определённая переменная", Err: errors.ErrUndefinedVariable}
+			}
+			used[n.Name] = value
+			return &parser.NumberLit{Position: n.Position, Text: formatNumber(value), Value: value}, nil
+		case *element:
+			return &operand{Position: n.Position, Value: locals[n.Name][n.Index]}, nil
+		case *parser.UnaryExpr:
+			operand, err := substitute(n.Operand)
+			if err != nil {
+				return nil, err
+			}
+			return &parser.UnaryExpr{Position: n.Position, Op: n.Op, Operand: operand}, nil
+		case *parser.BinaryExpr:
+			left, err := substitute(n.Left)
+			if err != nil {
+				return nil, err
+			}
+			right, err := substitute(n.Right)
+			if err != nil {
+				return nil, err
+			}
+			return &parser.BinaryExpr{Position: n.Position, Op: n.Op, Left: left, Right: right}, nil
+		case *parser.CallExpr:
+			it, iteration := parser.IterationOf(n)
+			args := make([]parser.Node, len(n.Args))
+			for i, arg := range n.Args {
+				if iteration && i == it.Index {
+					args[i] = arg
+					continue
+				}
+				if iteration && i == it.Body {
+					bound[it.Variable]++
+				}
+				substituted, err := substitute(arg)
+				if iteration && i == it.Body {
+					bound[it.Variable]--
+				}
+				if err != nil {
+					return nil, err
+				}
+				args[i] = substituted
+			}
+			return &parser.CallExpr{Position: n.Position, Name: n.Name, Args: args}, nil
+		case *parser.Conditional:
+			parts := make([]parser.Node, 3)
+			for i, part := range []parser.Node{n.Cond, n.Then, n.Else} {
+				substituted, err := substitute(part)
+				if err != nil {
+					return nil, err
+				}
+				parts[i] = substituted
+			}
+			return &parser.Conditional{Position: n.Position, Cond: parts[0], Then: parts[1], Else: parts[2]}, nil
+		}
+		return node, nil
+	}
+
+	result, err := substitute(node)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, used, nil
+}
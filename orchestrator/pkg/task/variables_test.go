@@ -0,0 +1,63 @@
+package task
+
+import (
+	stderrors "errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+func TestSubstituteVariables(t *testing.T) {
+	values := map[string]float64{"x": 3, "y": -1, "unused": 7}
+	node, err := parser.Parse("x * 2 + max(y, x)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	substituted, used, err := substituteVariables(node, values, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(convertToRPN(substituted, Options{Mode: shared.ModeFloat}), " "); got != "3 2 * -1 3 max/2 +" {
+		t.Errorf("convertToRPN после подстановки = %q", got)
+	}
+	if want := map[string]float64{"x": 3, "y": -1}; !maps.Equal(used, want) {
+		t.Errorf("использованные переменные = %v, want %v", used, want)
+	}
+}
+
+func TestSubstituteUndefined(t *testing.T) {
+	node, err := parser.Parse("1 + rate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = substituteVariables(node, nil, nil)
+	var syntaxErr *errors.SyntaxError
+	if !stderrors.Is(err, errors.ErrUndefinedVariable) || !stderrors.As(err, &syntaxErr) || syntaxErr.Pos != 4 {
+		t.Errorf("substituteVariables(1 + rate) = %v, want %v at 4", err, errors.ErrUndefinedVariable)
+	}
+}
+
+// Переменные сценария закрывают сохранённые переменные с теми же именами
+func TestSubstituteLocals(t *testing.T) {
+	node, err := parser.Parse("x + y")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	substituted, used, err := substituteVariables(node, map[string]float64{"x": 3, "y": 4}, map[string][]string{"x": {"id2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(convertToRPN(substituted, Options{Mode: shared.ModeFloat}), " "); got != "id2 4 +" {
+		t.Errorf("convertToRPN после подстановки = %q, want %q", got, "id2 4 +")
+	}
+	if want := map[string]float64{"y": 4}; !maps.Equal(used, want) {
+		t.Errorf("использованные переменные = %v, want %v", used, want)
+	}
+}
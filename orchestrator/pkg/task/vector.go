@@ -0,0 +1,529 @@
+package task
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Наибольший размер матрицы, определитель которой раскладывается на задачи.
+// Разложение по строке даёт n! произведений: у матрицы 6×6 это около 2000 задач
+const maxDeterminant = 6
+
+// tensor - значение выражения с векторами, разложенное на элементы: форма и скалярное
+// дерево каждого элемента по строкам. У числа форма пуста, а элемент один.
+// Агенты вычисляют только числа: вектор [1, 2] + [3, 4] - это задачи 1 + 3 и 2 + 4
+type tensor struct {
+	shape    []int
+	elements []parser.Node
+}
+
+func scalar(node parser.Node) tensor {
+	return tensor{elements: []parser.Node{node}}
+}
+
+// element - элемент вектора, присвоенного переменной сценария. Заменяется
+// операндом с результатом этого элемента (см. substituteVariables)
+type element struct {
+	Position int
+	Name     string
+	Index    int
+}
+
+func (n *element) Pos() int { return n.Position }
+
+// vectorizer раскладывает выражение на элементы. shapes - формы переменных сценария.
+// В режиме interval пара чисел [lo, hi] - интервал, а других векторов нет
+type vectorizer struct {
+	shapes    map[string][]int
+	intervals bool
+	first     parser.Node // первый вектор выражения: с погрешностями векторы не сочетаются
+}
+
+// expand возвращает элементы значения узла
+func (v *vectorizer) expand(node parser.Node) (tensor, error) {
+	switch n := node.(type) {
+	case *parser.VectorLit:
+		if v.intervals {
+			interval, err := intervalLiteral(n)
+			if err != nil {
+				return tensor{}, err
+			}
+			return scalar(interval), nil
+		}
+		return v.vector(n)
+	case *parser.Variable:
+		shape := v.shapes[n.Name]
+		if len(shape) == 0 {
+			return scalar(n), nil
+		}
+		result := tensor{shape: shape, elements: make([]parser.Node, count(shape))}
+		for i := range result.elements {
+			result.elements[i] = &element{Position: n.Position, Name: n.Name, Index: i}
+		}
+		return result, nil
+	case *parser.UnaryExpr:
+		operand, err := v.expand(n.Operand)
+		if err != nil {
+			return tensor{}, err
+		}
+		return operand.apply(func(x parser.Node) parser.Node {
+			return &parser.UnaryExpr{Position: n.Position, Op: n.Op, Operand: x}
+		}), nil
+	case *parser.BinaryExpr:
+		return v.binary(n)
+	case *parser.CallExpr:
+		return v.call(n)
+	case *parser.Conditional:
+		parts := make([]tensor, 3)
+		for i, part := range []parser.Node{n.Cond, n.Then, n.Else} {
+			expanded, err := v.expand(part)
+			if err != nil {
+				return tensor{}, err
+			}
+			parts[i] = expanded
+		}
+		// Условие, как и ветки, может быть вектором: if([1, 0], a, b) выбирает поэлементно
+		return broadcast(parts, n.Else, func(x []parser.Node) parser.Node {
+			return &parser.Conditional{Position: n.Position, Cond: x[0], Then: x[1], Else: x[2]}
+		})
+	}
+	return scalar(node), nil
+}
+
+// vector собирает элементы вектора. Все они должны быть одной формы:
+// вектор из векторов одной длины - матрица
+func (v *vectorizer) vector(n *parser.VectorLit) (tensor, error) {
+	if v.first == nil {
+		v.first = n
+	}
+	var result tensor
+	for i, node := range n.Elements {
+		item, err := v.expand(node)
+		if err != nil {
+			return tensor{}, err
+		}
+		if i == 0 {
+			result.shape = append([]int{len(n.Elements)}, item.shape...)
+		} else if !slices.Equal(item.shape, result.shape[1:]) {
+			return tensor{}, &errors.SyntaxError{Pos: node.Pos(), Token: parser.Format(node), Expected: shapeText(result.shape[1:]), Err: errors.ErrShapeMismatch}
+		}
+		result.elements = append(result.elements, item.elements...)
+	}
+	return result, nil
+}
+
+// intervalLiteral превращает пару чисел [lo, hi] в интервал режима interval: наименьший
+// интервал из float64, который содержит отрезок. Парой чисел вектор становится
+// и после перевода единиц: [1 km, 2 km] - это [1000, 2000]
+func intervalLiteral(n *parser.VectorLit) (parser.Node, error) {
+	if len(n.Elements) != 2 || !isSignedLiteral(n.Elements[0]) || !isSignedLiteral(n.Elements[1]) {
+		return nil, &errors.SyntaxError{Pos: n.Position, Token: "[", Expected: "интервал [lo, hi] из двух чисел", Err: errors.ErrVectorMode}
+	}
+	lo, hi := boundValue(n.Elements[0]), boundValue(n.Elements[1])
+	if lo.Cmp(hi) > 0 {
+		return nil, &errors.SyntaxError{Pos: n.Position, Token: "[", Err: errors.ErrInvalidInterval}
+	}
+	return &operand{Position: n.Position, Value: enclose(lo, hi)}, nil
+}
+
+// isSignedLiteral проверяет, что узел - числовой литерал, возможно со знаком
+func isSignedLiteral(node parser.Node) bool {
+	if n, ok := node.(*parser.UnaryExpr); ok && (n.Op == '-' || n.Op == '+') {
+		node = n.Operand
+	}
+	_, ok := node.(*parser.NumberLit)
+	return ok
+}
+
+// binary раскладывает бинарную операцию. Операции, кроме @, выполняются поэлементно,
+// число применяется к каждому элементу вектора: [1, 2] * 3 = [3, 6]
+func (v *vectorizer) binary(n *parser.BinaryExpr) (tensor, error) {
+	left, err := v.expand(n.Left)
+	if err != nil {
+		return tensor{}, err
+	}
+	right, err := v.expand(n.Right)
+	if err != nil {
+		return tensor{}, err
+	}
+	if n.Op == '@' {
+		return product(n, left, right)
+	}
+	return broadcast([]tensor{left, right}, n, func(x []parser.Node) parser.Node {
+		return &parser.BinaryExpr{Position: n.Position, Op: n.Op, Left: x[0], Right: x[1]}
+	})
+}
+
+// product раскладывает матричное произведение: каждый элемент результата - сумма
+// произведений, и все суммы вычисляются агентами параллельно. Вектор слева - строка,
+// справа - столбец, поэтому произведение двух векторов - скалярное
+func product(n *parser.BinaryExpr, left, right tensor) (tensor, error) {
+	for _, operand := range []struct {
+		node  parser.Node
+		value tensor
+	}{{n.Left, left}, {n.Right, right}} {
+		if len(operand.value.shape) == 0 || len(operand.value.shape) > 2 {
+			return tensor{}, &errors.SyntaxError{Pos: operand.node.Pos(), Token: parser.Format(operand.node), Expected: "вектор или матрица", Err: errors.ErrShapeMismatch}
+		}
+	}
+
+	inner := left.shape[len(left.shape)-1]
+	if right.shape[0] != inner {
+		return tensor{}, &errors.SyntaxError{Pos: n.Position, Token: "@", Expected: fmt.Sprintf("правый операнд из %d строк", inner), Err: errors.ErrShapeMismatch}
+	}
+	rows, columns := count(left.shape[:len(left.shape)-1]), count(right.shape[1:])
+
+	result := tensor{shape: append(slices.Clone(left.shape[:len(left.shape)-1]), right.shape[1:]...)}
+	for i := range rows {
+		for j := range columns {
+			terms := make([]parser.Node, inner)
+			for k := range inner {
+				terms[k] = &parser.BinaryExpr{Position: n.Position, Op: rune(Multiply), Left: left.elements[i*inner+k], Right: right.elements[k*columns+j]}
+			}
+			result.elements = append(result.elements, balancedSum(n.Position, terms))
+		}
+	}
+	return result, nil
+}
+
+// balancedSum складывает слагаемые сбалансированным деревом, как reduce: сложения
+// одного уровня не зависят друг от друга
+func balancedSum(pos int, terms []parser.Node) parser.Node {
+	for len(terms) > 1 {
+		var next []parser.Node
+		for i := 0; i+1 < len(terms); i += 2 {
+			next = append(next, &parser.BinaryExpr{Position: pos, Op: rune(Add), Left: terms[i], Right: terms[i+1]})
+		}
+		if len(terms)%2 == 1 {
+			next = append(next, terms[len(terms)-1])
+		}
+		terms = next
+	}
+	return terms[0]
+}
+
+// call раскладывает вызов встроенной функции. Функции одного аргумента применяются
+// к каждому элементу, агрегатные - ко всем элементам всех аргументов: sum([1, 2], 3) = 6
+func (v *vectorizer) call(n *parser.CallExpr) (tensor, error) {
+	if it, ok := parser.IterationOf(n); ok {
+		return v.iteration(n, it)
+	}
+
+	args := make([]tensor, len(n.Args))
+	for i, arg := range n.Args {
+		expanded, err := v.expand(arg)
+		if err != nil {
+			return tensor{}, err
+		}
+		args[i] = expanded
+	}
+
+	switch {
+	case shared.AggregateFunctions[n.Name]:
+		var elements []parser.Node
+		for _, arg := range args {
+			elements = append(elements, arg.elements...)
+		}
+		return scalar(&parser.CallExpr{Position: n.Position, Name: n.Name, Args: elements}), nil
+	case n.Name == "transpose":
+		return transpose(n, args[0])
+	case n.Name == "det":
+		return determinant(n, args[0])
+	}
+	return broadcast(args, n, func(x []parser.Node) parser.Node {
+		return &parser.CallExpr{Position: n.Position, Name: n.Name, Args: x}
+	})
+}
+
+// iteration раскладывает интеграл или ряд: его пределы и тело - числа. Связанная
+// переменная закрывает в теле одноимённый вектор сценария
+func (v *vectorizer) iteration(n *parser.CallExpr, it parser.Iteration) (tensor, error) {
+	args := slices.Clone(n.Args)
+	shapes := v.shapes
+	for i, arg := range n.Args {
+		if i == it.Index {
+			continue
+		}
+		if i == it.Body {
+			v.shapes = maps.Clone(shapes)
+			delete(v.shapes, it.Variable)
+		}
+		expanded, err := v.expand(arg)
+		v.shapes = shapes
+		if err != nil {
+			return tensor{}, err
+		}
+		if len(expanded.shape) > 0 {
+			return tensor{}, &errors.SyntaxError{Pos: arg.Pos(), Token: parser.Format(arg), Expected: "число", Err: errors.ErrIterationMode}
+		}
+		args[i] = expanded.elements[0]
+	}
+	return scalar(&parser.CallExpr{Position: n.Position, Name: n.Name, Args: args}), nil
+}
+
+// transpose меняет строки матрицы со столбцами. Вектор считается строкой
+// и становится столбцом: transpose([1, 2]) = [[1], [2]]
+func transpose(n *parser.CallExpr, m tensor) (tensor, error) {
+	switch len(m.shape) {
+	case 1:
+		m.shape = []int{m.shape[0], 1}
+		return m, nil
+	case 2:
+		rows, columns := m.shape[0], m.shape[1]
+		result := tensor{shape: []int{columns, rows}, elements: make([]parser.Node, len(m.elements))}
+		for i := range rows {
+			for j := range columns {
+				result.elements[j*rows+i] = m.elements[i*columns+j]
+			}
+		}
+		return result, nil
+	}
+	return tensor{}, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: "вектор или матрица", Err: errors.ErrShapeMismatch}
+}
+
+// determinant раскладывает определитель квадратной матрицы по первой строке
+func determinant(n *parser.CallExpr, m tensor) (tensor, error) {
+	if len(m.shape) != 2 || m.shape[0] != m.shape[1] {
+		return tensor{}, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: "квадратная матрица", Err: errors.ErrShapeMismatch}
+	}
+	size := m.shape[0]
+	if size > maxDeterminant {
+		return tensor{}, &errors.SyntaxError{Pos: n.Position, Token: n.Name, Expected: fmt.Sprintf("матрица не больше %d×%d", maxDeterminant, maxDeterminant), Err: errors.ErrExpressionTooLarge}
+	}
+
+	// minor - определитель из строк начиная с row и столбцов columns
+	var minor func(row int, columns []int) parser.Node
+	minor = func(row int, columns []int) parser.Node {
+		if len(columns) == 1 {
+			return m.elements[row*size+columns[0]]
+		}
+		var result parser.Node
+		for i, column := range columns {
+			rest := slices.Delete(slices.Clone(columns), i, i+1)
+			term := &parser.BinaryExpr{Position: n.Position, Op: rune(Multiply), Left: m.elements[row*size+column], Right: minor(row+1, rest)}
+			switch {
+			case i == 0:
+				result = term
+			case i%2 == 1:
+				result = &parser.BinaryExpr{Position: n.Position, Op: rune(Subtract), Left: result, Right: term}
+			default:
+				result = &parser.BinaryExpr{Position: n.Position, Op: rune(Add), Left: result, Right: term}
+			}
+		}
+		return result
+	}
+
+	columns := make([]int, size)
+	for i := range columns {
+		columns[i] = i
+	}
+	return scalar(minor(0, columns)), nil
+}
+
+// broadcast применяет операцию build к элементам операндов одной формы.
+// Число подставляется в каждый элемент. node - узел, на который указывает ошибка
+func broadcast(operands []tensor, node parser.Node, build func([]parser.Node) parser.Node) (tensor, error) {
+	var shape []int
+	for _, operand := range operands {
+		if len(operand.shape) == 0 {
+			continue
+		}
+		if shape != nil && !slices.Equal(operand.shape, shape) {
+			token, pos := parser.Format(node), node.Pos()
+			if n, ok := node.(*parser.BinaryExpr); ok {
+				token, pos = parser.OperatorText(n.Op), n.Position
+			}
+			return tensor{}, &errors.SyntaxError{Pos: pos, Token: token, Expected: shapeText(shape), Err: errors.ErrShapeMismatch}
+		}
+		shape = operand.shape
+	}
+
+	result := tensor{shape: shape, elements: make([]parser.Node, count(shape))}
+	for i := range result.elements {
+		args := make([]parser.Node, len(operands))
+		for j, operand := range operands {
+			args[j] = operand.elements[0]
+			if len(operand.shape) > 0 {
+				args[j] = operand.elements[i]
+			}
+		}
+		result.elements[i] = build(args)
+	}
+	return result, nil
+}
+
+// apply применяет операцию к каждому элементу
+func (t tensor) apply(build func(parser.Node) parser.Node) tensor {
+	result := tensor{shape: t.shape, elements: make([]parser.Node, len(t.elements))}
+	for i, x := range t.elements {
+		result.elements[i] = build(x)
+	}
+	return result
+}
+
+// count возвращает число элементов значения формы shape
+func count(shape []int) int {
+	n := 1
+	for _, size := range shape {
+		n *= size
+	}
+	return n
+}
+
+// shapeText описывает форму значения в сообщении об ошибке
+func shapeText(shape []int) string {
+	switch len(shape) {
+	case 0:
+		return "число"
+	case 1:
+		return fmt.Sprintf("вектор из %d элементов", shape[0])
+	case 2:
+		return fmt.Sprintf("матрица %d×%d", shape[0], shape[1])
+	}
+	sizes := make([]string, len(shape))
+	for i, size := range shape {
+		sizes[i] = strconv.Itoa(size)
+	}
+	return "массив " + strings.Join(sizes, "×")
+}
+
+// packRPN записывает обратную польскую запись вектора для просмотра: записи элементов,
+// после каждой строки - токен "[n]", который собирает из n элементов вектор
+func packRPN(shape []int, elements [][]string) []string {
+	if len(shape) == 0 {
+		return elements[0]
+	}
+	var output []string
+	step := len(elements) / shape[0]
+	for i := 0; i < len(elements); i += step {
+		output = append(output, packRPN(shape[1:], elements[i:i+step])...)
+	}
+	return append(output, "["+strconv.Itoa(shape[0])+"]")
+}
+
+// pack собирает результаты элементов в вектор формы shape задачами Pack
+// и возвращает операнд со всем вектором. Матрица собирается из строк
+func (p *planner) pack(operands []string, shape []int) string {
+	if len(shape) == 0 {
+		return operands[0]
+	}
+	step := len(operands) / shape[0]
+	rows := make([]string, 0, shape[0])
+	for i := 0; i < len(operands); i += step {
+		rows = append(rows, p.pack(operands[i:i+step], shape[1:]))
+	}
+
+	// Вектор из одного элемента тоже собирается задачей: иначе результатом осталось бы число
+	if len(rows) == 1 {
+		return p.add(shared.Task{FirstArgument: rows[0], ThirdArgument: "1,0", Operator: rune(Pack)})
+	}
+
+	// Сбалансированное дерево, как у collect. Размер части - число её элементов:
+	// часть из одного элемента - сам элемент, из нескольких - уже собранный вектор
+	sizes := make([]int, len(rows))
+	for i := range sizes {
+		sizes[i] = 1
+	}
+	for len(rows) > 1 {
+		var next []string
+		var nextSizes []int
+		for i := 0; i+1 < len(rows); i += 2 {
+			next = append(next, p.add(shared.Task{
+				FirstArgument:  rows[i],
+				SecondArgument: rows[i+1],
+				ThirdArgument:  fmt.Sprintf("%d,%d", sizes[i], sizes[i+1]),
+				Operator:       rune(Pack),
+			}))
+			nextSizes = append(nextSizes, sizes[i]+sizes[i+1])
+		}
+		if len(rows)%2 == 1 {
+			next = append(next, rows[len(rows)-1])
+			nextSizes = append(nextSizes, sizes[len(sizes)-1])
+		}
+		rows, sizes = next, nextSizes
+	}
+	return rows[0]
+}
+
+// pack завершает задачу Pack собранным вектором
+func (q *Queue) pack(task shared.Task) {
+	value, err := concatElements(task)
+	if err != nil {
+		q.Fail(task.ID, err.Error())
+		return
+	}
+
+	_, err = q.db.Exec(
+		"UPDATE tasks SET status = ?, result = 0, imag = 0, value = ? WHERE id = ?",
+		true, value, task.ID,
+	)
+	if err != nil {
+		log.Printf("Ошибка при обновлении задачи %d: %v", task.ID, err)
+	}
+}
+
+// concatElements собирает вектор из аргументов задачи Pack. Аргумент из одного
+// элемента - сам элемент (число или строка матрицы), из нескольких - вектор,
+// элементы которого переходят в результат
+func concatElements(task shared.Task) (string, error) {
+	sizes := strings.Split(task.ThirdArgument, ",")
+	if len(sizes) != 2 {
+		return "", fmt.Errorf("%w: размеры частей вектора %q", errors.ErrInvalidNumber, task.ThirdArgument)
+	}
+	var elements []string
+	for i, arg := range []string{task.FirstArgument, task.SecondArgument} {
+		size, err := strconv.Atoi(sizes[i])
+		if err != nil {
+			return "", fmt.Errorf("%w: размеры частей вектора %q", errors.ErrInvalidNumber, task.ThirdArgument)
+		}
+		switch size {
+		case 0:
+		case 1:
+			elements = append(elements, arg)
+		default:
+			parts, ok := splitVector(arg)
+			if !ok || len(parts) != size {
+				return "", fmt.Errorf("%w: %q", errors.ErrInvalidNumber, arg)
+			}
+			elements = append(elements, parts...)
+		}
+	}
+	return "[" + strings.Join(elements, ", ") + "]", nil
+}
+
+// splitVector делит запись вектора "[1, [2, 3]]" на элементы верхнего уровня
+func splitVector(value string) ([]string, bool) {
+	inner, ok := strings.CutPrefix(value, "[")
+	if !ok {
+		return nil, false
+	}
+	inner, ok = strings.CutSuffix(inner, "]")
+	if !ok {
+		return nil, false
+	}
+
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range inner {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(inner[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(inner[start:])), true
+}
@@ -0,0 +1,63 @@
+package task
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/orchestrator/pkg/parser"
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+// Векторы раскладываются на элементы до создания задач
+func TestPlanVectors(t *testing.T) {
+	tests := []struct {
+		input string
+		rpn   string
+		shape []int
+	}{
+		{"[1, 2] + [3, 4]", "1 3 + 2 4 + [2]", []int{2}},
+		{"[1, 2] * 3", "1 3 * 2 3 * [2]", []int{2}},
+		{"[1, 2] @ [3, 4]", "1 3 * 2 4 * +", nil},
+		{"[[1, 2], [3, 4]] @ [5, 6]", "1 5 * 2 6 * + 3 5 * 4 6 * + [2]", []int{2}},
+		{"transpose([1, 2])", "1 [1] 2 [1] [2]", []int{2, 1}},
+		{"det([[2, 1], [1, 3]])", "2 3 * 1 1 * -", nil},
+		{"sum([1, 2], 3)", "1 2 3 sum/3", nil},
+		{"[5]", "5 [1]", []int{1}},
+	}
+
+	for _, tt := range tests {
+		plan, err := PlanExpression(tt.input, Scope{}, Options{Mode: shared.ModeFloat, Locale: parser.DefaultLocale}, 1)
+		if err != nil {
+			t.Errorf("PlanExpression(%q): %v", tt.input, err)
+			continue
+		}
+		if got := strings.Join(plan.RPN[0], " "); got != tt.rpn || !slices.Equal(plan.Shapes[0], tt.shape) {
+			t.Errorf("PlanExpression(%q) = %q, форма %v, want %q, %v", tt.input, got, plan.Shapes[0], tt.rpn, tt.shape)
+		}
+	}
+}
+
+func TestConcatElements(t *testing.T) {
+	tests := []struct {
+		first, second, sizes string
+		want                 string
+	}{
+		{"1", "2", "1,1", "[1, 2]"},
+		{"[1, 2]", "3", "2,1", "[1, 2, 3]"},
+		{"[1, 2]", "[3, 4]", "1,1", "[[1, 2], [3, 4]]"},
+		{"[[1, 2], [3, 4]]", "[5, 6]", "2,1", "[[1, 2], [3, 4], [5, 6]]"},
+		{"7", "", "1,0", "[7]"},
+	}
+
+	for _, tt := range tests {
+		task := shared.Task{FirstArgument: tt.first, SecondArgument: tt.second, ThirdArgument: tt.sizes, Operator: rune(Pack)}
+		if got, err := concatElements(task); err != nil || got != tt.want {
+			t.Errorf("concatElements(%s, %s, %s) = %q, %v, want %q", tt.first, tt.second, tt.sizes, got, err, tt.want)
+		}
+	}
+
+	if _, err := concatElements(shared.Task{FirstArgument: "[1, 2]", SecondArgument: "3", ThirdArgument: "3,1"}); err == nil {
+		t.Error("concatElements с неверным размером части без ошибки")
+	}
+}
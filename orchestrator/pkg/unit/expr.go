@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Term - множитель составной единицы: обозначение и показатель степени, s^-2 - {"s", -2}
+type Term struct {
+	Symbol string
+	Power  int
+}
+
+// Expr - составная единица в том виде, в каком она записана: km/h, kg·m/s^2.
+// Пустая единица - у безразмерной величины
+type Expr []Term
+
+// Unit возвращает множитель перевода в СИ и размерность составной единицы.
+// Обозначения должны быть известны Lookup: их проверяет разбор выражения
+func (e Expr) Unit() Unit {
+	u := Unit{Factor: big.NewRat(1, 1)}
+	for _, term := range e {
+		base, _ := Lookup(term.Symbol)
+		factor := new(big.Rat).Set(base.Factor)
+		if term.Power < 0 {
+			factor.Inv(factor)
+		}
+		for range abs(term.Power) {
+			u.Factor.Mul(u.Factor, factor)
+		}
+		for i := range u.Dimension {
+			u.Dimension[i] += base.Dimension[i] * term.Power
+		}
+	}
+	return u
+}
+
+// Dimension возвращает размерность составной единицы
+func (e Expr) Dimension() Dimension {
+	return e.Unit().Dimension
+}
+
+// String записывает единицу: множители числителя через '·', знаменателя - через '/':
+// km/h, kg·m/s^2. Единица без числителя записывается отрицательными степенями: s^-1
+func (e Expr) String() string {
+	numerator, denominator := e.Split()
+	parts := make([]string, len(numerator))
+	for i, term := range numerator {
+		parts[i] = termText(term.Symbol, term.Power)
+	}
+	text := strings.Join(parts, "·")
+	for _, term := range denominator {
+		text += "/" + termText(term.Symbol, term.Power)
+	}
+	return text
+}
+
+// Split делит единицу на числитель и знаменатель с положительными степенями: kg·m/s^2 -
+// kg·m и s^2. У единицы без числителя знаменатель пуст, а в числителе остаются
+// отрицательные степени: s^-1
+func (e Expr) Split() (numerator, denominator Expr) {
+	for _, term := range e {
+		if term.Power > 0 {
+			numerator = append(numerator, term)
+		} else {
+			denominator = append(denominator, Term{term.Symbol, -term.Power})
+		}
+	}
+	if len(numerator) == 0 {
+		return e, nil
+	}
+	return numerator, denominator
+}
+
+func termText(symbol string, power int) string {
+	if power == 1 {
+		return symbol
+	}
+	return symbol + "^" + strconv.Itoa(power)
+}
+
+// Mul перемножает единицы. Множитель b той же размерности, что и множитель a,
+// переводится в единицу из a: km/h · min = km, m · km = m^2
+func Mul(a, b Expr) Expr {
+	result := slices.Clone(a)
+	for _, term := range b {
+		i := slices.IndexFunc(result, func(t Term) bool { return t.Symbol == term.Symbol })
+		if i < 0 {
+			dimension := Expr{{term.Symbol, 1}}.Dimension()
+			i = slices.IndexFunc(result, func(t Term) bool { return Expr{{t.Symbol, 1}}.Dimension() == dimension })
+		}
+		if i < 0 {
+			result = append(result, term)
+			continue
+		}
+		result[i].Power += term.Power
+	}
+	return slices.DeleteFunc(result, func(t Term) bool { return t.Power == 0 })
+}
+
+// Div делит единицу a на единицу b
+func Div(a, b Expr) Expr {
+	return Mul(a, b.Pow(-1))
+}
+
+// Pow возводит единицу в целую степень n
+func (e Expr) Pow(n int) Expr {
+	if n == 0 {
+		return nil
+	}
+	result := slices.Clone(e)
+	for i := range result {
+		result[i].Power *= n
+	}
+	return result
+}
+
+// Root извлекает из единицы корень степени n, если все показатели делятся на n: m^2 - m
+func (e Expr) Root(n int) (Expr, bool) {
+	result := slices.Clone(e)
+	for i := range result {
+		if result[i].Power%n != 0 {
+			return nil, false
+		}
+		result[i].Power /= n
+	}
+	return result, true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Dimension - показатели степени основных величин СИ в размерности единицы:
+// длины (m), массы (kg), времени (s), силы тока (A), температуры (K),
+// количества вещества (mol) и силы света (cd)
+type Dimension [7]int
+
+// IsZero проверяет, что величина безразмерна
+func (d Dimension) IsZero() bool {
+	return d == Dimension{}
+}
+
+// Unit - единица измерения: во сколько раз она больше единицы СИ той же размерности
+type Unit struct {
+	Factor    *big.Rat
+	Dimension Dimension
+}
+
+// definition - единица в справочнике. Множитель записан десятичной дробью,
+// prefixed - можно ли записывать единицу с приставкой: km, ms, kWh
+type definition struct {
+	factor    string
+	dimension Dimension
+	prefixed  bool
+}
+
+// Справочник единиц. Множители - точные значения, поэтому перевод единиц
+// не теряет точности в режимах exact и decimal
+var units = map[string]definition{
+	// Основные единицы СИ. Масса записывается в граммах, чтобы kg был граммом с приставкой
+	"m":   {"1", Dimension{1}, true},
+	"g":   {"0.001", Dimension{0, 1}, true},
+	"s":   {"1", Dimension{0, 0, 1}, true},
+	"A":   {"1", Dimension{0, 0, 0, 1}, true},
+	"K":   {"1", Dimension{0, 0, 0, 0, 1}, true},
+	"mol": {"1", Dimension{0, 0, 0, 0, 0, 1}, true},
+	"cd":  {"1", Dimension{0, 0, 0, 0, 0, 0, 1}, true},
+
+	// Производные единицы СИ
+	"Hz": {"1", Dimension{0, 0, -1}, true},
+	"N":  {"1", Dimension{1, 1, -2}, true},
+	"Pa": {"1", Dimension{-1, 1, -2}, true},
+	"J":  {"1", Dimension{2, 1, -2}, true},
+	"W":  {"1", Dimension{2, 1, -3}, true},
+	"C":  {"1", Dimension{0, 0, 1, 1}, true},
+	"V":  {"1", Dimension{2, 1, -3, -1}, true},
+	"Ω":  {"1", Dimension{2, 1, -3, -2}, true},
+	"L":  {"0.001", Dimension{3}, true},
+	"Wh": {"3600", Dimension{2, 1, -2}, true},
+	"eV": {"1.602176634e-19", Dimension{2, 1, -2}, true},
+
+	// Единицы вне СИ
+	"min": {"60", Dimension{0, 0, 1}, false},
+	"h":   {"3600", Dimension{0, 0, 1}, false},
+	"d":   {"86400", Dimension{0, 0, 1}, false},
+	"t":   {"1000", Dimension{0, 1}, false},
+	"ha":  {"10000", Dimension{2}, false},
+	"bar": {"100000", Dimension{-1, 1, -2}, false},
+	"atm": {"101325", Dimension{-1, 1, -2}, false},
+	"cal": {"4.184", Dimension{2, 1, -2}, false},
+	"in":  {"0.0254", Dimension{1}, false},
+	"ft":  {"0.3048", Dimension{1}, false},
+	"yd":  {"0.9144", Dimension{1}, false},
+	"mi":  {"1609.344", Dimension{1}, false},
+	"lb":  {"0.45359237", Dimension{0, 1}, false},
+	"oz":  {"0.028349523125", Dimension{0, 1}, false},
+}
+
+// Приставки СИ. Двухбуквенная da проверяется раньше d
+var prefixes = []struct {
+	symbol string
+	factor string
+}{
+	{"da", "10"},
+	{"Y", "1e24"}, {"Z", "1e21"}, {"E", "1e18"}, {"P", "1e15"}, {"T", "1e12"},
+	{"G", "1e9"}, {"M", "1e6"}, {"k", "1e3"}, {"h", "1e2"},
+	{"d", "1e-1"}, {"c", "1e-2"}, {"m", "1e-3"}, {"µ", "1e-6"}, {"μ", "1e-6"}, {"u", "1e-6"},
+	{"n", "1e-9"}, {"p", "1e-12"}, {"f", "1e-15"}, {"a", "1e-18"}, {"z", "1e-21"}, {"y", "1e-24"},
+}
+
+// Lookup находит единицу по обозначению, возможно с приставкой СИ: m, km, min, kWh
+func Lookup(symbol string) (Unit, bool) {
+	if def, ok := units[symbol]; ok {
+		return Unit{Factor: rat(def.factor), Dimension: def.dimension}, true
+	}
+	for _, prefix := range prefixes {
+		rest, ok := strings.CutPrefix(symbol, prefix.symbol)
+		if !ok {
+			continue
+		}
+		if def, ok := units[rest]; ok && def.prefixed {
+			factor := rat(def.factor)
+			return Unit{Factor: factor.Mul(factor, rat(prefix.factor)), Dimension: def.dimension}, true
+		}
+	}
+	return Unit{}, false
+}
+
+// IsUnit проверяет, обозначает ли имя единицу измерения
+func IsUnit(symbol string) bool {
+	_, ok := Lookup(symbol)
+	return ok
+}
+
+func rat(s string) *big.Rat {
+	r, _ := new(big.Rat).SetString(s)
+	return r
+}
@@ -0,0 +1,72 @@
+package unit
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		symbol    string
+		factor    string
+		dimension Dimension
+	}{
+		{"m", "1", Dimension{1}},
+		{"km", "1000", Dimension{1}},
+		{"kg", "1", Dimension{0, 1}},
+		{"dam", "10", Dimension{1}},
+		{"µs", "1/1000000", Dimension{0, 0, 1}},
+		{"kWh", "3600000", Dimension{2, 1, -2}},
+		{"h", "3600", Dimension{0, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		u, ok := Lookup(tt.symbol)
+		if !ok {
+			t.Errorf("Lookup(%q): единица не найдена", tt.symbol)
+			continue
+		}
+		if u.Factor.RatString() != tt.factor || u.Dimension != tt.dimension {
+			t.Errorf("Lookup(%q) = %s %v, want %s %v", tt.symbol, u.Factor.RatString(), u.Dimension, tt.factor, tt.dimension)
+		}
+	}
+
+	// Единицы вне СИ приставок не принимают
+	for _, symbol := range []string{"kmin", "Mh", "x", ""} {
+		if IsUnit(symbol) {
+			t.Errorf("IsUnit(%q) = true", symbol)
+		}
+	}
+}
+
+func TestExpr(t *testing.T) {
+	km, h, minute, m, s := Expr{{"km", 1}}, Expr{{"h", 1}}, Expr{{"min", 1}}, Expr{{"m", 1}}, Expr{{"s", 1}}
+	tests := []struct {
+		name string
+		expr Expr
+		want string
+	}{
+		{"speed", Div(km, h), "km/h"},
+		{"same dimension", Mul(Div(km, h), minute), "km"},
+		{"area", Mul(m, km), "m^2"},
+		{"frequency", Div(nil, s), "s^-1"},
+		{"acceleration", Div(Mul(Expr{{"kg", 1}}, m), s.Pow(2)), "kg·m/s^2"},
+		{"dimensionless", Div(m, m), ""},
+	}
+
+	for _, tt := range tests {
+		if got := tt.expr.String(); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if factor := Div(km, h).Unit().Factor; factor.Cmp(big.NewRat(5, 18)) != 0 {
+		t.Errorf("km/h = %s m/s, want 5/18", factor.RatString())
+	}
+	if root, ok := (Expr{{"m", 2}}).Root(2); !ok || root.String() != "m" {
+		t.Errorf("Root(m^2, 2) = %q, %v", root, ok)
+	}
+	if _, ok := (Expr{{"m", 3}}).Root(2); ok {
+		t.Error("Root(m^3, 2) без ошибки")
+	}
+}
@@ -0,0 +1,96 @@
+package variable
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+// Storage хранит переменные пользователей
+type Storage struct {
+	db *sql.DB
+}
+
+func NewStorage(dbPath string) (*Storage, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Создаем таблицу переменных, если она не существует
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS variables (
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			value REAL NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, name),
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// Set создаёт переменную или заменяет её значение
+func (s *Storage) Set(userID int64, variable shared.Variable) error {
+	_, err := s.db.Exec(`
+		INSERT INTO variables (user_id, name, value) VALUES (?, ?, ?)
+		ON CONFLICT(user_id, name) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`,
+		userID, variable.Name, variable.Value,
+	)
+	return err
+}
+
+// List возвращает все переменные пользователя, отсортированные по имени
+func (s *Storage) List(userID int64) ([]shared.Variable, error) {
+	rows, err := s.db.Query("SELECT name, value FROM variables WHERE user_id = ? ORDER BY name", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variables []shared.Variable
+	for rows.Next() {
+		var variable shared.Variable
+		if err := rows.Scan(&variable.Name, &variable.Value); err != nil {
+			return nil, err
+		}
+		variables = append(variables, variable)
+	}
+
+	return variables, rows.Err()
+}
+
+// Values возвращает значения переменных пользователя по их именам
+func (s *Storage) Values(userID int64) (map[string]float64, error) {
+	variables, err := s.List(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64, len(variables))
+	for _, variable := range variables {
+		values[variable.Name] = variable.Value
+	}
+	return values, nil
+}
+
+// Delete удаляет переменную. Возвращает false, если такой переменной не было
+func (s *Storage) Delete(userID int64, name string) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM variables WHERE user_id = ? AND name = ?", userID, name)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
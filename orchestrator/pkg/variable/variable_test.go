@@ -0,0 +1,49 @@
+package variable
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/nktauserum/web-calculation/shared"
+)
+
+// Переменные разных пользователей не пересекаются, повторная запись заменяет значение
+func TestStorage(t *testing.T) {
+	storage, err := NewStorage(filepath.Join(t.TempDir(), "variables.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	for _, set := range []struct {
+		user     int64
+		variable shared.Variable
+	}{
+		{1, shared.Variable{Name: "x", Value: 1}},
+		{1, shared.Variable{Name: "x", Value: 2}},
+		{1, shared.Variable{Name: "y", Value: 3}},
+		{2, shared.Variable{Name: "x", Value: 4}},
+	} {
+		if err := storage.Set(set.user, set.variable); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values, err := storage.Values(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values["x"] != 2 || values["y"] != 3 {
+		t.Errorf("Values(1) = %v, want x = 2, y = 3", values)
+	}
+
+	if deleted, err := storage.Delete(1, "x"); err != nil || !deleted {
+		t.Errorf("Delete(1, x) = %v, %v, want true", deleted, err)
+	}
+	if deleted, err := storage.Delete(1, "x"); err != nil || deleted {
+		t.Errorf("повторный Delete(1, x) = %v, %v, want false", deleted, err)
+	}
+	if values, _ := storage.Values(2); values["x"] != 4 {
+		t.Errorf("Values(2) = %v, want x = 4", values)
+	}
+}
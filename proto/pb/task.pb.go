@@ -30,6 +30,15 @@ type Task struct {
 	OperationTime float64                `protobuf:"fixed64,5,opt,name=operation_time,json=operationTime,proto3" json:"operation_time,omitempty"`
 	Status        bool                   `protobuf:"varint,6,opt,name=status,proto3" json:"status,omitempty"`
 	Result        float64                `protobuf:"fixed64,7,opt,name=result,proto3" json:"result,omitempty"`
+	Function      string                 `protobuf:"bytes,8,opt,name=function,proto3" json:"function,omitempty"`
+	Mode          string                 `protobuf:"bytes,9,opt,name=mode,proto3" json:"mode,omitempty"`
+	Precision     int32                  `protobuf:"varint,10,opt,name=precision,proto3" json:"precision,omitempty"`
+	Rounding      string                 `protobuf:"bytes,11,opt,name=rounding,proto3" json:"rounding,omitempty"`
+	Imag          float64                `protobuf:"fixed64,12,opt,name=imag,proto3" json:"imag,omitempty"`
+	Program       []string               `protobuf:"bytes,13,rep,name=program,proto3" json:"program,omitempty"`
+	Variable      string                 `protobuf:"bytes,14,opt,name=variable,proto3" json:"variable,omitempty"`
+	Chunk         int32                  `protobuf:"varint,15,opt,name=chunk,proto3" json:"chunk,omitempty"`
+	Chunks        int32                  `protobuf:"varint,16,opt,name=chunks,proto3" json:"chunks,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -113,10 +122,76 @@ func (x *Task) GetResult() float64 {
 	return 0
 }
 
+func (x *Task) GetFunction() string {
+	if x != nil {
+		return x.Function
+	}
+	return ""
+}
+
+func (x *Task) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *Task) GetPrecision() int32 {
+	if x != nil {
+		return x.Precision
+	}
+	return 0
+}
+
+func (x *Task) GetRounding() string {
+	if x != nil {
+		return x.Rounding
+	}
+	return ""
+}
+
+func (x *Task) GetImag() float64 {
+	if x != nil {
+		return x.Imag
+	}
+	return 0
+}
+
+func (x *Task) GetProgram() []string {
+	if x != nil {
+		return x.Program
+	}
+	return nil
+}
+
+func (x *Task) GetVariable() string {
+	if x != nil {
+		return x.Variable
+	}
+	return ""
+}
+
+func (x *Task) GetChunk() int32 {
+	if x != nil {
+		return x.Chunk
+	}
+	return 0
+}
+
+func (x *Task) GetChunks() int32 {
+	if x != nil {
+		return x.Chunks
+	}
+	return 0
+}
+
 type TaskResult struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	Result        float64                `protobuf:"fixed64,2,opt,name=result,proto3" json:"result,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Value         string                 `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	Imag          float64                `protobuf:"fixed64,5,opt,name=imag,proto3" json:"imag,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -165,6 +240,27 @@ func (x *TaskResult) GetResult() float64 {
 	return 0
 }
 
+func (x *TaskResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *TaskResult) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *TaskResult) GetImag() float64 {
+	if x != nil {
+		return x.Imag
+	}
+	return 0
+}
+
 type Empty struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -206,7 +302,7 @@ var File_task_proto protoreflect.FileDescriptor
 const file_task_proto_rawDesc = "" +
 	"\n" +
 	"\n" +
-	"task.proto\x12\x05tasks\"\xb1\x01\n" +
+	"task.proto\x12\x05tasks\"\x93\x03\n" +
 	"\x04Task\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
 	"\x04arg1\x18\x02 \x01(\tR\x04arg1\x12\x12\n" +
@@ -214,11 +310,24 @@ const file_task_proto_rawDesc = "" +
 	"\boperator\x18\x04 \x01(\tR\boperator\x12%\n" +
 	"\x0eoperation_time\x18\x05 \x01(\x01R\roperationTime\x12\x16\n" +
 	"\x06status\x18\x06 \x01(\bR\x06status\x12\x16\n" +
-	"\x06result\x18\a \x01(\x01R\x06result\"4\n" +
+	"\x06result\x18\a \x01(\x01R\x06result\x12\x1a\n" +
+	"\bfunction\x18\b \x01(\tR\bfunction\x12\x12\n" +
+	"\x04mode\x18\t \x01(\tR\x04mode\x12\x1c\n" +
+	"\tprecision\x18\n" +
+	" \x01(\x05R\tprecision\x12\x1a\n" +
+	"\brounding\x18\v \x01(\tR\brounding\x12\x12\n" +
+	"\x04imag\x18\f \x01(\x01R\x04imag\x12\x18\n" +
+	"\aprogram\x18\r \x03(\tR\aprogram\x12\x1a\n" +
+	"\bvariable\x18\x0e \x01(\tR\bvariable\x12\x14\n" +
+	"\x05chunk\x18\x0f \x01(\x05R\x05chunk\x12\x16\n" +
+	"\x06chunks\x18\x10 \x01(\x05R\x06chunks\"t\n" +
 	"\n" +
 	"TaskResult\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x16\n" +
-	"\x06result\x18\x02 \x01(\x01R\x06result\"\a\n" +
+	"\x06result\x18\x02 \x01(\x01R\x06result\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\tR\x05value\x12\x12\n" +
+	"\x04imag\x18\x05 \x01(\x01R\x04imag\"\a\n" +
 	"\x05Empty2q\n" +
 	"\vTaskService\x12/\n" +
 	"\x10GetAvailableTask\x12\f.tasks.Empty\x1a\v.tasks.Task\"\x00\x121\n" +
@@ -36,21 +36,42 @@ func (s *Server) GetAvailableTask(context.Context, *pb.Empty) (*pb.Task, error)
 	if !found {
 		return &pb.Task{Status: false}, nil
 	}
+	queue.Start(finalTask.ID)
 
 	return &pb.Task{
 		Id:            finalTask.ID,
 		Arg1:          finalTask.FirstArgument,
 		Arg2:          finalTask.SecondArgument,
 		Operator:      string(finalTask.Operator),
+		Function:      finalTask.Function,
+		Mode:          string(finalTask.Mode),
+		Precision:     int32(finalTask.Precision),
+		Rounding:      string(finalTask.Rounding),
 		OperationTime: finalTask.OperationTime,
 		Status:        true,
 		Result:        finalTask.Result,
+		Imag:          finalTask.Imag,
+		Program:       finalTask.Program,
+		Variable:      finalTask.Variable,
+		Chunk:         int32(finalTask.Chunk),
+		Chunks:        int32(finalTask.Chunks),
 	}, nil
 }
 
 func (s *Server) CompleteTask(ctx context.Context, taskResult *pb.TaskResult) (*pb.Empty, error) {
 	queue := service.GetQueue()
-	queue.Done(taskResult.Id, taskResult.Result)
+	if taskResult.Error != "" {
+		queue.Fail(taskResult.Id, taskResult.Error)
+		fmt.Printf("Задачу %d не удалось выполнить: %s\n", taskResult.Id, taskResult.Error)
+		return &pb.Empty{}, nil
+	}
+
+	queue.Done(shared.TaskResult{
+		ID:     taskResult.Id,
+		Result: taskResult.Result,
+		Imag:   taskResult.Imag,
+		Value:  taskResult.Value,
+	})
 	fmt.Printf("Задача %d успешно выполнена!\n", taskResult.Id)
 	return &pb.Empty{}, nil
 }
@@ -0,0 +1,35 @@
+package shared
+
+import (
+	"fmt"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Mode - режим арифметики, в котором вычисляется выражение
+type Mode string
+
+const (
+	ModeFloat    Mode = "float"    // двоичная арифметика float64, режим по умолчанию
+	ModeExact    Mode = "exact"    // точная арифметика рациональных чисел
+	ModeDecimal  Mode = "decimal"  // десятичная арифметика с округлением до заданного числа значащих цифр
+	ModeInterval Mode = "interval" // интервальная арифметика: результат - отрезок, гарантированно содержащий точное значение
+)
+
+// Знаков после запятой в десятичной записи результата точного режима.
+// MaxPrecision ограничивает и число значащих цифр десятичного режима
+const (
+	DefaultPrecision = 10
+	MaxPrecision     = 1000
+)
+
+// ParseMode проверяет режим из запроса. Пустая строка означает режим по умолчанию
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(mode) {
+	case "", ModeFloat:
+		return ModeFloat, nil
+	case ModeExact, ModeDecimal, ModeInterval:
+		return Mode(mode), nil
+	}
+	return "", fmt.Errorf("%w: %q", errors.ErrUnknownMode, mode)
+}
@@ -0,0 +1,23 @@
+package shared
+
+import (
+	"strconv"
+)
+
+// FormatComplex записывает комплексное число так, как его передают агенты и
+// видит пользователь: 2+4i, -1.5i. Число без мнимой части записывается как обычное
+func FormatComplex(z complex128) string {
+	re, im := real(z), imag(z)
+	if im == 0 {
+		return strconv.FormatFloat(re, 'f', -1, 64)
+	}
+
+	imaginary := strconv.FormatFloat(im, 'f', -1, 64) + "i"
+	if re == 0 {
+		return imaginary
+	}
+	if im > 0 {
+		imaginary = "+" + imaginary
+	}
+	return strconv.FormatFloat(re, 'f', -1, 64) + imaginary
+}
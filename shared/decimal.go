@@ -0,0 +1,125 @@
+package shared
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Rounding - способ округления в десятичном режиме
+type Rounding string
+
+const (
+	RoundHalfUp   Rounding = "half-up"   // половина - от нуля
+	RoundHalfEven Rounding = "half-even" // половина - к чётной цифре (банковское округление)
+	RoundDown     Rounding = "down"      // к нулю, отбрасывание цифр
+	RoundCeiling  Rounding = "ceiling"   // к плюс бесконечности
+)
+
+// Значащих цифр в десятичном режиме
+const DefaultDecimalPrecision = 28
+
+// ParseRounding проверяет способ округления из запроса.
+// Пустая строка означает банковское округление
+func ParseRounding(rounding string) (Rounding, error) {
+	switch Rounding(rounding) {
+	case "":
+		return RoundHalfEven, nil
+	case RoundHalfUp, RoundHalfEven, RoundDown, RoundCeiling:
+		return Rounding(rounding), nil
+	}
+	return "", fmt.Errorf("%w: %q", errors.ErrUnknownRounding, rounding)
+}
+
+// RoundDecimal округляет число до precision значащих цифр и возвращает его
+// десятичную запись без экспоненты и без незначащих нулей в дробной части
+func RoundDecimal(x *big.Rat, precision int, rounding Rounding) string {
+	if x.Sign() == 0 {
+		return "0"
+	}
+
+	// Сдвигаем запятую так, чтобы в целой части осталось precision цифр
+	scale := precision - 1 - decimalExponent(x)
+	digits := roundInteger(new(big.Rat).Mul(x, pow10(scale)), rounding)
+
+	// Округление вверх могло добавить разряд: 9.99 -> 10.0
+	if new(big.Int).Abs(digits).Cmp(pow10(precision).Num()) >= 0 {
+		scale--
+		digits = roundInteger(new(big.Rat).Mul(x, pow10(scale)), rounding)
+	}
+
+	return formatScaled(digits, scale)
+}
+
+// decimalExponent возвращает порядок числа: floor(log10(|x|))
+func decimalExponent(x *big.Rat) int {
+	abs := new(big.Rat).Abs(x)
+	exponent := len(abs.Num().String()) - len(abs.Denom().String())
+	if abs.Cmp(pow10(exponent)) < 0 {
+		exponent--
+	}
+	return exponent
+}
+
+// pow10 возвращает 10^n, в том числе для отрицательных n
+func pow10(n int) *big.Rat {
+	power := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(n))), nil)
+	if n < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), power)
+	}
+	return new(big.Rat).SetInt(power)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// roundInteger округляет число до целого выбранным способом
+func roundInteger(x *big.Rat, rounding Rounding) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+	if remainder.Sign() == 0 {
+		return quotient
+	}
+
+	away := false
+	switch rounding {
+	case RoundDown:
+	case RoundCeiling:
+		away = x.Sign() > 0
+	default:
+		// Сравниваем отброшенную часть с половиной: 2*|остаток| и знаменатель
+		half := new(big.Int).Lsh(new(big.Int).Abs(remainder), 1).Cmp(x.Denom())
+		away = half > 0 || half == 0 && (rounding == RoundHalfUp || quotient.Bit(0) == 1)
+	}
+
+	if away {
+		quotient.Add(quotient, big.NewInt(int64(x.Sign())))
+	}
+	return quotient
+}
+
+// formatScaled записывает число digits * 10^-scale десятичной дробью
+func formatScaled(digits *big.Int, scale int) string {
+	sign := ""
+	if digits.Sign() < 0 {
+		sign = "-"
+	}
+	text := new(big.Int).Abs(digits).String()
+
+	if scale <= 0 {
+		return sign + text + strings.Repeat("0", -scale)
+	}
+	if len(text) <= scale {
+		text = strings.Repeat("0", scale-len(text)+1) + text
+	}
+	integer, fraction := text[:len(text)-scale], strings.TrimRight(text[len(text)-scale:], "0")
+	if fraction == "" {
+		return sign + integer
+	}
+	return sign + integer + "." + fraction
+}
@@ -0,0 +1,36 @@
+package shared
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestRoundDecimal(t *testing.T) {
+	tests := []struct {
+		x         string
+		precision int
+		rounding  Rounding
+		want      string
+	}{
+		{"1/3", 5, RoundHalfEven, "0.33333"},
+		{"2/3", 3, RoundHalfEven, "0.667"},
+		{"2/3", 3, RoundDown, "0.666"},
+		{"-2/3", 3, RoundCeiling, "-0.666"},
+		{"2.5", 1, RoundHalfEven, "2"},
+		{"3.5", 1, RoundHalfEven, "4"},
+		{"2.5", 1, RoundHalfUp, "3"},
+		{"-2.5", 1, RoundHalfUp, "-3"},
+		{"9.99", 2, RoundHalfEven, "10"},
+		{"123456", 2, RoundHalfEven, "120000"},
+		{"0.00012345", 3, RoundDown, "0.000123"},
+		{"1.50", 10, RoundHalfEven, "1.5"},
+		{"0", 5, RoundHalfEven, "0"},
+	}
+
+	for _, tt := range tests {
+		x, _ := new(big.Rat).SetString(tt.x)
+		if got := RoundDecimal(x, tt.precision, tt.rounding); got != tt.want {
+			t.Errorf("RoundDecimal(%s, %d, %s) = %s, want %s", tt.x, tt.precision, tt.rounding, got, tt.want)
+		}
+	}
+}
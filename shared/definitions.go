@@ -0,0 +1,28 @@
+package shared
+
+// Именованная переменная пользователя, которую можно использовать в выражениях
+type Variable struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// Применяется при запросе к оркестратору
+// PUT /api/v1/variables/[:name]
+type VariableRequest struct {
+	Value *float64 `json:"value"`
+}
+
+// Функция пользователя, которую можно вызывать в выражениях
+type Function struct {
+	Name       string   `json:"name"`
+	Params     []string `json:"params"`
+	Definition string   `json:"definition"`
+	Version    int      `json:"version"`
+	Error      string   `json:"error,omitempty"` // почему сохранённое определение больше не действует
+}
+
+// Применяется при запросе к оркестратору
+// POST /api/v1/functions
+type FunctionRequest struct {
+	Definition string `json:"definition"`
+}
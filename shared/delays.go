@@ -0,0 +1,64 @@
+package shared
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Переменные среды со временем выполнения операций агентом в миллисекундах
+var ArithmeticDelays = map[rune]string{
+	'+': "TIME_ADDITION_MS",
+	'-': "TIME_SUBTRACTION_MS",
+	'*': "TIME_MULTIPLICATIONS_MS",
+	'/': "TIME_DIVISIONS_MS",
+	'^': "TIME_POWER_MS",
+}
+
+// Переменные среды с временем выполнения целочисленных операций
+var IntegerDelays = map[rune]string{
+	'%': "TIME_MODULO_MS",
+	'÷': "TIME_INT_DIVISION_MS",
+	'&': "TIME_BITWISE_MS",
+	'|': "TIME_BITWISE_MS",
+	'⊕': "TIME_BITWISE_MS",
+	'≪': "TIME_SHIFT_MS",
+	'≫': "TIME_SHIFT_MS",
+}
+
+// ComparisonDelay - переменная среды со временем выполнения сравнений
+const ComparisonDelay = "TIME_COMPARISON_MS"
+
+// DelayVariable возвращает переменную среды со временем выполнения операции:
+// TIME_<ФУНКЦИЯ>_MS для вызова функции, иначе переменную оператора.
+// Для операций, которые агент не выполняет, возвращает пустую строку
+func DelayVariable(operator rune, function string) string {
+	if function != "" {
+		return "TIME_" + strings.ToUpper(function) + "_MS"
+	}
+	if variable, ok := ArithmeticDelays[operator]; ok {
+		return variable
+	}
+	if variable, ok := IntegerDelays[operator]; ok {
+		return variable
+	}
+	switch operator {
+	case '<', '>', '≤', '≥', '=', '≠':
+		return ComparisonDelay
+	}
+	return ""
+}
+
+// OperationDelay возвращает время выполнения операции агентом в миллисекундах.
+// Незаданная или неверная переменная означает отсутствие задержки
+func OperationDelay(operator rune, function string) float64 {
+	variable := DelayVariable(operator, function)
+	if variable == "" {
+		return 0
+	}
+	ms, err := strconv.ParseFloat(os.Getenv(variable), 64)
+	if err != nil || ms < 0 {
+		return 0
+	}
+	return ms
+}
@@ -1,6 +1,9 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrMismatchedParentheses = errors.New("несоответствующие скобки")
@@ -9,4 +12,70 @@ var (
 	ErrNotEnoughOperands     = errors.New("недостаточно операндов")
 	ErrDivisionByZero        = errors.New("деление на ноль")
 	ErrUnknownOperator       = errors.New("неизвестный оператор")
+	ErrUnexpectedToken       = errors.New("неожиданный токен")
+	ErrUnknownFunction       = errors.New("неизвестная функция")
+	ErrDomain                = errors.New("аргумент вне области определения функции")
+	ErrArgumentCount         = errors.New("неверное количество аргументов функции")
+	ErrUndefinedVariable     = errors.New("неизвестная переменная")
+	ErrInvalidName           = errors.New("недопустимое имя")
+	ErrReservedName          = errors.New("имя зарезервировано")
+	ErrRecursion             = errors.New("рекурсивный вызов функции")
+	ErrExpressionTooLarge    = errors.New("выражение слишком велико")
+	ErrNotInteger            = errors.New("операция определена только для целых чисел")
+	ErrIntegerOverflow       = errors.New("результат не помещается в целое число")
+	ErrInvalidShift          = errors.New("недопустимая величина сдвига")
+	ErrNumberOutOfRange      = errors.New("число вне допустимого диапазона")
+	ErrOverflow              = errors.New("переполнение: результат слишком велик по модулю")
+	ErrNotANumber            = errors.New("результат не является числом")
+	ErrUnknownMode           = errors.New("неизвестный режим вычисления")
+	ErrInvalidPrecision      = errors.New("недопустимая точность")
+	ErrInexact               = errors.New("операция не выполняется точно")
+	ErrExponentTooLarge      = errors.New("слишком большой показатель степени")
+	ErrUnknownRounding       = errors.New("неизвестный способ округления")
+	ErrComplexMode           = errors.New("комплексные числа доступны только в режиме float")
+	ErrComplexArgument       = errors.New("операция не определена для комплексных чисел")
+	ErrBranchNotTaken        = errors.New("ветка условия не выбрана")
+	ErrReassignment          = errors.New("переменная уже присвоена в сценарии")
+	ErrUsedBeforeAssignment  = errors.New("переменная используется до присваивания")
+	ErrUnusedStatement       = errors.New("результат инструкции не используется")
+	ErrUnknownFormat         = errors.New("неизвестный формат")
+	ErrUnknownLocale         = errors.New("неизвестная локаль")
+	ErrNotDifferentiable     = errors.New("выражение не дифференцируется")
+	ErrInvalidInterval       = errors.New("нижняя граница интервала больше верхней")
+	ErrIntervalDivision      = errors.New("деление на интервал, содержащий ноль")
+	ErrUncertainCondition    = errors.New("условие не определено: значение может быть и истинным, и ложным")
+	ErrNegativeUncertainty   = errors.New("погрешность не может быть отрицательной")
+	ErrUncertainMode         = errors.New("величины с погрешностью вычисляются только в режиме float")
+	ErrInvalidSamples        = errors.New("недопустимое число выборок")
+	ErrIncompatibleUnits     = errors.New("несовместимые единицы измерения")
+	ErrShapeMismatch         = errors.New("несовместимые размеры векторов")
+	ErrVectorMode            = errors.New("векторы недоступны в режиме interval и с величинами с погрешностью")
+	ErrExpressionNotFound    = errors.New("выражение не найдено")
+	ErrPlotNotFound          = errors.New("график не найден")
+	ErrInvalidPlotRange      = errors.New("недопустимый отрезок графика")
+	ErrInvalidPlotPoints     = errors.New("недопустимое число точек графика")
+	ErrPlotValue             = errors.New("график строится только по числам: векторы и величины с погрешностью недоступны")
+	ErrIterationMode         = errors.New("интегралы и ряды вычисляются только в режиме float, над числами без погрешности")
+	ErrNestedIteration       = errors.New("интегралы и ряды нельзя вкладывать в тело другого интеграла или ряда")
+	ErrTooManyTerms          = errors.New("слишком много членов ряда")
 )
+
+// SyntaxError - ошибка разбора выражения с указанием места, где она возникла.
+// Pos - смещение в байтах от начала выражения
+type SyntaxError struct {
+	Pos      int    `json:"position"`
+	Token    string `json:"token"`
+	Expected string `json:"expected,omitempty"`
+	Err      error  `json:"-"`
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("%s: позиция %d, конец выражения", e.Err, e.Pos)
+	}
+	return fmt.Sprintf("%s: позиция %d, токен %q", e.Err, e.Pos, e.Token)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,82 @@
+package shared
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Функции одного аргумента, доступные в выражениях.
+// Вычисляются агентами так же, как и бинарные операции
+var UnaryFunctions = map[string]func(x float64) (float64, error){
+	"sqrt": func(x float64) (float64, error) {
+		if x < 0 {
+			return 0, fmt.Errorf("sqrt(%g): %w", x, errors.ErrDomain)
+		}
+		return math.Sqrt(x), nil
+	},
+	"abs": func(x float64) (float64, error) {
+		return math.Abs(x), nil
+	},
+	"ln": func(x float64) (float64, error) {
+		if x <= 0 {
+			return 0, fmt.Errorf("ln(%g): %w", x, errors.ErrDomain)
+		}
+		return math.Log(x), nil
+	},
+	"log10": func(x float64) (float64, error) {
+		if x <= 0 {
+			return 0, fmt.Errorf("log10(%g): %w", x, errors.ErrDomain)
+		}
+		return math.Log10(x), nil
+	},
+	"exp": func(x float64) (float64, error) {
+		return math.Exp(x), nil
+	},
+	"sin": func(x float64) (float64, error) {
+		return math.Sin(x), nil
+	},
+	"cos": func(x float64) (float64, error) {
+		return math.Cos(x), nil
+	},
+	"tan": func(x float64) (float64, error) {
+		return math.Tan(x), nil
+	},
+}
+
+// Функции двух аргументов. Пользователю напрямую недоступны: из них
+// оркестратор строит деревья задач для агрегатных функций
+var BinaryFunctions = map[string]func(x, y float64) (float64, error){
+	"min": func(x, y float64) (float64, error) {
+		return math.Min(x, y), nil
+	},
+	"max": func(x, y float64) (float64, error) {
+		return math.Max(x, y), nil
+	},
+}
+
+// Агрегатные функции произвольного (но не нулевого) числа аргументов.
+// Собственной реализации у них нет - оркестратор раскладывает их в дерево задач,
+// которые агенты вычисляют параллельно
+var AggregateFunctions = map[string]bool{
+	"sum":    true,
+	"avg":    true,
+	"min":    true,
+	"max":    true,
+	"median": true,
+}
+
+// Функции со связанной переменной: интеграл integrate(f, x, a, b) и ряд sum(k, a, b, f).
+// Оркестратор делит отрезок на участки, а агенты вычисляют тело на своём участке сами
+var IterationFunctions = map[string]bool{
+	"integrate": true,
+	"sum":       true,
+}
+
+// Функции матриц. Как и агрегатные, агентам они не передаются: оркестратор
+// раскладывает их в задачи над элементами
+var MatrixFunctions = map[string]bool{
+	"det":       true,
+	"transpose": true,
+}
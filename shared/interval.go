@@ -0,0 +1,71 @@
+package shared
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Interval - отрезок [Lo, Hi], который гарантированно содержит точное значение.
+// В режиме interval аргументы и результаты задач - интервалы в записи "[lo, hi]";
+// число без скобок - интервал из одной точки
+type Interval struct {
+	Lo, Hi float64
+}
+
+// String записывает интервал так, как он передаётся в задачах: "[1.9, 2.1]".
+// Границы записываются кратчайшим числом, которое читается обратно без потерь
+func (iv Interval) String() string {
+	return "[" + strconv.FormatFloat(iv.Lo, 'g', -1, 64) + ", " + strconv.FormatFloat(iv.Hi, 'g', -1, 64) + "]"
+}
+
+// Mid возвращает середину интервала - приближённое значение результата
+func (iv Interval) Mid() float64 {
+	return iv.Lo/2 + iv.Hi/2
+}
+
+// Contains проверяет, лежит ли число в интервале
+func (iv Interval) Contains(x float64) bool {
+	return iv.Lo <= x && x <= iv.Hi
+}
+
+// IsPoint проверяет, состоит ли интервал из одного числа
+func (iv Interval) IsPoint() bool {
+	return iv.Lo == iv.Hi
+}
+
+// IsInterval проверяет, записан ли операнд интервалом в скобках
+func IsInterval(s string) bool {
+	return strings.HasPrefix(s, "[")
+}
+
+// ParseInterval читает интервал "[lo, hi]" или число, которое считается интервалом из одной точки
+func ParseInterval(s string) (Interval, error) {
+	inner, ok := strings.CutPrefix(s, "[")
+	if !ok {
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Interval{}, fmt.Errorf("%w: %q", errors.ErrInvalidNumber, s)
+		}
+		return Interval{x, x}, nil
+	}
+
+	lo, hi, ok := strings.Cut(strings.TrimSuffix(inner, "]"), ",")
+	if !ok || !strings.HasSuffix(inner, "]") {
+		return Interval{}, fmt.Errorf("%w: %q", errors.ErrInvalidNumber, s)
+	}
+	var iv Interval
+	var err error
+	if iv.Lo, err = strconv.ParseFloat(strings.TrimSpace(lo), 64); err != nil {
+		return Interval{}, fmt.Errorf("%w: %q", errors.ErrInvalidNumber, s)
+	}
+	if iv.Hi, err = strconv.ParseFloat(strings.TrimSpace(hi), 64); err != nil {
+		return Interval{}, fmt.Errorf("%w: %q", errors.ErrInvalidNumber, s)
+	}
+	if iv.Lo > iv.Hi {
+		return Interval{}, fmt.Errorf("%w: %q", errors.ErrInvalidInterval, s)
+	}
+	return iv, nil
+}
@@ -0,0 +1,20 @@
+package shared
+
+import (
+	"math"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// CheckFinite проверяет, что результат вычисления - конечное число.
+// Бесконечность и NaN не являются результатом: операция, которая их даёт,
+// завершается ошибкой, и выражение не вычисляется
+func CheckFinite(value float64) error {
+	switch {
+	case math.IsNaN(value):
+		return errors.ErrNotANumber
+	case math.IsInf(value, 0):
+		return errors.ErrOverflow
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package shared
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nktauserum/web-calculation/shared/errors"
+)
+
+// Выражения с величинами с погрешностью (5 ± 0.1) вычисляются методом Монте-Карло:
+// задачи выражения повторяются для каждой пачки выборок, а агенты вычисляют
+// операцию сразу над всеми значениями пачки
+const (
+	DefaultSamples  = 1000  // число выборок по умолчанию
+	MaxSamples      = 10000 // наибольшее число выборок
+	SamplesPerBatch = 100   // значений в одной пачке
+)
+
+// Процентили, которые попадают в сводку: медиана и границы, в которых
+// у нормального распределения лежат 68% и 95% значений
+var SummaryPercentiles = []float64{2.5, 16, 50, 84, 97.5}
+
+// Samples - пачка значений величины с погрешностью. Аргументы и результаты задач
+// записываются в фигурных скобках: "{4.98, 5.03, 5.1}"
+type Samples []float64
+
+// String записывает пачку так, как она передаётся в задачах
+func (s Samples) String() string {
+	parts := make([]string, len(s))
+	for i, x := range s {
+		parts[i] = strconv.FormatFloat(x, 'g', -1, 64)
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// IsSamples проверяет, записан ли операнд пачкой значений
+func IsSamples(s string) bool {
+	return strings.HasPrefix(s, "{")
+}
+
+// ParseSamples читает пачку "{x1, x2, ...}" или число, которое считается пачкой из одного значения
+func ParseSamples(s string) (Samples, error) {
+	inner, ok := strings.CutPrefix(s, "{")
+	if !ok {
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", errors.ErrInvalidNumber, s)
+		}
+		return Samples{x}, nil
+	}
+
+	inner, ok = strings.CutSuffix(inner, "}")
+	if !ok || strings.TrimSpace(inner) == "" {
+		return nil, fmt.Errorf("%w: %q", errors.ErrInvalidNumber, s)
+	}
+	parts := strings.Split(inner, ",")
+	samples := make(Samples, len(parts))
+	for i, part := range parts {
+		x, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", errors.ErrInvalidNumber, s)
+		}
+		samples[i] = x
+	}
+	return samples, nil
+}
+
+// Mean возвращает среднее значение пачки
+func (s Samples) Mean() float64 {
+	var sum float64
+	for _, x := range s {
+		sum += x
+	}
+	return sum / float64(len(s))
+}
+
+// Distribution - сводка выборки, которой завершается выражение с погрешностями
+type Distribution struct {
+	Samples     int                `json:"samples"`
+	Mean        float64            `json:"mean"`
+	StdDev      float64            `json:"std_dev"`
+	Percentiles map[string]float64 `json:"percentiles"` // ключ - процент: "2.5", "50"
+}
+
+// Summarize находит среднее, стандартное отклонение (исправленное, по n - 1 степеням
+// свободы) и процентили SummaryPercentiles с линейной интерполяцией между значениями
+func Summarize(samples Samples) Distribution {
+	d := Distribution{Samples: len(samples), Mean: samples.Mean(), Percentiles: make(map[string]float64)}
+	if len(samples) > 1 {
+		var squares float64
+		for _, x := range samples {
+			squares += (x - d.Mean) * (x - d.Mean)
+		}
+		d.StdDev = math.Sqrt(squares / float64(len(samples)-1))
+	}
+
+	sorted := slices.Sorted(slices.Values(samples))
+	for _, p := range SummaryPercentiles {
+		rank := p / 100 * float64(len(sorted)-1)
+		i := int(rank)
+		value := sorted[i]
+		if i+1 < len(sorted) {
+			value += (rank - float64(i)) * (sorted[i+1] - sorted[i])
+		}
+		d.Percentiles[strconv.FormatFloat(p, 'g', -1, 64)] = value
+	}
+	return d
+}
+
+// String записывает среднее и стандартное отклонение: отклонение округляется
+// до двух значащих цифр, среднее - до того же разряда. 10.0012 ± 0.3162 - "10.00 ± 0.32"
+func (d Distribution) String() string {
+	if d.StdDev == 0 {
+		return strconv.FormatFloat(d.Mean, 'g', -1, 64)
+	}
+	digit := int(math.Floor(math.Log10(d.StdDev))) - 1 // разряд второй значащей цифры
+	if digit < 0 {
+		return strconv.FormatFloat(d.Mean, 'f', -digit, 64) + " ± " + strconv.FormatFloat(d.StdDev, 'f', -digit, 64)
+	}
+	scale := math.Pow10(digit)
+	mean, sigma := math.Round(d.Mean/scale)*scale, math.Round(d.StdDev/scale)*scale
+	return strconv.FormatFloat(mean, 'f', 0, 64) + " ± " + strconv.FormatFloat(sigma, 'f', 0, 64)
+}
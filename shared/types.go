@@ -13,20 +13,132 @@ type CalculateResponse struct {
 type TaskResult struct {
 	ID     int64   `json:"id"`
 	Result float64 `json:"result"`
+	Imag   float64 `json:"imag,omitempty"` // мнимая часть результата, Result - вещественная
+	Value  string  `json:"value"`          // результат без потери точности: "0.3", "3/10" или "2+4i"
+	Error  string  `json:"error,omitempty"`
 }
 
 // Применяется при запросе к оркестратору
 // /api/v1/expression/[:id]
 type ExpressionRequest struct {
 	Expression string `json:"expression"`
+	Mode       string `json:"mode,omitempty"`      // float (по умолчанию), exact, decimal или interval; величины с ± - в float
+	Precision  *int   `json:"precision,omitempty"` // знаков после запятой (exact) или значащих цифр (decimal)
+	Rounding   string `json:"rounding,omitempty"`  // способ округления в режиме decimal
+	Locale     string `json:"locale,omitempty"`    // запись чисел: en, de, ru или fr
+	Samples    *int   `json:"samples,omitempty"`   // число выборок Монте-Карло для величин с погрешностью
+}
+
+// Запрос производной выражения по переменной. Если задана точка at, производная
+// вычисляется в ней как обычное выражение с режимом, точностью и округлением запроса
+type DeriveRequest struct {
+	ExpressionRequest
+	Variable string   `json:"variable"`
+	At       *float64 `json:"at,omitempty"`
+}
+
+// Производная в каноническом виде и ID выражения, вычисляющего её в точке at
+type DeriveResponse struct {
+	Derivative string `json:"derivative"`
+	ID         int64  `json:"id,omitempty"`
+}
+
+// Число точек графика по умолчанию и наибольшее
+const (
+	DefaultPlotPoints = 200
+	MaxPlotPoints     = 1000
+)
+
+// Интеграл и ряд делятся на IterationChunks участков, которые агенты вычисляют параллельно.
+// Участок интеграла вычисляется по формуле Симпсона с SimpsonSteps отрезками разбиения.
+// В ряде может быть не больше MaxSeriesTerms членов
+const (
+	IterationChunks = 8
+	SimpsonSteps    = 1000
+	MaxSeriesTerms  = 10_000_000
+)
+
+// Запрос графика выражения: оно вычисляется в Points точках, равномерно
+// расставленных от From до To, как обычное выражение со значением Variable в точке
+type PlotRequest struct {
+	Expression string  `json:"expression"`
+	Variable   string  `json:"variable,omitempty"` // переменная по оси абсцисс, по умолчанию x
+	From       float64 `json:"from"`
+	To         float64 `json:"to"`
+	Points     int     `json:"points,omitempty"`
+	Locale     string  `json:"locale,omitempty"`
+}
+
+// Точка графика. Y нет, пока значение в точке не вычислено или если выражение
+// в ней не определено: деление на ноль, корень из отрицательного, комплексный результат
+type PlotPoint struct {
+	X float64  `json:"x"`
+	Y *float64 `json:"y"`
+}
+
+// График выражения - задание, которое агенты вычисляют по точкам, как выражение по задачам
+type Plot struct {
+	ID         int64       `json:"id"`
+	UserID     int64       `json:"user_id"`
+	Expression string      `json:"expression"` // выражение в каноническом виде
+	Variable   string      `json:"variable"`
+	From       float64     `json:"from"`
+	To         float64     `json:"to"`
+	Status     bool        `json:"status"`
+	Progress   float64     `json:"progress"`        // доля точек, значения в которых уже известны
+	Unit       string      `json:"unit,omitempty"`  // единица значений выражения
+	Error      string      `json:"error,omitempty"` // причина, по которой не вычислена ни одна точка
+	Series     []PlotPoint `json:"series"`
+	SVG        string      `json:"svg,omitempty"` // график в SVG, когда вычислены все точки
+}
+
+// Запрос на запись выражения в каноническом виде и формулой
+type FormatRequest struct {
+	Expression string `json:"expression"`
+	Format     string `json:"format,omitempty"` // text (по умолчанию), latex или mathml
+	Locale     string `json:"locale,omitempty"`
+}
+
+// Выражение в каноническом виде и, если запрошено, формулой LaTeX или MathML
+type FormatResponse struct {
+	Canonical string `json:"canonical"`
+	LaTeX     string `json:"latex,omitempty"`
+	MathML    string `json:"mathml,omitempty"`
 }
 
 // Универсальный тип выражения
 type Expression struct {
-	ID     int64  `json:"id"`
-	UserID int64  `json:"user_id"`
-	Status bool   `json:"status"`
-	Result string `json:"result"`
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"user_id"`
+	Expression string `json:"expression,omitempty"` // выражение в каноническом виде
+	Status     bool   `json:"status"`
+	Result     string `json:"result"`
+	Error      string `json:"error,omitempty"` // причина, по которой выражение не удалось вычислить
+
+	// Режим арифметики. В точном режиме Result - десятичная запись с Precision
+	// знаками после запятой, а Fraction - несократимая дробь. В десятичном
+	// режиме каждая операция округляется до Precision значащих цифр способом Rounding
+	Mode      Mode     `json:"mode"`
+	Precision int      `json:"precision,omitempty"`
+	Rounding  Rounding `json:"rounding,omitempty"`
+	Fraction  string   `json:"fraction,omitempty"`
+
+	// Сводка выборки выражения с величинами с погрешностью (5 ± 0.1). Result
+	// тогда - среднее и стандартное отклонение: "10.00 ± 0.32"
+	Distribution *Distribution `json:"distribution,omitempty"`
+
+	// Единица результата выражения с величинами (3 km / 20 min to km/h). Result
+	// тогда записывается вместе с ней: "9 km/h"
+	Unit string `json:"unit,omitempty"`
+
+	// Значения переменных и версии функций на момент отправки выражения
+	Variables map[string]float64 `json:"variables,omitempty"`
+	Functions map[string]int     `json:"functions,omitempty"`
+
+	// Значения переменных, присвоенных в сценарии (a = 3*4; b = a + 7; b / 2),
+	// в том же виде, что и Result. Пока задача переменной не выполнена, значение - ссылка idN
+	Results map[string]string `json:"results,omitempty"`
+	Units   map[string]string `json:"units,omitempty"` // единицы переменных сценария, у безразмерных их нет
 }
 
 // Список из выражений, выдающийся оркестратором при запросе
@@ -36,11 +148,40 @@ type ExpressionList struct {
 }
 
 type Task struct {
-	ID             int64   `json:"id"`
-	FirstArgument  string  `json:"arg1"`
-	SecondArgument string  `json:"arg2"`
-	Operator       rune    `json:"operator"`
-	OperationTime  float64 `json:"operation_time"`
-	Status         bool    `json:"status"`
-	Result         float64 `json:"result"`
+	ID             int64    `json:"id"`
+	FirstArgument  string   `json:"arg1"`
+	SecondArgument string   `json:"arg2"`
+	ThirdArgument  string   `json:"arg3,omitempty"` // ветка else условной задачи, ветка then - во втором аргументе
+	Operator       rune     `json:"operator"`
+	Function       string   `json:"function,omitempty"` // имя функции для задач-вызовов, у них нет второго аргумента
+	OperationTime  float64  `json:"operation_time"`
+	Status         bool     `json:"status"`
+	Result         float64  `json:"result"`
+	Imag           float64  `json:"imag,omitempty"`  // мнимая часть комплексного результата
+	Value          string   `json:"value,omitempty"` // результат без потери точности
+	Mode           Mode     `json:"mode"`
+	Precision      int      `json:"precision,omitempty"` // значащих цифр в режиме decimal
+	Rounding       Rounding `json:"rounding,omitempty"`
+	Error          string   `json:"error,omitempty"`
+
+	// Задача ветки условия ждёт, пока условная задача Guard выберет ветку Branch
+	Guard  int64  `json:"guard,omitempty"`
+	Branch string `json:"branch,omitempty"`
+
+	// Задача-участок интеграла или ряда: агент сам вычисляет тело Program (обратная польская
+	// запись) по переменной Variable на участке Chunk из Chunks отрезка [FirstArgument, SecondArgument]
+	Program  []string `json:"program,omitempty"`
+	Variable string   `json:"variable,omitempty"`
+	Chunk    int      `json:"chunk,omitempty"`
+	Chunks   int      `json:"chunks,omitempty"`
+
+	ExpressionID int64   `json:"expression_id,omitempty"` // выражение, ради которого создана задача
+	DependsOn    []int64 `json:"depends_on,omitempty"`    // задачи, результаты которых нужны задаче
+	StartedAt    int64   `json:"started_at,omitempty"`    // когда задача впервые выдана агенту, мс Unix
 }
+
+// Ветки условной задачи
+const (
+	BranchThen = "then"
+	BranchElse = "else"
+)
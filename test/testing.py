@@ -124,7 +124,7 @@ def calculation_test():
     try:
         token = calc.login(username=random_username, password="password123")
         invalid_expressions = [
-            "2++2",
+            "2+*2",
             "2+2*",
             "(2+2",
             "2+2)",